@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/internal/task"
+	"github.com/spf13/cobra"
+)
+
+// NewFsckCommand returns the command checking that the file, cache and
+// metadata storages agree with each other. openTaskStorage is only called
+// to enqueue the check in the background.
+func NewFsckCommand(checker *task.ConsistencyChecker, openTaskStorage func() (scheduler.Storer, error)) *cobra.Command {
+	var (
+		opts       task.FsckOptions
+		background bool
+	)
+	fsckCmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Check the consistency of the file, cache and metadata storages",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if background {
+				t := scheduler.NewTask(task.FsckTaskName, opts)
+				taskStorage, err := openTaskStorage()
+				if err != nil {
+					return err
+				}
+				if err := taskStorage.EnqueueTask(&t); err != nil {
+					return err
+				}
+				fmt.Println("fsck task", t.Id.String(), "enqueued")
+				return nil
+			}
+
+			report, err := checker.Check(opts)
+			if err != nil {
+				return err
+			}
+			b, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(b))
+			if len(report.RepairFailures) > 0 {
+				return errors.New("some inconsistencies could not be repaired, see the logs")
+			}
+			if !report.IsConsistent() && !opts.Repair {
+				return errors.New("inconsistencies found, run again with --repair to fix them")
+			}
+			return nil
+		},
+	}
+	fsckCmd.Flags().StringVar(&opts.Path, "path", "/", "folder to check")
+	fsckCmd.Flags().BoolVar(&opts.Repair, "repair", false, "fix the inconsistencies found")
+	fsckCmd.Flags().BoolVar(&opts.DeleteRecords, "delete-records", false, "let the repair delete the records whose original is missing")
+	fsckCmd.Flags().BoolVar(&background, "background", false, "run the check as a task of the server scheduler")
+	return fsckCmd
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/spf13/cobra"
+)
+
+// IndexRebuilder is implemented by the storers backed by a search index.
+type IndexRebuilder interface {
+	RebuildIndex() error
+}
+
+func NewIndexCommand(mediaStorage media.Storer) *cobra.Command {
+	indexCmd := &cobra.Command{
+		Use:   "index",
+		Short: "Manage the search indexes",
+	}
+	indexCmd.AddCommand(&cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuild the media index, the current one is served until the new one is ready",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rebuilder, ok := mediaStorage.(IndexRebuilder)
+			if !ok {
+				return errors.New("the media storage has no index to rebuild")
+			}
+			if err := rebuilder.RebuildIndex(); err != nil {
+				return err
+			}
+			fmt.Println("media index rebuilt")
+			return nil
+		},
+	})
+	return indexCmd
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/internal/task"
+	"github.com/spf13/cobra"
+)
+
+// NewMigrateCommand returns the command copying the storages to the ones
+// described by the TARGET_ prefixed environment variables. migrator is nil
+// when there are none. openTaskStorage is only called to enqueue the
+// migration in the background.
+func NewMigrateCommand(migrator *task.StorageMigrator, openTaskStorage func() (scheduler.Storer, error)) *cobra.Command {
+	var (
+		opts       task.MigrateOptions
+		background bool
+	)
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy the storages to the ones described by the TARGET_ prefixed environment variables",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if migrator == nil {
+				return errors.New("no target storage configured, set the TARGET_ prefixed environment variables")
+			}
+			if background {
+				t := scheduler.NewTask(task.MigrateStorageTaskName, opts)
+				taskStorage, err := openTaskStorage()
+				if err != nil {
+					return err
+				}
+				if err := taskStorage.EnqueueTask(&t); err != nil {
+					return err
+				}
+				fmt.Println("migration task", t.Id.String(), "enqueued")
+				return nil
+			}
+
+			report, err := migrator.Migrate(opts, func(_ *task.MigrationReport, percent int, message string) {
+				fmt.Printf("[%3d%%] %s\n", percent, message)
+			})
+			if report != nil {
+				b, _ := json.MarshalIndent(report, "", "  ")
+				fmt.Println(string(b))
+			}
+			return err
+		},
+	}
+	migrateCmd.Flags().StringSliceVar(&opts.Stores, "stores", nil, "stores to migrate among files, cache, medias, apikeys and named_transformations, all by default")
+	migrateCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "list what would be copied without copying it")
+	migrateCmd.Flags().BoolVar(&opts.SkipVerify, "skip-verify", false, "don't read the copies back to compare their checksums")
+	migrateCmd.Flags().Float64Var(&opts.RateLimit, "rate", 0, "maximum number of objects copied per second, unlimited by default")
+	migrateCmd.Flags().BoolVar(&opts.CatchUp, "catch-up", false, "copy again the medias updated and the files missing or modified since the last pass, e.g. once the writes are stopped")
+	migrateCmd.Flags().StringVar(&opts.CheckpointFile, "checkpoint", "migration_checkpoint.json", "file recording the progress, an interrupted migration resumes from it")
+	migrateCmd.Flags().BoolVar(&background, "background", false, "run the migration as a task of the server scheduler")
+	return migrateCmd
+}
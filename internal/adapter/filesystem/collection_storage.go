@@ -0,0 +1,127 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v2"
+)
+
+type CollectionStorage struct {
+	filename string
+	mu       sync.Mutex
+	data     map[string]collection.Collection
+}
+
+func NewCollectionStorage() *CollectionStorage {
+	return &CollectionStorage{
+		filename: "collections.yml",
+	}
+}
+
+func (s *CollectionStorage) GetAll() ([]collection.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	collections := []collection.Collection{}
+	for _, c := range s.data {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].CreatedAt.Before(collections[j].CreatedAt)
+	})
+	return collections, nil
+}
+
+func (s *CollectionStorage) Get(id string) (*collection.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if val, ok := s.data[id]; ok {
+		return &val, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+}
+
+func (s *CollectionStorage) GetByMedia(path string) ([]collection.Collection, error) {
+	collections, err := s.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(collections, func(c collection.Collection) bool {
+		return !c.Refers(path)
+	}), nil
+}
+
+func (s *CollectionStorage) Update(id string, fn func(c *collection.Collection) error) (*collection.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	c, ok := s.data[id]
+	if !ok {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+	}
+	c.Medias = slices.Clone(c.Medias)
+	if err := fn(&c); err != nil {
+		return nil, err
+	}
+	s.data[c.Id] = c
+	return &c, s.save()
+}
+
+func (s *CollectionStorage) Save(c collection.Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.data[c.Id] = c
+	return s.save()
+}
+
+func (s *CollectionStorage) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	delete(s.data, id)
+	return s.save()
+}
+
+func (s *CollectionStorage) load() error {
+	if s.data != nil {
+		return nil
+	}
+	data := map[string]collection.Collection{}
+	body, err := os.ReadFile(s.filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(body, &data); err != nil {
+			return err
+		}
+	}
+	s.data = data
+	return nil
+}
+
+func (s *CollectionStorage) save() error {
+	yamlData, err := yaml.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filename, yamlData, 0644)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
@@ -45,16 +46,18 @@ func detectContentType(f *os.File) (string, error) {
 	// 512 bytes are used.
 	buf := make([]byte, 512)
 
-	_, err := f.Read(buf)
+	n, err := f.Read(buf)
 
-	if err != nil {
+	if err != nil && err != io.EOF {
 		return "", err
 	}
 
 	// the function that actually does the trick
-	contentType := http.DetectContentType(buf)
+	contentType := http.DetectContentType(buf[:n])
 
-	return contentType, nil
+	// rewind so that the file is read from the start.
+	_, err = f.Seek(0, io.SeekStart)
+	return contentType, err
 }
 
 func (s *FileStorage) Upload(in media.UploadInput) error {
@@ -81,16 +84,18 @@ func (s *FileStorage) Download(p media.Path) (*media.DownloadResult, error) {
 	}
 	contentType, err := detectContentType(file)
 	if err != nil {
+		file.Close()
 		return nil, err
 	}
 	stat, err := file.Stat()
 	if err != nil {
+		file.Close()
 		return nil, err
 	}
 
 	return &media.DownloadResult{
 		Path:          p,
-		Body:          io.NopCloser(file),
+		Body:          file,
 		ContentType:   contentType,
 		ContentLength: stat.Size(),
 	}, nil
@@ -157,6 +162,48 @@ func (s *FileStorage) GetMultiple(p media.Path) ([]media.FileInfo, error) {
 	return files, nil
 }
 
+func (s *FileStorage) Walk(p media.Path, fn func(media.FileInfo) error) error {
+	err := s.walk(p.ToString(), fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// walk visits the entries of each directory sorted as if the directories
+// names ended with a slash, so that the paths are in lexical order.
+func (s *FileStorage) walk(dir string, fn func(media.FileInfo) error) error {
+	entries, err := os.ReadDir(path.JoinPath(s.MountDir, dir))
+	if err != nil {
+		return err
+	}
+	key := func(e os.DirEntry) string {
+		if e.IsDir() {
+			return e.Name() + "/"
+		}
+		return e.Name()
+	}
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i]) < key(entries[j]) })
+
+	for _, entry := range entries {
+		p := path.JoinPath(dir, entry.Name())
+		if entry.IsDir() {
+			if err := s.walk(p, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := fn(media.FileInfo{Path: media.NewPath(p), ContentLength: int(info.Size()), ModifiedAt: info.ModTime()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *FileStorage) Move(src, dst media.Path) error {
 	err := os.MkdirAll(path.JoinPath(s.MountDir, dst.Path), 0777)
 	if err != nil {
@@ -166,12 +213,19 @@ func (s *FileStorage) Move(src, dst media.Path) error {
 }
 
 func (s *FileStorage) Copy(src, dst media.Path) error {
-	source, err := os.Open(path.JoinPath(s.MountDir, src.ToString()+"/"+src.Filename()))
+	source, err := os.Open(path.JoinPath(s.MountDir, src.ToString()))
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeMediaNotFound}
+		}
 		return err
 	}
 	defer source.Close()
 
+	err = os.MkdirAll(path.JoinPath(s.MountDir, dst.Dir()), 0777)
+	if err != nil {
+		return err
+	}
 	destination, err := os.Create(path.JoinPath(s.MountDir, dst.ToString()))
 	if err != nil {
 		return err
@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"gopkg.in/yaml.v2"
+)
+
+// folderSettings stores the schema as a string, YAML maps can't be turned
+// back into JSON.
+type folderSettings struct {
+	MetadataSchema string             `yaml:"metadata_schema,omitempty"`
+	Versioning     *folder.Versioning `yaml:"versioning,omitempty"`
+	CreatedAt      time.Time          `yaml:"created_at"`
+	UpdatedAt      time.Time          `yaml:"updated_at"`
+}
+
+type FolderStorage struct {
+	filename string
+	mu       sync.Mutex
+	data     map[string]folderSettings
+}
+
+func NewFolderStorage() *FolderStorage {
+	return &FolderStorage{
+		filename: "folders.yml",
+	}
+}
+
+func (s *FolderStorage) GetAll() ([]folder.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	folders := []folder.Settings{}
+	for p, f := range s.data {
+		folders = append(folders, toFolderSettings(p, f))
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].Path < folders[j].Path
+	})
+	return folders, nil
+}
+
+func (s *FolderStorage) Get(path string) (*folder.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if val, ok := s.data[path]; ok {
+		settings := toFolderSettings(path, val)
+		return &settings, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeFolderNotFound)
+}
+
+func (s *FolderStorage) Save(settings folder.Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.data[settings.Path] = folderSettings{
+		MetadataSchema: string(settings.MetadataSchema),
+		Versioning:     settings.Versioning,
+		CreatedAt:      settings.CreatedAt,
+		UpdatedAt:      settings.UpdatedAt,
+	}
+	return s.save()
+}
+
+func (s *FolderStorage) Delete(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	delete(s.data, path)
+	return s.save()
+}
+
+func toFolderSettings(path string, f folderSettings) folder.Settings {
+	settings := folder.Settings{
+		Path:       path,
+		Versioning: f.Versioning,
+		CreatedAt:  f.CreatedAt,
+		UpdatedAt:  f.UpdatedAt,
+	}
+	if f.MetadataSchema != "" {
+		settings.MetadataSchema = json.RawMessage(f.MetadataSchema)
+	}
+	return settings
+}
+
+func (s *FolderStorage) load() error {
+	if s.data != nil {
+		return nil
+	}
+	data := map[string]folderSettings{}
+	body, err := os.ReadFile(s.filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(body, &data); err != nil {
+			return err
+		}
+	}
+	if data == nil {
+		data = map[string]folderSettings{}
+	}
+	s.data = data
+	return nil
+}
+
+func (s *FolderStorage) save() error {
+	yamlData, err := yaml.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filename, yamlData, 0644)
+}
@@ -0,0 +1,101 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"go.etcd.io/bbolt"
+)
+
+const defaultJournalStorageFilename = "journal.db"
+
+var operationsBucket = []byte("operations")
+
+type JournalStorage struct {
+	db *bbolt.DB
+}
+
+func NewJournalStorage(filename string) *JournalStorage {
+	if filename == "" {
+		filename = defaultJournalStorageFilename
+	}
+	db, err := bbolt.Open(filename, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to open journal storage, %v", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(operationsBucket)
+		return err
+	})
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to init journal storage, %v", err)
+	}
+	return &JournalStorage{
+		db: db,
+	}
+}
+
+// OpenJournalStorageReadOnly opens the journal to list its operations, it
+// fails right away when another process, like a running server, holds it.
+func OpenJournalStorageReadOnly(filename string) (*JournalStorage, error) {
+	if filename == "" {
+		filename = defaultJournalStorageFilename
+	}
+	// bbolt would create the file.
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(filename, 0600, &bbolt.Options{ReadOnly: true, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		return nil, err
+	}
+	return &JournalStorage{
+		db: db,
+	}, nil
+}
+
+func (s *JournalStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *JournalStorage) Save(op *journal.Operation) error {
+	opJSON, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(operationsBucket).Put([]byte(op.Id.String()), opJSON)
+	})
+}
+
+func (s *JournalStorage) Delete(id uuid.UUID) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(operationsBucket).Delete([]byte(id.String()))
+	})
+}
+
+func (s *JournalStorage) GetAll() ([]journal.Operation, error) {
+	ops := []journal.Operation{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(operationsBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var op journal.Operation
+			if err := json.Unmarshal(v, &op); err != nil {
+				return &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+			}
+			ops = append(ops, op)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
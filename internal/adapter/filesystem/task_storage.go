@@ -0,0 +1,293 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	defaultTaskStorageFilename = "tasks.db"
+	taskRetention              = 7 * 24 * time.Hour
+	// taskPruneInterval spaces out the scans of the tasks pruning the
+	// expired ones.
+	taskPruneInterval = time.Hour
+)
+
+var (
+	tasksBucket         = []byte("tasks")
+	recurringJobsBucket = []byte("recurring_jobs")
+	leaderBucket        = []byte("scheduler_leader")
+	leaderKey           = []byte("leader")
+)
+
+type leaderLease struct {
+	InstanceId string    `json:"instance_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func queueBucket(priority scheduler.TaskPriority) []byte {
+	switch priority {
+	case scheduler.HighPriority:
+		return []byte("task_queue_high")
+	case scheduler.LowPriority:
+		return []byte("task_queue_low")
+	default:
+		return []byte("task_queue")
+	}
+}
+
+type TaskStorage struct {
+	db       *bbolt.DB
+	mu       sync.Mutex
+	wake     chan struct{}
+	prunedAt time.Time
+}
+
+func NewTaskStorage(filename string) *TaskStorage {
+	if filename == "" {
+		filename = defaultTaskStorageFilename
+	}
+	db, err := bbolt.Open(filename, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to open task storage, %v", err)
+	}
+
+	s := &TaskStorage{
+		db:   db,
+		wake: make(chan struct{}),
+	}
+	if err := s.init(); err != nil {
+		mindiaerr.ExitErrorf("unable to init task storage, %v", err)
+	}
+	return s
+}
+
+func (s *TaskStorage) init() error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		buckets := [][]byte{tasksBucket, recurringJobsBucket, leaderBucket}
+		for _, p := range scheduler.Priorities {
+			buckets = append(buckets, queueBucket(p))
+		}
+		for _, b := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		s.prunedAt = time.Now()
+		return pruneTasks(tx.Bucket(tasksBucket), s.prunedAt.Add(-taskRetention))
+	})
+}
+
+func pruneTasks(b *bbolt.Bucket, before time.Time) error {
+	expired := [][]byte{}
+	err := b.ForEach(func(k, v []byte) error {
+		var t scheduler.Task
+		if err := json.Unmarshal(v, &t); err != nil {
+			return err
+		}
+		if t.Status.IsTerminal() && t.FinishedAt.Before(before) {
+			expired = append(expired, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TaskStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *TaskStorage) EnqueueTask(task *scheduler.Task) error {
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(queueBucket(task.Priority))
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := b.Put(key, taskJSON); err != nil {
+			return err
+		}
+		if err := tx.Bucket(tasksBucket).Put([]byte(task.Id.String()), taskJSON); err != nil {
+			return err
+		}
+		// Expired tasks are cleaned up as new ones come in.
+		if now := time.Now(); now.Sub(s.prunedAt) >= taskPruneInterval {
+			s.prunedAt = now
+			return pruneTasks(tx.Bucket(tasksBucket), now.Add(-taskRetention))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	close(s.wake)
+	s.wake = make(chan struct{})
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *TaskStorage) DequeueTask(timeout time.Duration) (*scheduler.Task, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		// The wake channel must be captured before popping, otherwise a task
+		// enqueued in between would not wake us up.
+		s.mu.Lock()
+		wake := s.wake
+		s.mu.Unlock()
+
+		task, err := s.pop()
+		if err != nil || task != nil {
+			return task, err
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+}
+
+func (s *TaskStorage) pop() (*scheduler.Task, error) {
+	var task *scheduler.Task
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for _, p := range scheduler.Priorities {
+			b := tx.Bucket(queueBucket(p))
+			k, v := b.Cursor().First()
+			if k == nil {
+				continue
+			}
+			var t scheduler.Task
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			task = &t
+			return b.Delete(k)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *TaskStorage) SaveTask(task *scheduler.Task) error {
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(tasksBucket).Put([]byte(task.Id.String()), taskJSON)
+	})
+}
+
+func (s *TaskStorage) GetTask(id uuid.UUID) (*scheduler.Task, error) {
+	var task *scheduler.Task
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(tasksBucket).Get([]byte(id.String()))
+		if v == nil {
+			return mindiaerr.New(mindiaerr.ErrCodeTaskNotFound)
+		}
+		var t scheduler.Task
+		if err := json.Unmarshal(v, &t); err != nil {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+		}
+		task = &t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *TaskStorage) SaveRecurringJob(job *scheduler.RecurringJob) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(recurringJobsBucket).Put([]byte(job.Name), jobJSON)
+	})
+}
+
+func (s *TaskStorage) GetRecurringJobs() (map[string]scheduler.RecurringJob, error) {
+	jobs := map[string]scheduler.RecurringJob{}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(recurringJobsBucket).ForEach(func(k, v []byte) error {
+			var job scheduler.RecurringJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			jobs[string(k)] = job
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *TaskStorage) AcquireLeadership(instanceId string, ttl time.Duration) (bool, error) {
+	acquired := false
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(leaderBucket)
+		now := time.Now()
+
+		if v := b.Get(leaderKey); v != nil {
+			var lease leaderLease
+			if err := json.Unmarshal(v, &lease); err != nil {
+				return err
+			}
+			if lease.InstanceId != instanceId && now.Before(lease.ExpiresAt) {
+				return nil
+			}
+		}
+
+		leaseJSON, err := json.Marshal(leaderLease{
+			InstanceId: instanceId,
+			ExpiresAt:  now.Add(ttl),
+		})
+		if err != nil {
+			return err
+		}
+		acquired = true
+		return b.Put(leaderKey, leaseJSON)
+	})
+	if err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
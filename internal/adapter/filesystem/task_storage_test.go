@@ -0,0 +1,111 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+)
+
+func TestTaskStoragePriorities(t *testing.T) {
+	s := NewTaskStorage(filepath.Join(t.TempDir(), "tasks.db"))
+	defer s.Close()
+
+	low := scheduler.NewTask("low", nil)
+	low.Priority = scheduler.LowPriority
+	normal := scheduler.NewTask("normal", nil)
+	high := scheduler.NewTask("high", nil)
+	high.Priority = scheduler.HighPriority
+
+	for _, task := range []*scheduler.Task{&low, &normal, &high} {
+		if err := s.EnqueueTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"high", "normal", "low"} {
+		task, err := s.DequeueTask(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task == nil {
+			t.Fatalf("got no task, wanted %q", want)
+		}
+		if task.Name != want {
+			t.Errorf("got %q, wanted %q", task.Name, want)
+		}
+	}
+
+	task, err := s.DequeueTask(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task != nil {
+		t.Errorf("got %q, wanted an empty queue", task.Name)
+	}
+}
+
+func TestTaskStorageBlocksUntilEnqueue(t *testing.T) {
+	s := NewTaskStorage(filepath.Join(t.TempDir(), "tasks.db"))
+	defer s.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		task := scheduler.NewTask("delayed", nil)
+		_ = s.EnqueueTask(&task)
+	}()
+
+	task, err := s.DequeueTask(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task == nil || task.Name != "delayed" {
+		t.Errorf("should have received the delayed task")
+	}
+}
+
+func TestTaskStorageIsDurable(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tasks.db")
+
+	s := NewTaskStorage(filename)
+	task := scheduler.NewTask("durable", nil)
+	if err := s.EnqueueTask(&task); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = NewTaskStorage(filename)
+	defer s.Close()
+	got, err := s.DequeueTask(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Id != task.Id {
+		t.Errorf("should have kept the task across restarts")
+	}
+}
+
+func TestTaskStoragePrunesOnEnqueue(t *testing.T) {
+	s := NewTaskStorage(filepath.Join(t.TempDir(), "tasks.db"))
+	defer s.Close()
+
+	finished := scheduler.NewTask("finished", nil)
+	finished.Status = scheduler.Finished
+	finished.FinishedAt = time.Now().Add(-taskRetention - time.Hour)
+	if err := s.SaveTask(&finished); err != nil {
+		t.Fatal(err)
+	}
+	s.prunedAt = time.Now().Add(-taskPruneInterval)
+
+	task := scheduler.NewTask("new", nil)
+	if err := s.EnqueueTask(&task); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetTask(finished.Id); err == nil && got != nil {
+		t.Errorf("expired task should be pruned, got %+v", got)
+	}
+	if got, err := s.GetTask(task.Id); err != nil || got == nil {
+		t.Errorf("enqueued task should be kept, got %+v %v", got, err)
+	}
+}
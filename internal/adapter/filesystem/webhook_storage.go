@@ -0,0 +1,129 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/webhook"
+	"gopkg.in/yaml.v2"
+)
+
+type webhookFile struct {
+	Webhooks   webhook.WebhookMap            `yaml:"webhooks"`
+	Deliveries map[string][]webhook.Delivery `yaml:"deliveries"`
+}
+
+type WebhookStorage struct {
+	filename string
+	mu       sync.Mutex
+	data     *webhookFile
+}
+
+func NewWebhookStorage() *WebhookStorage {
+	return &WebhookStorage{
+		filename: "webhooks.yml",
+	}
+}
+
+func (s *WebhookStorage) GetAll() (webhook.WebhookMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	webhooks := webhook.WebhookMap{}
+	for id, w := range s.data.Webhooks {
+		webhooks[id] = w
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookStorage) Get(id string) (*webhook.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if val, ok := s.data.Webhooks[id]; ok {
+		return &val, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeWebhookNotFound)
+}
+
+func (s *WebhookStorage) Save(w webhook.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.data.Webhooks[w.Id] = w
+	return s.save()
+}
+
+func (s *WebhookStorage) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	delete(s.data.Webhooks, id)
+	delete(s.data.Deliveries, id)
+	return s.save()
+}
+
+func (s *WebhookStorage) SaveDelivery(delivery webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	deliveries := append([]webhook.Delivery{delivery}, s.data.Deliveries[delivery.WebhookId]...)
+	if len(deliveries) > webhook.MaxDeliveries {
+		deliveries = deliveries[:webhook.MaxDeliveries]
+	}
+	s.data.Deliveries[delivery.WebhookId] = deliveries
+	return s.save()
+}
+
+func (s *WebhookStorage) GetDeliveries(webhookId string) ([]webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return append([]webhook.Delivery{}, s.data.Deliveries[webhookId]...), nil
+}
+
+func (s *WebhookStorage) load() error {
+	if s.data != nil {
+		return nil
+	}
+	data := webhookFile{}
+	body, err := os.ReadFile(s.filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(body, &data); err != nil {
+			return err
+		}
+	}
+	if data.Webhooks == nil {
+		data.Webhooks = webhook.WebhookMap{}
+	}
+	if data.Deliveries == nil {
+		data.Deliveries = map[string][]webhook.Delivery{}
+	}
+	s.data = &data
+	return nil
+}
+
+func (s *WebhookStorage) save() error {
+	yamlData, err := yaml.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filename, yamlData, 0644)
+}
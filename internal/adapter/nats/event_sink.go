@@ -0,0 +1,53 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/nats-io/nats.go"
+)
+
+const defaultEventsSubject = "mindia.events"
+
+// EventSink publishes events on the "<subject>.<event type>" subjects.
+type EventSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewEventSink(url string, subject string) *EventSink {
+	if subject == "" {
+		subject = defaultEventsSubject
+	}
+	conn, err := nats.Connect(url,
+		nats.Name("mindia"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+	)
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to connect to nats, %v", err)
+	}
+	return &EventSink{
+		conn,
+		subject,
+	}
+}
+
+func (s *EventSink) Name() string {
+	return "nats"
+}
+
+func (s *EventSink) Publish(e event.Event) error {
+	eventJSON, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.conn.Publish(fmt.Sprintf("%s.%s", s.subject, e.Type), eventJSON)
+}
+
+func (s *EventSink) Close() {
+	s.conn.Close()
+}
@@ -0,0 +1,54 @@
+package nats
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventSinkPublishes(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	srv := natsserver.RunServer(&opts)
+	defer srv.Shutdown()
+
+	sub, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	msgs := make(chan *nats.Msg, 1)
+	if _, err := sub.ChanSubscribe("mindia.events.>", msgs); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := NewEventSink(srv.ClientURL(), "")
+	defer sink.Close()
+	if err := sink.Publish(event.New(event.CacheCleared, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-msgs:
+		if msg.Subject != "mindia.events.cache.cleared" {
+			t.Errorf("got subject %s, wanted mindia.events.cache.cleared", msg.Subject)
+		}
+		var e event.Event
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != event.CacheCleared {
+			t.Errorf("got event type %s, wanted %s", e.Type, event.CacheCleared)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("should have received the event")
+	}
+}
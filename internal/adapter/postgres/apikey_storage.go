@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jeremybastin1207/mindia-core/internal/apikey"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+)
+
+type ApiKeyStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewApiKeyStorage(pool *pgxpool.Pool) *ApiKeyStorage {
+	return &ApiKeyStorage{
+		pool: pool,
+	}
+}
+
+func (s *ApiKeyStorage) GetByName(name string) (*apikey.ApiKey, error) {
+	return s.get(`SELECT data FROM api_keys WHERE name = $1 LIMIT 1`, name)
+}
+
+func (s *ApiKeyStorage) GetByKey(key string) (*apikey.ApiKey, error) {
+	return s.get(`SELECT data FROM api_keys WHERE key = $1`, key)
+}
+
+func (s *ApiKeyStorage) get(query string, arg string) (*apikey.ApiKey, error) {
+	var data []byte
+	err := s.pool.QueryRow(context.Background(), query, arg).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, &mindiaerr.Error{
+			ErrCode: mindiaerr.ErrCodeApiKeyNotFound,
+			Msg:     fmt.Errorf("key: %v", arg),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a apikey.ApiKey
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *ApiKeyStorage) GetAll() (apikey.ApiKeyMap, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT data FROM api_keys`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apikeys := apikey.ApiKeyMap{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var a apikey.ApiKey
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, err
+		}
+		apikeys[a.Key] = a
+	}
+	return apikeys, rows.Err()
+}
+
+func (s *ApiKeyStorage) Save(a apikey.ApiKey) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(context.Background(), `INSERT INTO api_keys (key, name, data) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET name = EXCLUDED.name, data = EXCLUDED.data`, a.Key, a.Name, data)
+	return err
+}
+
+func (s *ApiKeyStorage) Delete(key string) error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM api_keys WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+)
+
+type CollectionStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewCollectionStorage(pool *pgxpool.Pool) *CollectionStorage {
+	return &CollectionStorage{
+		pool: pool,
+	}
+}
+
+const selectCollections = `SELECT c.id, c.name, c.description, c.cover, c.created_at, c.updated_at,
+	COALESCE(array_agg(cm.path ORDER BY cm.position) FILTER (WHERE cm.path IS NOT NULL), '{}')
+	FROM collections c LEFT JOIN collection_medias cm ON cm.collection_id = c.id`
+
+func scanCollection(row pgx.Row) (*collection.Collection, error) {
+	var c collection.Collection
+	err := row.Scan(&c.Id, &c.Name, &c.Description, &c.Cover, &c.CreatedAt, &c.UpdatedAt, &c.Medias)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *CollectionStorage) GetAll() ([]collection.Collection, error) {
+	return s.query(selectCollections + ` GROUP BY c.id ORDER BY c.created_at`)
+}
+
+func (s *CollectionStorage) GetByMedia(path string) ([]collection.Collection, error) {
+	return s.query(selectCollections+` WHERE c.cover = $1
+		OR c.id IN (SELECT collection_id FROM collection_medias WHERE path = $1)
+		GROUP BY c.id ORDER BY c.created_at`, path)
+}
+
+func (s *CollectionStorage) query(sql string, args ...interface{}) ([]collection.Collection, error) {
+	rows, err := s.pool.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []collection.Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, *c)
+	}
+	return collections, rows.Err()
+}
+
+func (s *CollectionStorage) Get(id string) (*collection.Collection, error) {
+	c, err := scanCollection(s.pool.QueryRow(context.Background(), selectCollections+` WHERE c.id = $1 GROUP BY c.id`, id))
+	if err == pgx.ErrNoRows {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+	}
+	return c, err
+}
+
+// Save replaces the collection with its members in a transaction.
+func (s *CollectionStorage) Save(c collection.Collection) error {
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := saveCollection(ctx, tx, c); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update locks the row of the collection until the updated one is saved.
+func (s *CollectionStorage) Update(id string, fn func(c *collection.Collection) error) (*collection.Collection, error) {
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM collections WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return nil, err
+	}
+	c, err := scanCollection(tx.QueryRow(ctx, selectCollections+` WHERE c.id = $1 GROUP BY c.id`, id))
+	if err == pgx.ErrNoRows {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(c); err != nil {
+		return nil, err
+	}
+	if err := saveCollection(ctx, tx, *c); err != nil {
+		return nil, err
+	}
+	return c, tx.Commit(ctx)
+}
+
+func saveCollection(ctx context.Context, tx pgx.Tx, c collection.Collection) error {
+	_, err := tx.Exec(ctx, `INSERT INTO collections (id, name, description, cover, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description,
+		cover = EXCLUDED.cover, updated_at = EXCLUDED.updated_at`,
+		c.Id, c.Name, c.Description, c.Cover, c.CreatedAt, c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM collection_medias WHERE collection_id = $1`, c.Id); err != nil {
+		return err
+	}
+	rows := [][]interface{}{}
+	for i, p := range c.Medias {
+		rows = append(rows, []interface{}{c.Id, i, p})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"collection_medias"}, []string{"collection_id", "position", "path"}, pgx.CopyFromRows(rows))
+	return err
+}
+
+func (s *CollectionStorage) Delete(id string) error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM collections WHERE id = $1`, id)
+	return err
+}
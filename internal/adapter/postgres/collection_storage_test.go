@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+)
+
+func TestCollectionStorage(t *testing.T) {
+	s := NewCollectionStorage(newTestPool(t))
+
+	c := collection.Collection{
+		Id:        "summer",
+		Name:      "Summer",
+		Medias:    []string{"/b.jpg", "/a.jpg"},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.Save(c); err != nil {
+		t.Fatal(err)
+	}
+	c.Medias = []string{"/a.jpg", "/c.jpg", "/b.jpg"}
+	c.Cover = "/c.jpg"
+	if err := s.Save(c); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get("summer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Medias, c.Medias) || got.Cover != c.Cover {
+		t.Errorf("got %+v, wanted %+v", got, c)
+	}
+
+	empty := collection.Collection{Id: "empty", Name: "Empty", Medias: []string{}, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := s.Save(empty); err != nil {
+		t.Fatal(err)
+	}
+	all, err := s.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Id != "summer" || len(all[1].Medias) != 0 {
+		t.Errorf("unexpected collections %+v", all)
+	}
+	byMedia, err := s.GetByMedia("/c.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byMedia) != 1 || byMedia[0].Id != "summer" {
+		t.Errorf("unexpected collections of the media %+v", byMedia)
+	}
+
+	// Concurrent updates are serialized, none is lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			_, err := s.Update("empty", func(c *collection.Collection) error {
+				c.Medias = append(c.Medias, p)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(fmt.Sprintf("/%d.jpg", i))
+	}
+	wg.Wait()
+	if got, _ := s.Get("empty"); got == nil || len(got.Medias) != 5 {
+		t.Errorf("concurrent updates should all be kept, got %+v", got)
+	}
+
+	if err := s.Delete("summer"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("summer"); err == nil {
+		t.Errorf("deleted collection should not be found")
+	}
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+)
+
+type JournalStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewJournalStorage(pool *pgxpool.Pool) *JournalStorage {
+	return &JournalStorage{
+		pool: pool,
+	}
+}
+
+func (s *JournalStorage) Save(op *journal.Operation) error {
+	opJSON, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(context.Background(), `INSERT INTO journal_operations (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`, op.Id, opJSON)
+	return err
+}
+
+func (s *JournalStorage) Delete(id uuid.UUID) error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM journal_operations WHERE id = $1`, id)
+	return err
+}
+
+func (s *JournalStorage) GetAll() ([]journal.Operation, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT data FROM journal_operations ORDER BY data->>'created_at'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []journal.Operation{}
+	for rows.Next() {
+		var opJSON []byte
+		if err := rows.Scan(&opJSON); err != nil {
+			return nil, err
+		}
+		var op journal.Operation
+		if err := json.Unmarshal(opJSON, &op); err != nil {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
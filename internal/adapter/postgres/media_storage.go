@@ -0,0 +1,252 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+const (
+	maxSearchLimit  = 100
+	maxFacetResults = 20
+)
+
+type MediaStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewMediaStorage(pool *pgxpool.Pool) *MediaStorage {
+	return &MediaStorage{
+		pool: pool,
+	}
+}
+
+func (s *MediaStorage) Get(path media.Path) (*media.Media, error) {
+	var data []byte
+	err := s.pool.QueryRow(context.Background(), `SELECT data FROM medias WHERE path = $1`, path.ToString()).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeMediaNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeMedia(data)
+}
+
+func decodeMedia(data []byte) (*media.Media, error) {
+	var m media.Media
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+	}
+	return &m, nil
+}
+
+func (s *MediaStorage) GetMultiple(q media.ListQuery) (*media.ListResult, error) {
+	cursor, err := media.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	where := whereClause{}
+	where.path(q.Path)
+	where.tagFilter(q.TagFilter)
+
+	medias, next, total, err := s.list(where, q.Sort, cursor, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := media.ListResult{
+		Medias:     medias,
+		Total:      total,
+		NextCursor: next,
+	}
+	return &result, nil
+}
+
+func (s *MediaStorage) Search(q media.SearchQuery) (*media.SearchResult, error) {
+	cursor, err := media.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	where := whereClause{}
+	where.path(q.Path)
+	if q.Condition != nil {
+		if err := where.condition(q.Condition); err != nil {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+		}
+	}
+
+	medias, next, total, err := s.list(where, q.Sort, cursor, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := media.SearchResult{
+		Medias:     medias,
+		Total:      total,
+		NextCursor: next,
+	}
+
+	if len(q.Facets) > 0 {
+		result.Facets = map[string][]media.FacetValue{}
+		for _, f := range q.Facets {
+			values, err := s.facet(where, f)
+			if err != nil {
+				return nil, err
+			}
+			result.Facets[f] = values
+		}
+	}
+	return &result, nil
+}
+
+// list returns the page of the medias matching the clause after the cursor,
+// the cursor of the next page and the number of medias matching the clause.
+func (s *MediaStorage) list(where whereClause, sort []media.SortKey, cursor *media.Cursor, limit int) ([]media.Media, string, int, error) {
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	orderBy, err := toOrderBy(sort)
+	if err != nil {
+		return nil, "", 0, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+
+	ctx := context.Background()
+	var total int
+	err = s.pool.QueryRow(ctx, rebind(`SELECT COUNT(*) FROM medias m WHERE `+where.sql()), where.args...).Scan(&total)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	where.after(sort, cursor)
+	args := append(append([]interface{}{}, where.args...), limit+1)
+	rows, err := s.pool.Query(ctx, rebind(`SELECT m.data FROM medias m WHERE `+where.sql()+` ORDER BY `+orderBy+` LIMIT ?`), args...)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer rows.Close()
+
+	medias := []media.Media{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, "", 0, err
+		}
+		m, err := decodeMedia(data)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		medias = append(medias, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", 0, err
+	}
+	medias, next := media.Page(medias, limit)
+	return medias, next, total, nil
+}
+
+// facet counts the medias matching the clause by value of the field.
+func (s *MediaStorage) facet(where whereClause, field string) ([]media.FacetValue, error) {
+	var query string
+	switch field {
+	case media.FieldContentType:
+		query = `SELECT m.content_type, COUNT(*) FROM medias m WHERE ` + where.sql() + ` GROUP BY 1`
+	case media.FieldCameraMake:
+		query = `SELECT m.camera_make, COUNT(*) FROM medias m WHERE m.camera_make != '' AND ` + where.sql() + ` GROUP BY 1`
+	case media.FieldTag:
+		query = `SELECT t.value, COUNT(DISTINCT m.path) FROM medias m JOIN media_tags t ON t.path = m.path WHERE ` + where.sql() + ` GROUP BY 1`
+	default:
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("facet %s not supported", field)}
+	}
+	rows, err := s.pool.Query(context.Background(), rebind(query+` ORDER BY 2 DESC, 1 LIMIT `+strconv.Itoa(maxFacetResults)), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []media.FacetValue{}
+	for rows.Next() {
+		var v media.FacetValue
+		var count int64
+		if err := rows.Scan(&v.Value, &count); err != nil {
+			return nil, err
+		}
+		v.Count = int(count)
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func (s *MediaStorage) Save(m *media.Media) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	width, _ := strconv.Atoi(m.EmbeddedMetadata[media.WidthMetadataKey])
+	height, _ := strconv.Atoi(m.EmbeddedMetadata[media.HeightMetadataKey])
+	hasGps := m.EmbeddedMetadata[media.LatitudeMetadataKey] != ""
+	path := m.Path.ToString()
+
+	tagValues := []string{}
+	for _, t := range m.Tags {
+		tagValues = append(tagValues, media.NormalizeTagValue(t.Value))
+	}
+	search := strings.Join([]string{searchWords(path), searchWords(strings.Join(tagValues, " ")), searchWords(m.CustomMetadata.Text())}, " ")
+
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The tags and metadata rows are deleted in cascade.
+	if _, err := tx.Exec(ctx, `DELETE FROM medias WHERE path = $1`, path); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO medias (path, dir, name, content_type, content_length, width, height, camera_make, has_gps, created_at, updated_at, search, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, to_tsvector('simple', $12), $13)`,
+		path,
+		folder.CleanPath(m.Path.Dir()),
+		strings.ToLower(m.Path.Filename()),
+		strings.ToLower(m.ContentType),
+		m.ContentLength,
+		width,
+		height,
+		m.EmbeddedMetadata[media.CameraMakeMetadataKey],
+		hasGps,
+		m.CreatedAt.Unix(),
+		m.UpdatedAt.Unix(),
+		search,
+		data,
+	)
+	if err != nil {
+		return err
+	}
+
+	for i, t := range m.Tags {
+		_, err := tx.Exec(ctx, `INSERT INTO media_tags (path, value, confidence, provider) VALUES ($1, $2, $3, $4)`, path, tagValues[i], t.ConfidenceScore, t.Provider)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range m.CustomMetadata.Fields() {
+		key, value, _ := strings.Cut(f, ":")
+		_, err := tx.Exec(ctx, `INSERT INTO media_metadata (path, key, value) VALUES ($1, $2, $3)`, path, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *MediaStorage) Delete(path media.Path) error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM medias WHERE path = $1`, path.ToString())
+	return err
+}
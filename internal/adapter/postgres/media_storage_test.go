@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/media/mediatest"
+)
+
+// newTestPool connects to the database at MINDIA_TEST_POSTGRES_URL, its
+// tables are emptied.
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv("MINDIA_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("MINDIA_TEST_POSTGRES_URL not set")
+	}
+	pool, err := NewPool(url, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	_, err = pool.Exec(context.Background(), `TRUNCATE medias, media_tags, media_metadata, api_keys, named_transformations, tasks, task_queue, recurring_jobs, scheduler_leader, journal_operations, collections, collection_medias`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestMediaStorage(t *testing.T) {
+	mediatest.TestStorer(t, func(t *testing.T) media.Storer {
+		return NewMediaStorage(newTestPool(t))
+	})
+}
+
+func TestRebind(t *testing.T) {
+	got := rebind("a = ? AND b IN (?, ?)")
+	if want := "a = $1 AND b IN ($2, $3)"; got != want {
+		t.Errorf("got %s, wanted %s", got, want)
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	if got := searchWords("/Photos-old/bird.jpg"); got != "photos old bird jpg" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,37 @@
+CREATE TABLE medias (
+	path           TEXT PRIMARY KEY,
+	dir            TEXT NOT NULL,
+	name           TEXT NOT NULL,
+	content_type   TEXT NOT NULL,
+	content_length BIGINT NOT NULL,
+	width          INTEGER NOT NULL DEFAULT 0,
+	height         INTEGER NOT NULL DEFAULT 0,
+	camera_make    TEXT NOT NULL DEFAULT '',
+	has_gps        BOOLEAN NOT NULL DEFAULT false,
+	created_at     BIGINT NOT NULL,
+	updated_at     BIGINT NOT NULL,
+	search         TSVECTOR NOT NULL,
+	data           JSONB NOT NULL
+);
+CREATE INDEX medias_dir ON medias (dir text_pattern_ops);
+CREATE INDEX medias_content_type ON medias (content_type);
+CREATE INDEX medias_created_at ON medias (created_at);
+CREATE INDEX medias_updated_at ON medias (updated_at);
+CREATE INDEX medias_search ON medias USING GIN (search);
+
+CREATE TABLE media_tags (
+	path       TEXT NOT NULL REFERENCES medias (path) ON DELETE CASCADE,
+	value      TEXT NOT NULL,
+	confidence REAL NOT NULL,
+	provider   TEXT NOT NULL
+);
+CREATE INDEX media_tags_path ON media_tags (path);
+CREATE INDEX media_tags_value ON media_tags (value, confidence);
+
+CREATE TABLE media_metadata (
+	path  TEXT NOT NULL REFERENCES medias (path) ON DELETE CASCADE,
+	key   TEXT NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE INDEX media_metadata_path ON media_metadata (path);
+CREATE INDEX media_metadata_key ON media_metadata (key, lower(value));
@@ -0,0 +1,11 @@
+CREATE TABLE api_keys (
+	key  TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	data JSONB NOT NULL
+);
+CREATE INDEX api_keys_name ON api_keys (name);
+
+CREATE TABLE named_transformations (
+	name TEXT PRIMARY KEY,
+	data JSONB NOT NULL
+);
@@ -0,0 +1,25 @@
+CREATE TABLE tasks (
+	id          UUID PRIMARY KEY,
+	enqueued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	data        JSONB NOT NULL
+);
+CREATE INDEX tasks_enqueued_at ON tasks (enqueued_at);
+
+-- The queue holds the tasks as they were enqueued, tasks holds their state.
+CREATE TABLE task_queue (
+	id       BIGSERIAL PRIMARY KEY,
+	priority INTEGER NOT NULL,
+	data     JSONB NOT NULL
+);
+CREATE INDEX task_queue_order ON task_queue (priority DESC, id);
+
+CREATE TABLE recurring_jobs (
+	name TEXT PRIMARY KEY,
+	data JSONB NOT NULL
+);
+
+CREATE TABLE scheduler_leader (
+	id          INTEGER PRIMARY KEY,
+	instance_id TEXT NOT NULL,
+	expires_at  TIMESTAMPTZ NOT NULL
+);
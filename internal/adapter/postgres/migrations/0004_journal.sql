@@ -0,0 +1,4 @@
+CREATE TABLE journal_operations (
+	id   UUID PRIMARY KEY,
+	data JSONB NOT NULL
+);
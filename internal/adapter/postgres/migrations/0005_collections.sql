@@ -0,0 +1,16 @@
+CREATE TABLE collections (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	cover       TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE collection_medias (
+	collection_id TEXT NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+	position      INTEGER NOT NULL,
+	path          TEXT NOT NULL,
+	PRIMARY KEY (collection_id, path)
+);
+CREATE INDEX collection_medias_path ON collection_medias (path);
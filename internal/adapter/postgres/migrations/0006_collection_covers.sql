@@ -0,0 +1 @@
+CREATE INDEX collections_cover ON collections (cover);
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+type NamedTransformationStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewNamedTransformationStorage(pool *pgxpool.Pool) *NamedTransformationStorage {
+	return &NamedTransformationStorage{
+		pool: pool,
+	}
+}
+
+func (s *NamedTransformationStorage) GetAll() (transform.NamedTransformationMap, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT data FROM named_transformations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	namedTransformations := transform.NamedTransformationMap{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var t transform.NamedTransformation
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		namedTransformations[t.Name] = t
+	}
+	return namedTransformations, rows.Err()
+}
+
+func (s *NamedTransformationStorage) Get(name string) (*transform.NamedTransformation, error) {
+	var data []byte
+	err := s.pool.QueryRow(context.Background(), `SELECT data FROM named_transformations WHERE name = $1`, name).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, &mindiaerr.Error{
+			ErrCode: mindiaerr.ErrCodeNamedTransformationNotFound,
+			Msg:     fmt.Errorf("name: %v", name),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t transform.NamedTransformation
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *NamedTransformationStorage) Save(t transform.NamedTransformation) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(context.Background(), `INSERT INTO named_transformations (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`, t.Name, data)
+	return err
+}
+
+func (s *NamedTransformationStorage) Delete(name string) error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM named_transformations WHERE name = $1`, name)
+	return err
+}
+
+func (s *NamedTransformationStorage) DeleteAll() error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM named_transformations`)
+	return err
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+)
+
+const (
+	taskRetention     = 7 * 24 * time.Hour
+	queuePollInterval = 250 * time.Millisecond
+)
+
+type TaskStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewTaskStorage(pool *pgxpool.Pool) *TaskStorage {
+	return &TaskStorage{
+		pool: pool,
+	}
+}
+
+func (s *TaskStorage) EnqueueTask(task *scheduler.Task) error {
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := saveTask(ctx, tx, task.Id, taskJSON); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO task_queue (priority, data) VALUES ($1, $2)`, task.Priority, taskJSON); err != nil {
+		return err
+	}
+	// Expired tasks are cleaned up as new ones come in.
+	if _, err := tx.Exec(ctx, `DELETE FROM tasks WHERE enqueued_at < $1`, time.Now().Add(-taskRetention)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DequeueTask polls the queue, concurrent workers skip the rows locked by
+// each other instead of waiting for them.
+func (s *TaskStorage) DequeueTask(timeout time.Duration) (*scheduler.Task, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		task, err := s.dequeue()
+		if err != nil || task != nil {
+			return task, err
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, nil
+		}
+		if wait > queuePollInterval {
+			wait = queuePollInterval
+		}
+		time.Sleep(wait)
+	}
+}
+
+func (s *TaskStorage) dequeue() (*scheduler.Task, error) {
+	var taskJSON []byte
+	err := s.pool.QueryRow(context.Background(), `DELETE FROM task_queue WHERE id = (
+			SELECT id FROM task_queue ORDER BY priority DESC, id LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING data`).Scan(&taskJSON)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var task scheduler.Task
+	if err := json.Unmarshal(taskJSON, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *TaskStorage) SaveTask(task *scheduler.Task) error {
+	taskJSON, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return saveTask(context.Background(), s.pool, task.Id, taskJSON)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+func saveTask(ctx context.Context, db execer, id uuid.UUID, taskJSON []byte) error {
+	_, err := db.Exec(ctx, `INSERT INTO tasks (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`, id, taskJSON)
+	return err
+}
+
+func (s *TaskStorage) GetTask(id uuid.UUID) (*scheduler.Task, error) {
+	var taskJSON []byte
+	err := s.pool.QueryRow(context.Background(), `SELECT data FROM tasks WHERE id = $1`, id).Scan(&taskJSON)
+	if err == pgx.ErrNoRows {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeTaskNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var task scheduler.Task
+	if err := json.Unmarshal(taskJSON, &task); err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+	}
+	return &task, nil
+}
+
+func (s *TaskStorage) SaveRecurringJob(job *scheduler.RecurringJob) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(context.Background(), `INSERT INTO recurring_jobs (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`, job.Name, jobJSON)
+	return err
+}
+
+func (s *TaskStorage) GetRecurringJobs() (map[string]scheduler.RecurringJob, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT data FROM recurring_jobs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := map[string]scheduler.RecurringJob{}
+	for rows.Next() {
+		var jobJSON []byte
+		if err := rows.Scan(&jobJSON); err != nil {
+			return nil, err
+		}
+		var job scheduler.RecurringJob
+		if err := json.Unmarshal(jobJSON, &job); err != nil {
+			return nil, err
+		}
+		jobs[job.Name] = job
+	}
+	return jobs, rows.Err()
+}
+
+// AcquireLeadership takes the lease when it is free or expired, or renews it
+// when instanceId already holds it.
+func (s *TaskStorage) AcquireLeadership(instanceId string, ttl time.Duration) (bool, error) {
+	tag, err := s.pool.Exec(context.Background(), `INSERT INTO scheduler_leader (id, instance_id, expires_at) VALUES (1, $1, now() + $2::interval)
+		ON CONFLICT (id) DO UPDATE SET instance_id = EXCLUDED.instance_id, expires_at = EXCLUDED.expires_at
+		WHERE scheduler_leader.instance_id = EXCLUDED.instance_id OR scheduler_leader.expires_at < now()`, instanceId, ttl)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
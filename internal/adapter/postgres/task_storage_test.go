@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+)
+
+func TestTaskStorage(t *testing.T) {
+	s := NewTaskStorage(newTestPool(t))
+
+	low := scheduler.NewTask("low", nil)
+	low.Priority = scheduler.LowPriority
+	high := scheduler.NewTask("high", nil)
+	high.Priority = scheduler.HighPriority
+	for _, task := range []*scheduler.Task{&low, &high} {
+		if err := s.EnqueueTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"high", "low"} {
+		task, err := s.DequeueTask(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task == nil || task.Name != want {
+			t.Fatalf("got %v, wanted %s", task, want)
+		}
+	}
+	if task, err := s.DequeueTask(300 * time.Millisecond); err != nil || task != nil {
+		t.Errorf("got %v %v, wanted an empty queue", task, err)
+	}
+
+	low.Status = scheduler.Finished
+	if err := s.SaveTask(&low); err != nil {
+		t.Fatal(err)
+	}
+	task, err := s.GetTask(low.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != scheduler.Finished {
+		t.Errorf("got status %s", task.Status)
+	}
+}
+
+func TestAcquireLeadership(t *testing.T) {
+	s := NewTaskStorage(newTestPool(t))
+
+	cases := []struct {
+		instance string
+		want     bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"a", true},
+	}
+	for _, c := range cases {
+		got, err := s.AcquireLeadership(c.instance, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %v, wanted %v", c.instance, got, c.want)
+		}
+	}
+}
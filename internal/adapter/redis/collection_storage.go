@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"encoding/json"
+	"sort"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+)
+
+const (
+	collectionsKey = "internal:collections"
+	// collectionMediasPrefix prefixes the sets of the ids of the collections
+	// referring to a media.
+	collectionMediasPrefix = "internal:collection_medias:"
+)
+
+type CollectionStorage struct {
+	redisPool *redigo.Pool
+}
+
+func NewCollectionStorage(redisPool *redigo.Pool) *CollectionStorage {
+	s := CollectionStorage{
+		redisPool: redisPool,
+	}
+	if err := s.init(); err != nil {
+		mindiaerr.ExitErrorf("unable to index the collections, %v", err)
+	}
+	return &s
+}
+
+// init adds the collections saved before the sets of the medias existed to
+// them.
+func (s *CollectionStorage) init() error {
+	collections, err := s.GetAll()
+	if err != nil {
+		return err
+	}
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	for _, c := range collections {
+		for _, p := range c.Paths() {
+			conn.Send("SADD", collectionMediasPrefix+p, c.Id)
+		}
+	}
+	_, err = conn.Do("")
+	return err
+}
+
+func (s *CollectionStorage) GetAll() ([]collection.Collection, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	values, err := redigo.ByteSlices(conn.Do("HVALS", collectionsKey))
+	if err != nil {
+		return nil, err
+	}
+	return decodeCollections(values)
+}
+
+func (s *CollectionStorage) GetByMedia(path string) ([]collection.Collection, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	ids, err := redigo.Strings(conn.Do("SMEMBERS", collectionMediasPrefix+path))
+	if err != nil || len(ids) == 0 {
+		return []collection.Collection{}, err
+	}
+	values, err := redigo.ByteSlices(conn.Do("HMGET", redigo.Args{}.Add(collectionsKey).AddFlat(ids)...))
+	if err != nil {
+		return nil, err
+	}
+	return decodeCollections(values)
+}
+
+// decodeCollections decodes the collections oldest first, the missing ones
+// are skipped.
+func decodeCollections(values [][]byte) ([]collection.Collection, error) {
+	collections := []collection.Collection{}
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		var c collection.Collection
+		if err := json.Unmarshal(v, &c); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].CreatedAt.Before(collections[j].CreatedAt)
+	})
+	return collections, nil
+}
+
+func (s *CollectionStorage) Get(id string) (*collection.Collection, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	c, err := getCollection(conn, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+	}
+	return c, nil
+}
+
+func getCollection(conn redigo.Conn, id string) (*collection.Collection, error) {
+	value, err := redigo.Bytes(conn.Do("HGET", collectionsKey, id))
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c collection.Collection
+	if err := json.Unmarshal(value, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *CollectionStorage) Save(c collection.Collection) error {
+	_, err := s.replace(c.Id, func(*collection.Collection) (*collection.Collection, error) {
+		return &c, nil
+	})
+	return err
+}
+
+func (s *CollectionStorage) Update(id string, fn func(c *collection.Collection) error) (*collection.Collection, error) {
+	return s.replace(id, func(c *collection.Collection) (*collection.Collection, error) {
+		if c == nil {
+			return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+		}
+		return c, fn(c)
+	})
+}
+
+func (s *CollectionStorage) Delete(id string) error {
+	_, err := s.replace(id, func(*collection.Collection) (*collection.Collection, error) {
+		return nil, nil
+	})
+	return err
+}
+
+// replace saves the collection fn returns in place of the current one, or
+// deletes it when nil, along with the sets of the medias it refers to. The
+// collections are watched so that fn runs again when another update
+// happened meanwhile.
+func (s *CollectionStorage) replace(id string, fn func(current *collection.Collection) (*collection.Collection, error)) (*collection.Collection, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	for {
+		if _, err := conn.Do("WATCH", collectionsKey); err != nil {
+			return nil, err
+		}
+		current, err := getCollection(conn, id)
+		if err != nil {
+			conn.Do("UNWATCH")
+			return nil, err
+		}
+		var previous []string
+		if current != nil {
+			previous = current.Paths()
+		}
+		c, err := fn(current)
+		var collectionJSON []byte
+		if err == nil && c != nil {
+			collectionJSON, err = json.Marshal(c)
+		}
+		if err != nil {
+			conn.Do("UNWATCH")
+			return nil, err
+		}
+
+		conn.Send("MULTI")
+		for _, p := range previous {
+			conn.Send("SREM", collectionMediasPrefix+p, id)
+		}
+		if c == nil {
+			conn.Send("HDEL", collectionsKey, id)
+		} else {
+			conn.Send("HSET", collectionsKey, id, collectionJSON)
+			for _, p := range c.Paths() {
+				conn.Send("SADD", collectionMediasPrefix+p, id)
+			}
+		}
+		reply, err := conn.Do("EXEC")
+		if err != nil {
+			return nil, err
+		}
+		// A nil reply means the collections changed since WATCH.
+		if reply != nil {
+			return c, nil
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+)
+
+const defaultEventsChannel = "mindia.events"
+
+// EventSink publishes events on the "<channel>.<event type>" pub/sub channels,
+// so that subscribers can PSUBSCRIBE to a subset of them.
+type EventSink struct {
+	redisPool *redigo.Pool
+	channel   string
+}
+
+func NewEventSink(redisPool *redigo.Pool, channel string) *EventSink {
+	if channel == "" {
+		channel = defaultEventsChannel
+	}
+	return &EventSink{
+		redisPool,
+		channel,
+	}
+}
+
+func (s *EventSink) Name() string {
+	return "redis"
+}
+
+func (s *EventSink) Publish(e event.Event) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	eventJSON, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("PUBLISH", fmt.Sprintf("%s.%s", s.channel, e.Type), eventJSON)
+	return err
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+)
+
+func TestEventSinkPublishes(t *testing.T) {
+	srv := miniredis.RunT(t)
+	pool := NewPool(srv.Addr())
+
+	conn := pool.Get()
+	defer conn.Close()
+	psc := redigo.PubSubConn{Conn: conn}
+	if err := psc.PSubscribe("mindia.events.media.*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := psc.Receive().(redigo.Subscription); !ok {
+		t.Fatal("should have confirmed the subscription")
+	}
+
+	sink := NewEventSink(pool, "")
+	if err := sink.Publish(event.New(event.MediaUploaded, map[string]string{"path": "/a.jpg"})); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan redigo.Message, 1)
+	go func() {
+		if msg, ok := psc.Receive().(redigo.Message); ok {
+			done <- msg
+		}
+	}()
+	select {
+	case msg := <-done:
+		if msg.Channel != "mindia.events.media.uploaded" {
+			t.Errorf("got channel %s, wanted mindia.events.media.uploaded", msg.Channel)
+		}
+		var e event.Event
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != event.MediaUploaded {
+			t.Errorf("got event type %s, wanted %s", e.Type, event.MediaUploaded)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("should have received the event")
+	}
+}
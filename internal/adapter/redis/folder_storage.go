@@ -0,0 +1,96 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	redigo "github.com/gomodule/redigo/redis"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/nitishm/go-rejson"
+)
+
+const foldersKey = "internal:configuration:folders"
+
+type FolderStorage struct {
+	rejsonHandler *rejson.Handler
+}
+
+func NewFolderStorage(redisPool *redigo.Pool) *FolderStorage {
+	rejsonHandler := rejson.NewReJSONHandler()
+	rejsonHandler.SetRedigoClient(redisPool.Get())
+
+	s := FolderStorage{
+		rejsonHandler: rejsonHandler,
+	}
+	s.init()
+	return &s
+}
+
+func (s *FolderStorage) init() error {
+	res, err := s.rejsonHandler.JSONGet(foldersKey, ".")
+	if err != nil && err != redigo.ErrNil {
+		return err
+	}
+	if res == nil {
+		_, err := s.rejsonHandler.JSONSet(foldersKey, ".", map[string]folder.Settings{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FolderStorage) getAll() (map[string]folder.Settings, error) {
+	res, err := s.rejsonHandler.JSONGet(foldersKey, ".")
+	if err == redigo.ErrNil || (err == nil && res == nil) {
+		return map[string]folder.Settings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var folders map[string]folder.Settings
+	err = json.Unmarshal(res.([]byte), &folders)
+	if err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (s *FolderStorage) GetAll() ([]folder.Settings, error) {
+	folders, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	settings := []folder.Settings{}
+	for _, f := range folders {
+		settings = append(settings, f)
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Path < settings[j].Path
+	})
+	return settings, nil
+}
+
+func (s *FolderStorage) Get(path string) (*folder.Settings, error) {
+	folders, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := folders[path]; ok {
+		return &val, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeFolderNotFound)
+}
+
+func (s *FolderStorage) Save(settings folder.Settings) error {
+	s.init()
+	_, err := s.rejsonHandler.JSONSet(foldersKey, fmt.Sprintf("[\"%s\"]", settings.Path), settings)
+	return err
+}
+
+func (s *FolderStorage) Delete(path string) error {
+	_, err := s.rejsonHandler.JSONDel(foldersKey, fmt.Sprintf("[\"%s\"]", path))
+	return err
+}
@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"encoding/json"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+)
+
+const journalKey = "internal:journal"
+
+type JournalStorage struct {
+	redisPool *redigo.Pool
+}
+
+func NewJournalStorage(redisPool *redigo.Pool) *JournalStorage {
+	return &JournalStorage{
+		redisPool: redisPool,
+	}
+}
+
+func (s *JournalStorage) Save(op *journal.Operation) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	opJSON, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("HSET", journalKey, op.Id.String(), opJSON)
+	return err
+}
+
+func (s *JournalStorage) Delete(id uuid.UUID) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HDEL", journalKey, id.String())
+	return err
+}
+
+func (s *JournalStorage) GetAll() ([]journal.Operation, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	values, err := redigo.ByteSlices(conn.Do("HVALS", journalKey))
+	if err != nil {
+		return nil, err
+	}
+	ops := []journal.Operation{}
+	for _, v := range values {
+		var op journal.Operation
+		if err := json.Unmarshal(v, &op); err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
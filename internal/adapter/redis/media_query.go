@@ -0,0 +1,222 @@
+package redis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+var sortFields = map[string]string{
+	media.FieldCreatedAt:     "@created_at",
+	media.FieldUpdatedAt:     "@updated_at",
+	media.FieldContentLength: "@content_length",
+	media.FieldName:          "@name",
+}
+
+// toSortArgs returns the FT.AGGREGATE SORTBY arguments, the path is the last
+// key so that the order is stable.
+func toSortArgs(keys []media.SortKey) ([]string, error) {
+	if len(keys) == 0 {
+		keys = media.DefaultSort
+	}
+	args := []string{}
+	for _, k := range keys {
+		field, ok := sortFields[k.Field]
+		if !ok {
+			return nil, fmt.Errorf("sort by %s not supported", k.Field)
+		}
+		order := "DESC"
+		if k.Asc {
+			order = "ASC"
+		}
+		args = append(args, field, order)
+	}
+	return append(args, "@path", "ASC"), nil
+}
+
+// afterFilter returns the FT.AGGREGATE FILTER expression matching the medias
+// sorted after the cursor, the keys must have been checked by toSortArgs. The
+// index lower cases the sortable text fields.
+func afterFilter(keys []media.SortKey, c *media.Cursor) string {
+	if len(keys) == 0 {
+		keys = media.DefaultSort
+	}
+	clauses := []string{}
+	equal := ""
+	for _, k := range keys {
+		field := sortFields[k.Field]
+		value := exprValue(c.Value(k.Field))
+		op := "<"
+		if k.Asc {
+			op = ">"
+		}
+		clauses = append(clauses, fmt.Sprintf("(%s%s %s %s)", equal, field, op, value))
+		equal += fmt.Sprintf("%s == %s && ", field, value)
+	}
+	clauses = append(clauses, fmt.Sprintf("(%s@path > %s)", equal, exprValue(strings.ToLower(c.Path))))
+	return strings.Join(clauses, " || ")
+}
+
+func exprValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return fmt.Sprint(v)
+}
+
+// pathQuery matches the medias in the folder and its sub folders.
+func pathQuery(path media.Path) string {
+	p := folder.CleanPath(path.ToString())
+	if p == "/" {
+		return ""
+	}
+	return fmt.Sprintf("@folders:{%s}", escapeQuery(p))
+}
+
+func joinQuery(clauses ...string) string {
+	nonEmpty := []string{}
+	for _, c := range clauses {
+		if c != "" && c != "*" {
+			nonEmpty = append(nonEmpty, c)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return "*"
+	}
+	return strings.Join(nonEmpty, " ")
+}
+
+func buildMediaQuery(path media.Path, tagFilter media.TagFilter) string {
+	clauses := []string{pathQuery(path)}
+	if len(tagFilter.Values) > 0 {
+		values := []string{}
+		for _, v := range tagFilter.Values {
+			values = append(values, escapeQuery(media.NormalizeTagValue(v)))
+		}
+		if tagFilter.MatchAll {
+			for _, v := range values {
+				clauses = append(clauses, fmt.Sprintf("@tags:{%s}", v))
+			}
+		} else {
+			clauses = append(clauses, fmt.Sprintf("@tags:{%s}", strings.Join(values, "|")))
+		}
+	}
+	return joinQuery(clauses...)
+}
+
+func buildSearchQuery(path media.Path, c media.Condition) (string, error) {
+	if c == nil {
+		return joinQuery(pathQuery(path)), nil
+	}
+	query, err := translateCondition(c)
+	if err != nil {
+		return "", err
+	}
+	return joinQuery(pathQuery(path), query), nil
+}
+
+// translateCondition translates the search AST to the RediSearch query syntax.
+func translateCondition(c media.Condition) (string, error) {
+	switch c := c.(type) {
+	case media.And:
+		return translateConditions(c, " ")
+	case media.Or:
+		q, err := translateConditions(c, " | ")
+		if err != nil {
+			return "", err
+		}
+		return "(" + q + ")", nil
+	case media.Not:
+		q, err := translateCondition(c.Condition)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("-(%s)", q), nil
+	case media.Text:
+		terms := []string{}
+		for _, t := range strings.Fields(string(c)) {
+			terms = append(terms, escapeQuery(t))
+		}
+		return fmt.Sprintf("@path|metadata_text|tags_text:(%s)", strings.Join(terms, " ")), nil
+	case media.Comparison:
+		return translateComparison(c)
+	}
+	return "", fmt.Errorf("condition %T not supported", c)
+}
+
+func translateConditions(conditions []media.Condition, sep string) (string, error) {
+	queries := []string{}
+	for _, c := range conditions {
+		q, err := translateCondition(c)
+		if err != nil {
+			return "", err
+		}
+		queries = append(queries, "("+q+")")
+	}
+	return strings.Join(queries, sep), nil
+}
+
+func translateComparison(c media.Comparison) (string, error) {
+	switch c.Field {
+	case media.FieldContentLength:
+		return numericRange("content_length", c.Op, c.Value)
+	case media.FieldWidth, media.FieldHeight:
+		return numericRange(c.Field, c.Op, c.Value)
+	case media.FieldCreatedAt, media.FieldUpdatedAt:
+		return numericRange(c.Field, c.Op, c.Value)
+	case media.FieldContentType:
+		return fmt.Sprintf("@content_type:{%s}", escapeQuery(fmt.Sprint(c.Value))), nil
+	case media.FieldTag:
+		return fmt.Sprintf("@tags:{%s}", escapeQuery(media.NormalizeTagValue(fmt.Sprint(c.Value)))), nil
+	case media.FieldCameraMake:
+		return fmt.Sprintf("@camera_make:{%s}", escapeQuery(fmt.Sprint(c.Value))), nil
+	case media.FieldGps:
+		return fmt.Sprintf("@has_gps:{%v}", c.Value), nil
+	}
+	if media.IsMetadataField(c.Field) {
+		key := strings.TrimPrefix(c.Field, media.MetadataFieldPrefix)
+		return fmt.Sprintf("@metadata:{%s}", escapeQuery(fmt.Sprintf("%s:%v", key, c.Value))), nil
+	}
+	return "", fmt.Errorf("field %s not supported", c.Field)
+}
+
+func numericRange(field string, op media.Operator, value interface{}) (string, error) {
+	var n int64
+	switch v := value.(type) {
+	case int64:
+		n = v
+	case time.Time:
+		n = v.Unix()
+	default:
+		return "", fmt.Errorf("invalid value %v for %s", value, field)
+	}
+	switch op {
+	case media.OpEq:
+		return fmt.Sprintf("@%s:[%d %d]", field, n, n), nil
+	case media.OpGt:
+		return fmt.Sprintf("@%s:[(%d +inf]", field, n), nil
+	case media.OpGte:
+		return fmt.Sprintf("@%s:[%d +inf]", field, n), nil
+	case media.OpLt:
+		return fmt.Sprintf("@%s:[-inf (%d]", field, n), nil
+	case media.OpLte:
+		return fmt.Sprintf("@%s:[-inf %d]", field, n), nil
+	}
+	return "", fmt.Errorf("operator %s not supported", op)
+}
+
+// escapeQuery escapes the RediSearch query syntax characters.
+func escapeQuery(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -2,25 +2,95 @@ package redis
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/RediSearch/redisearch-go/redisearch"
 	redigo "github.com/gomodule/redigo/redis"
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	rejson "github.com/nitishm/go-rejson/v4"
-	"github.com/rs/zerolog/log"
 )
 
-const medias_key = "media"
+const (
+	medias_key      = "media"
+	mediaIndexName  = "media_index"
+	maxSearchLimit  = 100
+	maxFacetResults = 20
+)
+
+var mediaIndex = searchIndex{
+	Alias:   mediaIndexName,
+	Version: 1,
+	Prefix:  fmt.Sprintf("%s:", medias_key),
+	Schema:  mediaIndexSchema,
+}
 
+// mediaIndexSchema is the schema of the media index, fields are aliased so
+// that queries don't need to escape JSON paths. mediaIndex.Version must be
+// bumped when it changes.
+var mediaIndexSchema = []string{
+	"$.path", "AS", "path", "TEXT", "SORTABLE",
+	"$.name", "AS", "name", "TAG", "SORTABLE",
+	"$.folders[*]", "AS", "folders", "TAG",
+	"$.content_type", "AS", "content_type", "TAG",
+	"$.content_length", "AS", "content_length", "NUMERIC", "SORTABLE",
+	"$.timestamp", "AS", "created_at", "NUMERIC", "SORTABLE",
+	"$.updated_timestamp", "AS", "updated_at", "NUMERIC", "SORTABLE",
+	"$.width", "AS", "width", "NUMERIC",
+	"$.height", "AS", "height", "NUMERIC",
+	"$.tags[*].value", "AS", "tags", "TAG", "SEPARATOR", ",",
+	"$.camera_make", "AS", "camera_make", "TAG",
+	"$.has_gps", "AS", "has_gps", "TAG",
+	"$.custom_metadata_fields[*]", "AS", "metadata", "TAG", "SEPARATOR", ",",
+	"$.custom_metadata_text", "AS", "metadata_text", "TEXT",
+	"$.tag_values", "AS", "tags_text", "TEXT",
+}
+
+// mediaWithTimestamp holds the fields only used by the index besides the media.
 type mediaWithTimestamp struct {
 	media.Media
-	Timestamp int64 `json:"timestamp"`
+	Timestamp            int64    `json:"timestamp"`
+	UpdatedTimestamp     int64    `json:"updated_timestamp"`
+	Folders              []string `json:"folders"`
+	Name                 string   `json:"name"`
+	Width                int      `json:"width,omitempty"`
+	Height               int      `json:"height,omitempty"`
+	TagValues            string   `json:"tag_values,omitempty"`
+	CameraMake           string   `json:"camera_make,omitempty"`
+	HasGps               string   `json:"has_gps"`
+	CustomMetadataFields []string `json:"custom_metadata_fields,omitempty"`
+	CustomMetadataText   string   `json:"custom_metadata_text,omitempty"`
+}
+
+func newMediaWithTimestamp(m *media.Media) mediaWithTimestamp {
+	mi := mediaWithTimestamp{
+		Media:                *m,
+		Timestamp:            m.CreatedAt.Unix(),
+		UpdatedTimestamp:     m.UpdatedAt.Unix(),
+		Folders:              folder.Parents(m.Path.Dir()),
+		Name:                 strings.ToLower(m.Path.Filename()),
+		CameraMake:           m.EmbeddedMetadata[media.CameraMakeMetadataKey],
+		HasGps:               "false",
+		CustomMetadataFields: m.CustomMetadata.Fields(),
+		CustomMetadataText:   m.CustomMetadata.Text(),
+	}
+	fmt.Sscan(m.EmbeddedMetadata[media.WidthMetadataKey], &mi.Width)
+	fmt.Sscan(m.EmbeddedMetadata[media.HeightMetadataKey], &mi.Height)
+	if m.EmbeddedMetadata[media.LatitudeMetadataKey] != "" {
+		mi.HasGps = "true"
+	}
+	tagValues := []string{}
+	for _, t := range m.Tags {
+		tagValues = append(tagValues, media.NormalizeTagValue(t.Value))
+	}
+	mi.TagValues = strings.Join(tagValues, ",")
+	return mi
 }
 
 type MediaStorage struct {
+	redisPool        *redigo.Pool
 	rejsonHandler    *rejson.Handler
 	redisearchClient *redisearch.Client
 }
@@ -28,9 +98,10 @@ type MediaStorage struct {
 func NewMediaStorage(redisPool *redigo.Pool) *MediaStorage {
 	rejsonHandler := rejson.NewReJSONHandler()
 	rejsonHandler.SetRedigoClient(redisPool.Get())
-	redisearchClient := redisearch.NewClientFromPool(redisPool, "media_index")
+	redisearchClient := redisearch.NewClientFromPool(redisPool, mediaIndexName)
 
 	s := MediaStorage{
+		redisPool:        redisPool,
 		rejsonHandler:    rejsonHandler,
 		redisearchClient: redisearchClient,
 	}
@@ -39,24 +110,17 @@ func NewMediaStorage(redisPool *redigo.Pool) *MediaStorage {
 }
 
 func (s *MediaStorage) init() {
-	schema := redisearch.NewSchema(redisearch.DefaultOptions).
-		AddField(redisearch.NewTextField("$.path")).
-		AddField(redisearch.NewTextField("$.content_type")).
-		AddField(redisearch.NewNumericField("$.content_length")).
-		AddField(redisearch.NewNumericFieldOptions("$.timestamp", redisearch.NumericFieldOptions{Sortable: true}))
-
-	def := redisearch.NewIndexDefinition()
-	def.IndexOn = "JSON"
-	def.Prefix = []string{fmt.Sprintf("%s:", medias_key)}
-
-	if err := s.redisearchClient.DropIndex(false); err != nil {
-		log.Warn().Err(err)
-	}
-	if err := s.redisearchClient.CreateIndexWithIndexDefinition(schema, def); err != nil {
+	if err := mediaIndex.ensure(s.redisPool); err != nil {
 		mindiaerr.ExitErrorf(err.Error())
 	}
 }
 
+// RebuildIndex rebuilds the media index, it keeps serving the current one
+// until the new one is ready.
+func (s *MediaStorage) RebuildIndex() error {
+	return mediaIndex.rebuild(s.redisPool)
+}
+
 func (s *MediaStorage) Get(path media.Path) (*media.Media, error) {
 	id := fmt.Sprintf("%s:%s", medias_key, path.ToString())
 	return s.get(id)
@@ -78,43 +142,240 @@ func (s *MediaStorage) get(id string) (*media.Media, error) {
 	return &media.Media, nil
 }
 
-func (s *MediaStorage) GetMultiple(path media.Path, offset int, limit int, sortBy string, asc bool) ([]media.Media, error) {
-	var medias = []media.Media{}
+func (s *MediaStorage) GetMultiple(q media.ListQuery) (*media.ListResult, error) {
+	cursor, err := media.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	query := buildMediaQuery(q.Path, q.TagFilter)
+	var medias []media.Media
+	var next string
+	var total int
+	if q.TagFilter.MinConfidence > 0 {
+		medias, next, total, err = s.listConfident(query, q.Sort, cursor, q.Limit, q.TagFilter)
+	} else {
+		medias, next, total, err = s.list(query, q.Sort, cursor, q.Limit)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	switch sortBy {
-	case "created_at":
-		sortBy = "$.timestamp"
-	case "content_length":
-		sortBy = "$.content_length"
-	default:
-		return medias, errors.New("sortby not supported")
+	result := media.ListResult{
+		Medias:     medias,
+		Total:      total,
+		NextCursor: next,
+	}
+	return &result, nil
+}
+
+// listConfident is list for a tag filter with a minimum confidence. Confidence
+// scores are not indexed, the medias matching the query are loaded to keep
+// the ones with confident tags, all of them to count these.
+func (s *MediaStorage) listConfident(query string, sort []media.SortKey, cursor *media.Cursor, limit int, filter media.TagFilter) ([]media.Media, string, int, error) {
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	scan := func(after *media.Cursor, fn func(m *media.Media) bool) error {
+		for {
+			medias, next, _, err := s.list(query, sort, after, maxSearchLimit)
+			if err != nil {
+				return err
+			}
+			for i := range medias {
+				if filter.Matches(&medias[i]) && !fn(&medias[i]) {
+					return nil
+				}
+			}
+			if next == "" {
+				return nil
+			}
+			if after, err = media.DecodeCursor(next); err != nil {
+				return err
+			}
+		}
 	}
 
-	results, _, err := s.redisearchClient.Search(
-		redisearch.
-			NewQuery(fmt.Sprintf("^%s*", path.ToString())).
-			SetInFields("$.path").
-			SetSortBy(sortBy, asc).
-			Limit(offset, limit))
+	page := []media.Media{}
+	err := scan(cursor, func(m *media.Media) bool {
+		page = append(page, *m)
+		return len(page) <= limit
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+	total := 0
+	err = scan(nil, func(*media.Media) bool {
+		total++
+		return true
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+	page, next := media.Page(page, limit)
+	return page, next, total, nil
+}
+
+func (s *MediaStorage) Search(q media.SearchQuery) (*media.SearchResult, error) {
+	cursor, err := media.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	query, err := buildSearchQuery(q.Path, q.Condition)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	medias, next, total, err := s.list(query, q.Sort, cursor, q.Limit)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, r := range results {
-		m, err := s.get(r.Id)
+	result := media.SearchResult{
+		Medias:     medias,
+		Total:      total,
+		NextCursor: next,
+	}
+
+	if len(q.Facets) > 0 {
+		result.Facets = map[string][]media.FacetValue{}
+		for _, f := range q.Facets {
+			values, err := s.facet(query, f)
+			if err != nil {
+				return nil, err
+			}
+			result.Facets[f] = values
+		}
+	}
+	return &result, nil
+}
+
+// list returns the page of the medias matching the query after the cursor,
+// the cursor of the next page and the number of medias matching the query.
+// FT.SEARCH only sorts by one field, the page is fetched with FT.AGGREGATE.
+func (s *MediaStorage) list(query string, sort []media.SortKey, cursor *media.Cursor, limit int) ([]media.Media, string, int, error) {
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	sortArgs, err := toSortArgs(sort)
+	if err != nil {
+		return nil, "", 0, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	reply, err := redigo.Values(conn.Do("FT.SEARCH", mediaIndexName, query, "NOCONTENT", "LIMIT", 0, 0))
+	if err != nil {
+		return nil, "", 0, err
+	}
+	total, err := redigo.Int(reply[0], nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	args := redigo.Args{mediaIndexName, query, "LOAD", 1, "@__key"}
+	if cursor != nil {
+		args = args.Add("FILTER", afterFilter(sort, cursor))
+	}
+	args = args.Add("SORTBY", len(sortArgs)).AddFlat(sortArgs).
+		Add("LIMIT", 0, limit+1)
+	reply, err = redigo.Values(conn.Do("FT.AGGREGATE", args...))
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	keys := parseAggregateKeys(reply)
+	more := len(keys) > limit
+	if more {
+		keys = keys[:limit]
+	}
+	medias := []media.Media{}
+	for _, id := range keys {
+		m, err := s.get(id)
 		if err != nil {
 			continue
 		}
 		medias = append(medias, *m)
 	}
-	return medias, nil
+	next := ""
+	if more && len(medias) > 0 {
+		next = media.CursorAfter(&medias[len(medias)-1]).Encode()
+	}
+	return medias, next, total, nil
 }
 
-func (s *MediaStorage) Save(m *media.Media) error {
-	mi := mediaWithTimestamp{
-		Media:     *m,
-		Timestamp: m.CreatedAt.Unix(),
+func parseAggregateKeys(reply []interface{}) []string {
+	keys := []string{}
+	for _, row := range reply[1:] {
+		fields, err := redigo.Strings(row, nil)
+		if err != nil {
+			continue
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "__key" {
+				keys = append(keys, fields[i+1])
+			}
+		}
+	}
+	return keys
+}
+
+// facet counts the medias matching the query by value of the field.
+func (s *MediaStorage) facet(query string, field string) ([]media.FacetValue, error) {
+	args := redigo.Args{mediaIndexName, query}
+	switch field {
+	case media.FieldContentType:
+		args = args.Add("LOAD", 3, "$.content_type", "AS", "facet_value")
+	case media.FieldCameraMake:
+		args = args.Add("LOAD", 3, "$.camera_make", "AS", "facet_value")
+	case media.FieldTag:
+		args = args.Add("LOAD", 3, "$.tag_values", "AS", "facet_values").
+			Add("APPLY", `split(@facet_values, ",")`, "AS", "facet_value")
+	default:
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("facet %s not supported", field)}
 	}
+	args = args.Add(
+		"GROUPBY", 1, "@facet_value",
+		"REDUCE", "COUNT", 0, "AS", "count",
+		"SORTBY", 2, "@count", "DESC", "MAX", maxFacetResults,
+	)
+
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	reply, err := redigo.Values(conn.Do("FT.AGGREGATE", args...))
+	if err != nil {
+		return nil, err
+	}
+	return parseFacetReply(reply), nil
+}
+
+func parseFacetReply(reply []interface{}) []media.FacetValue {
+	values := []media.FacetValue{}
+	// The first element is the number of groups, then come the rows as
+	// [key, value, key, value] lists.
+	for _, row := range reply[1:] {
+		fields, err := redigo.Strings(row, nil)
+		if err != nil {
+			continue
+		}
+		var v media.FacetValue
+		for i := 0; i+1 < len(fields); i += 2 {
+			switch fields[i] {
+			case "facet_value":
+				v.Value = fields[i+1]
+			case "count":
+				fmt.Sscan(fields[i+1], &v.Count)
+			}
+		}
+		if v.Value != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (s *MediaStorage) Save(m *media.Media) error {
+	mi := newMediaWithTimestamp(m)
 	_, err := s.rejsonHandler.JSONSet(fmt.Sprintf("%s:%s", medias_key, m.Path.ToString()), ".", mi)
 	return err
 }
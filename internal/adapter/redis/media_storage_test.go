@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/media/mediatest"
+)
+
+// TestMediaStorage runs against the Redis Stack instance at
+// MINDIA_TEST_REDIS_ADDR, its database is flushed.
+func TestMediaStorage(t *testing.T) {
+	addr := os.Getenv("MINDIA_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("MINDIA_TEST_REDIS_ADDR not set")
+	}
+	mediatest.TestStorer(t, func(t *testing.T) media.Storer {
+		pool := NewPool(addr)
+		t.Cleanup(func() { pool.Close() })
+
+		conn := pool.Get()
+		defer conn.Close()
+		if _, err := conn.Do("FLUSHDB"); err != nil {
+			t.Fatal(err)
+		}
+		return NewMediaStorage(pool)
+	})
+}
+
+func TestBuildMediaQuery(t *testing.T) {
+	cases := []struct {
+		path   string
+		filter media.TagFilter
+		want   string
+	}{
+		{"/", media.TagFilter{}, "*"},
+		{"/users", media.TagFilter{}, `@folders:{\/users}`},
+		{"/my-folder", media.TagFilter{Values: []string{"dog", "Red Car"}}, `@folders:{\/my\-folder} @tags:{dog|red\ car}`},
+		{"/", media.TagFilter{Values: []string{"dog", "cat"}, MatchAll: true}, "@tags:{dog} @tags:{cat}"},
+	}
+	for _, c := range cases {
+		if got := buildMediaQuery(media.NewPath(c.path), c.filter); got != c.want {
+			t.Errorf("got %s, wanted %s", got, c.want)
+		}
+	}
+}
+
+func TestBuildSearchQuery(t *testing.T) {
+	cases := []struct {
+		q    string
+		want string
+	}{
+		{"", `@folders:{\/users}`},
+		{"size>1kb -has:gps", `@folders:{\/users} (@content_length:[(1024 +inf]) (-(@has_gps:{true}))`},
+		{"tag:dog OR metadata.sku:A-1", `@folders:{\/users} ((@tags:{dog}) | (@metadata:{sku\:A\-1}))`},
+		{"created<2023-01-01 red car", `@folders:{\/users} (@created_at:[-inf (1672531200]) (@path|metadata_text|tags_text:(red)) (@path|metadata_text|tags_text:(car))`},
+	}
+	for _, c := range cases {
+		condition, err := media.ParseSearchQuery(c.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := buildSearchQuery(media.NewPath("/users"), condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %s, wanted %s", c.q, got, c.want)
+		}
+	}
+}
+
+func TestParseFacetReply(t *testing.T) {
+	reply := []interface{}{
+		int64(2),
+		[]interface{}{[]byte("facet_value"), []byte("image/jpeg"), []byte("count"), []byte("3")},
+		[]interface{}{[]byte("facet_value"), []byte("image/png"), []byte("count"), []byte("1")},
+	}
+	values := parseFacetReply(reply)
+	if len(values) != 2 || values[0].Value != "image/jpeg" || values[0].Count != 3 {
+		t.Errorf("unexpected facets %v", values)
+	}
+}
+
+func TestToSortArgs(t *testing.T) {
+	args, err := toSortArgs([]media.SortKey{{Field: media.FieldName, Asc: true}, {Field: media.FieldContentLength}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@name ASC @content_length DESC @path ASC"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("got %s, wanted %s", got, want)
+	}
+	if _, err := toSortArgs([]media.SortKey{{Field: "unknown"}}); err == nil {
+		t.Errorf("should not sort by an unknown field")
+	}
+}
+
+func TestAfterFilter(t *testing.T) {
+	c := &media.Cursor{ContentLength: 10, Name: `a"b.jpg`, Path: "/A/b.jpg"}
+	got := afterFilter([]media.SortKey{{Field: media.FieldName, Asc: true}, {Field: media.FieldContentLength}}, c)
+	want := `(@name > "a\"b.jpg") || (@name == "a\"b.jpg" && @content_length < 10) || (@name == "a\"b.jpg" && @content_length == 10 && @path > "/a/b.jpg")`
+	if got != want {
+		t.Errorf("got %s, wanted %s", got, want)
+	}
+}
+
+func TestParseAggregateKeys(t *testing.T) {
+	reply := []interface{}{
+		int64(2),
+		[]interface{}{[]byte("__key"), []byte("media:/a.jpg")},
+		[]interface{}{[]byte("__key"), []byte("media:/b.jpg")},
+	}
+	keys := parseAggregateKeys(reply)
+	if len(keys) != 2 || keys[1] != "media:/b.jpg" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
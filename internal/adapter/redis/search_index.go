@@ -0,0 +1,280 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	searchIndexesKey = "internal:indexes"
+	// searchIndexLockTTL is short so that the lock of a crashed builder is
+	// soon released, the builder renews it while building.
+	searchIndexLockTTL      = 30 * time.Second
+	searchIndexPollInterval = 500 * time.Millisecond
+	// searchIndexBuildTimeout bounds the wait for a new index to index the
+	// existing documents.
+	searchIndexBuildTimeout = time.Hour
+)
+
+// The lock holds the token of its owner, only the owner renews or releases
+// it.
+var (
+	renewLockScript = redigo.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseLockScript = redigo.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// searchIndex is a versioned RediSearch index definition served through an
+// alias. Each build creates a physical index named after the alias, the
+// version and the build time, which is swapped in once it is fully indexed,
+// so that the documents are never left without an index.
+type searchIndex struct {
+	Alias string
+	// Version must be bumped whenever Schema changes.
+	Version int
+	Prefix  string
+	Schema  []string
+}
+
+// searchIndexState is the index currently served under the alias, it is
+// stored in the searchIndexesKey hash.
+type searchIndexState struct {
+	Version int       `json:"version"`
+	Name    string    `json:"name"`
+	BuiltAt time.Time `json:"built_at"`
+}
+
+// ensure builds the index when it is missing or its version is older than the
+// definition, otherwise the existing index is kept as is.
+func (i searchIndex) ensure(pool *redigo.Pool) error {
+	conn := pool.Get()
+	defer conn.Close()
+
+	state, err := i.state(conn)
+	if err != nil {
+		return err
+	}
+	if state != nil && indexExists(conn, state.Name) {
+		if state.Version > i.Version {
+			log.Warn().Msgf("index %s is at version %d, newer than %d", i.Alias, state.Version, i.Version)
+		}
+		if state.Version >= i.Version {
+			return nil
+		}
+	}
+	return i.build(pool, func(s *searchIndexState) bool {
+		return s != nil && s.Version >= i.Version && indexExists(conn, s.Name)
+	})
+}
+
+// rebuild builds the index from scratch and swaps it in.
+func (i searchIndex) rebuild(pool *redigo.Pool) error {
+	return i.build(pool, nil)
+}
+
+// build creates a new physical index, waits for it to index the existing
+// documents, points the alias to it and drops the previous one. Only one
+// instance builds at a time, the others wait for it and skip the build when
+// done returns true for the state it left.
+func (i searchIndex) build(pool *redigo.Pool, done func(s *searchIndexState) bool) error {
+	conn := pool.Get()
+	defer conn.Close()
+
+	lockKey := fmt.Sprintf("%s:%s:lock", searchIndexesKey, i.Alias)
+	token := uuid.New().String()
+	for {
+		ok, err := redigo.String(conn.Do("SET", lockKey, token, "NX", "PX", searchIndexLockTTL.Milliseconds()))
+		if err != nil && err != redigo.ErrNil {
+			return err
+		}
+		if ok == "OK" {
+			break
+		}
+		time.Sleep(searchIndexPollInterval)
+	}
+	lost, stopRenewing := renewLock(pool, lockKey, token)
+	defer func() {
+		stopRenewing()
+		if _, err := releaseLockScript.Do(conn, lockKey, token); err != nil {
+			log.Warn().Err(err).Msgf("unable to release lock %s", lockKey)
+		}
+	}()
+
+	previous, err := i.state(conn)
+	if err != nil {
+		return err
+	}
+	if done != nil && done(previous) {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s_v%d_%d", i.Alias, i.Version, time.Now().Unix())
+	args := redigo.Args{name, "ON", "JSON", "PREFIX", 1, i.Prefix, "SCHEMA"}
+	for _, a := range i.Schema {
+		args = append(args, a)
+	}
+	if _, err := conn.Do("FT.CREATE", args...); err != nil {
+		return fmt.Errorf("unable to create index %s, %w", name, err)
+	}
+	// Until the alias points to it, the new index serves nothing and is
+	// dropped on failure.
+	served := false
+	defer func() {
+		if served {
+			return
+		}
+		if _, err := conn.Do("FT.DROPINDEX", name); err != nil {
+			log.Warn().Err(err).Msgf("unable to drop index %s", name)
+		}
+	}()
+	log.Info().Msgf("building index %s", name)
+	if err := waitIndexed(conn, name, lost); err != nil {
+		return err
+	}
+	select {
+	case <-lost:
+		return fmt.Errorf("lost lock %s while building index %s", lockKey, name)
+	default:
+	}
+
+	// Before versioning, the alias was the name of the index itself.
+	if info, err := indexInfo(conn, i.Alias); err == nil && fmt.Sprint(info["index_name"]) == i.Alias {
+		if _, err := conn.Do("FT.DROPINDEX", i.Alias); err != nil {
+			return err
+		}
+	}
+	if _, err := conn.Do("FT.ALIASUPDATE", i.Alias, name); err != nil {
+		return err
+	}
+	served = true
+
+	state, err := json.Marshal(searchIndexState{Version: i.Version, Name: name, BuiltAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Do("HSET", searchIndexesKey, i.Alias, state); err != nil {
+		return err
+	}
+	// Without DD, the documents are kept.
+	if previous != nil && previous.Name != name && indexExists(conn, previous.Name) {
+		if _, err := conn.Do("FT.DROPINDEX", previous.Name); err != nil {
+			log.Warn().Err(err).Msgf("unable to drop index %s", previous.Name)
+		}
+	}
+	log.Info().Msgf("index %s now serves %s", name, i.Alias)
+	return nil
+}
+
+// renewLock extends the lock every third of its TTL until stopped. lost is
+// closed once the lock is held by another owner or has expired, the builder
+// must not go on then.
+func renewLock(pool *redigo.Pool, lockKey, token string) (lost <-chan struct{}, stop func()) {
+	lostc := make(chan struct{})
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(searchIndexLockTTL / 3)
+		defer ticker.Stop()
+		renewedAt := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				conn := pool.Get()
+				renewed, err := redigo.Bool(renewLockScript.Do(conn, lockKey, token, searchIndexLockTTL.Milliseconds()))
+				conn.Close()
+				if err == nil && renewed {
+					renewedAt = time.Now()
+					continue
+				}
+				log.Warn().Err(err).Msgf("unable to renew lock %s", lockKey)
+				// An error may be transient, the lock is only lost once it
+				// has expired.
+				if err == nil || time.Since(renewedAt) >= searchIndexLockTTL {
+					close(lostc)
+					return
+				}
+			}
+		}
+	}()
+	return lostc, func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (i searchIndex) state(conn redigo.Conn) (*searchIndexState, error) {
+	b, err := redigo.Bytes(conn.Do("HGET", searchIndexesKey, i.Alias))
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state searchIndexState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func indexExists(conn redigo.Conn, name string) bool {
+	_, err := indexInfo(conn, name)
+	return err == nil
+}
+
+// indexInfo returns the top level fields of FT.INFO.
+func indexInfo(conn redigo.Conn, name string) (map[string]interface{}, error) {
+	reply, err := redigo.Values(conn.Do("FT.INFO", name))
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]interface{}{}
+	for i := 0; i+1 < len(reply); i += 2 {
+		key, _ := redigo.String(reply[i], nil)
+		if b, ok := reply[i+1].([]byte); ok {
+			info[key] = string(b)
+		} else {
+			info[key] = reply[i+1]
+		}
+	}
+	return info, nil
+}
+
+// waitIndexed waits for the index to finish indexing the existing documents,
+// for at most searchIndexBuildTimeout and until the lock is lost.
+func waitIndexed(conn redigo.Conn, name string, lost <-chan struct{}) error {
+	timeout := time.After(searchIndexBuildTimeout)
+	for {
+		info, err := indexInfo(conn, name)
+		if err != nil {
+			return err
+		}
+		if fmt.Sprint(info["indexing"]) == "0" {
+			return nil
+		}
+		select {
+		case <-lost:
+			return fmt.Errorf("lost lock while building index %s", name)
+		case <-timeout:
+			return fmt.Errorf("index %s not built after %s", name, searchIndexBuildTimeout)
+		case <-time.After(searchIndexPollInterval):
+		}
+	}
+}
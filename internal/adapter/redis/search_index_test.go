@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSearchIndex(t *testing.T) {
+	addr := os.Getenv("MINDIA_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("MINDIA_TEST_REDIS_ADDR not set")
+	}
+	pool := NewPool(addr)
+	defer pool.Close()
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+
+	index := searchIndex{Alias: "test_index", Version: 1, Prefix: "test:", Schema: []string{"$.name", "AS", "name", "TAG"}}
+	stateName := func() string {
+		state, err := index.state(conn)
+		if err != nil || state == nil {
+			t.Fatalf("no state, %v", err)
+		}
+		return state.Name
+	}
+
+	if err := index.ensure(pool); err != nil {
+		t.Fatal(err)
+	}
+	first := stateName()
+	if err := index.ensure(pool); err != nil {
+		t.Fatal(err)
+	}
+	if stateName() != first {
+		t.Errorf("index rebuilt although its version did not change")
+	}
+
+	index.Version = 2
+	if err := index.ensure(pool); err != nil {
+		t.Fatal(err)
+	}
+	second := stateName()
+	if second == first || indexExists(conn, first) {
+		t.Errorf("index %s not replaced", first)
+	}
+	if info, err := indexInfo(conn, index.Alias); err != nil || info["index_name"] != second {
+		t.Errorf("alias does not point to %s, %v", second, err)
+	}
+
+	if _, err := conn.Do("FT.DROPINDEX", second); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.ensure(pool); err != nil {
+		t.Fatal(err)
+	}
+	if !indexExists(conn, stateName()) {
+		t.Errorf("dropped index not recreated")
+	}
+}
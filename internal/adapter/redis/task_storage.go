@@ -2,13 +2,36 @@ package redis
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 	redigo "github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
 	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
 )
 
-const tasksQueueKey = "internal:taskQueue"
+const (
+	tasksQueueKey      = "internal:taskQueue"
+	recurringJobsKey   = "internal:recurringJobs"
+	schedulerLeaderKey = "internal:schedulerLeader"
+	taskKeyPrefix      = "internal:task"
+	taskRetention      = 7 * 24 * time.Hour
+)
+
+var acquireLeadershipScript = redigo.NewScript(1, `
+local current = redis.call('GET', KEYS[1])
+if current == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
 
 type TaskStorage struct {
 	redisPool *redis.Pool
@@ -21,6 +44,21 @@ func NewTaskStorage(redisPool *redigo.Pool) *TaskStorage {
 	return s
 }
 
+func queueKey(priority scheduler.TaskPriority) string {
+	switch priority {
+	case scheduler.HighPriority:
+		return tasksQueueKey + ":high"
+	case scheduler.LowPriority:
+		return tasksQueueKey + ":low"
+	default:
+		return tasksQueueKey
+	}
+}
+
+func taskKey(id uuid.UUID) string {
+	return fmt.Sprintf("%s:%s", taskKeyPrefix, id.String())
+}
+
 func (s *TaskStorage) EnqueueTask(task *scheduler.Task) error {
 	conn := s.redisPool.Get()
 	defer conn.Close()
@@ -30,30 +68,105 @@ func (s *TaskStorage) EnqueueTask(task *scheduler.Task) error {
 		return err
 	}
 
-	_, err = conn.Do("RPUSH", tasksQueueKey, taskJSON)
+	conn.Send("MULTI")
+	conn.Send("SET", taskKey(task.Id), taskJSON, "EX", int(taskRetention.Seconds()))
+	conn.Send("RPUSH", queueKey(task.Priority), taskJSON)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *TaskStorage) DequeueTask(timeout time.Duration) (*scheduler.Task, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	args := redigo.Args{}
+	for _, p := range scheduler.Priorities {
+		args = args.Add(queueKey(p))
+	}
+	args = args.Add(timeout.Seconds())
+
+	reply, err := redigo.ByteSlices(conn.Do("BLPOP", args...))
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var task scheduler.Task
+	err = json.Unmarshal(reply[1], &task)
+	if err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+func (s *TaskStorage) SaveTask(task *scheduler.Task) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	taskJSON, err := json.Marshal(task)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = conn.Do("SET", taskKey(task.Id), taskJSON, "EX", int(taskRetention.Seconds()))
+	return err
 }
 
-func (s *TaskStorage) DequeueTask() (*scheduler.Task, error) {
+func (s *TaskStorage) GetTask(id uuid.UUID) (*scheduler.Task, error) {
 	conn := s.redisPool.Get()
 	defer conn.Close()
 
-	taskJSON, err := conn.Do("LPOP", tasksQueueKey)
+	taskJSON, err := redigo.Bytes(conn.Do("GET", taskKey(id)))
+	if err == redigo.ErrNil {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeTaskNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
-	if taskJSON == nil {
-		return nil, nil
+	var task scheduler.Task
+	err = json.Unmarshal(taskJSON, &task)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
 	}
+	return &task, nil
+}
 
-	var task scheduler.Task
-	err = json.Unmarshal(taskJSON.([]byte), &task)
+func (s *TaskStorage) SaveRecurringJob(job *scheduler.RecurringJob) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("HSET", recurringJobsKey, job.Name, jobJSON)
+	return err
+}
+
+func (s *TaskStorage) GetRecurringJobs() (map[string]scheduler.RecurringJob, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	values, err := redigo.StringMap(conn.Do("HGETALL", recurringJobsKey))
 	if err != nil {
 		return nil, err
 	}
+	jobs := map[string]scheduler.RecurringJob{}
+	for name, v := range values {
+		var job scheduler.RecurringJob
+		if err := json.Unmarshal([]byte(v), &job); err != nil {
+			return nil, err
+		}
+		jobs[name] = job
+	}
+	return jobs, nil
+}
 
-	return &task, nil
+func (s *TaskStorage) AcquireLeadership(instanceId string, ttl time.Duration) (bool, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	return redigo.Bool(acquireLeadershipScript.Do(conn, schedulerLeaderKey, instanceId, ttl.Milliseconds()))
 }
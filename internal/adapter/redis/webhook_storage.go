@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+
+	redigo "github.com/gomodule/redigo/redis"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/webhook"
+	"github.com/nitishm/go-rejson"
+)
+
+const (
+	webhooksKey                = "internal:configuration:webhooks"
+	webhookDeliveriesKeyPrefix = "internal:webhookDeliveries"
+)
+
+type WebhookStorage struct {
+	redisPool     *redigo.Pool
+	rejsonHandler *rejson.Handler
+}
+
+func NewWebhookStorage(redisPool *redigo.Pool) *WebhookStorage {
+	rejsonHandler := rejson.NewReJSONHandler()
+	rejsonHandler.SetRedigoClient(redisPool.Get())
+
+	s := WebhookStorage{
+		redisPool:     redisPool,
+		rejsonHandler: rejsonHandler,
+	}
+	s.init()
+	return &s
+}
+
+func (s *WebhookStorage) init() error {
+	res, err := s.rejsonHandler.JSONGet(webhooksKey, ".")
+	if err != nil && err != redigo.ErrNil {
+		return err
+	}
+	if res == nil {
+		_, err := s.rejsonHandler.JSONSet(webhooksKey, ".", webhook.WebhookMap{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func webhookDeliveriesKey(webhookId string) string {
+	return fmt.Sprintf("%s:%s", webhookDeliveriesKeyPrefix, webhookId)
+}
+
+func (s *WebhookStorage) GetAll() (webhook.WebhookMap, error) {
+	res, err := s.rejsonHandler.JSONGet(webhooksKey, ".")
+	if err == redigo.ErrNil || (err == nil && res == nil) {
+		return webhook.WebhookMap{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var webhooks webhook.WebhookMap
+	err = json.Unmarshal(res.([]byte), &webhooks)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookStorage) Get(id string) (*webhook.Webhook, error) {
+	webhooks, err := s.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := webhooks[id]; ok {
+		return &val, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeWebhookNotFound)
+}
+
+func (s *WebhookStorage) Save(w webhook.Webhook) error {
+	s.init()
+	_, err := s.rejsonHandler.JSONSet(webhooksKey, fmt.Sprintf("[\"%s\"]", w.Id), w)
+	return err
+}
+
+func (s *WebhookStorage) Delete(id string) error {
+	_, err := s.rejsonHandler.JSONDel(webhooksKey, fmt.Sprintf("[\"%s\"]", id))
+	if err != nil {
+		return err
+	}
+
+	conn := s.redisPool.Get()
+	defer conn.Close()
+	_, err = conn.Do("DEL", webhookDeliveriesKey(id))
+	return err
+}
+
+func (s *WebhookStorage) SaveDelivery(delivery webhook.Delivery) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	deliveryJSON, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	key := webhookDeliveriesKey(delivery.WebhookId)
+	conn.Send("MULTI")
+	conn.Send("LPUSH", key, deliveryJSON)
+	conn.Send("LTRIM", key, 0, webhook.MaxDeliveries-1)
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *WebhookStorage) GetDeliveries(webhookId string) ([]webhook.Delivery, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	values, err := redigo.ByteSlices(conn.Do("LRANGE", webhookDeliveriesKey(webhookId), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []webhook.Delivery{}
+	for _, v := range values {
+		var delivery webhook.Delivery
+		if err := json.Unmarshal(v, &delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -109,6 +109,23 @@ func (s *FileStorage) GetMultiple(p media.Path) ([]media.FileInfo, error) {
 	return medias, nil
 }
 
+func (s *FileStorage) Walk(p media.Path, fn func(media.FileInfo) error) error {
+	prefix := strings.TrimPrefix(p.ToString(), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return s.s3.WalkObjects(ListObjectsParams{
+		Bucket: s.bucket,
+		Prefix: prefix,
+	}, func(obj S3Object) error {
+		return fn(media.FileInfo{
+			Path:          media.NewPath("/" + obj.Key),
+			ContentLength: int(obj.ContentLength),
+			ModifiedAt:    obj.LastModified,
+		})
+	})
+}
+
 func (s *FileStorage) Move(src, dst media.Path) error {
 	return s.s3.RenameObject(MoveObjectParams{
 		Bucket: s.bucket,
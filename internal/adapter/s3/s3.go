@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -18,6 +19,7 @@ type S3Object struct {
 	ContentType   string
 	ContentLength int64
 	Metadata      map[string]*string
+	LastModified  time.Time
 }
 
 type S3Config struct {
@@ -79,6 +81,33 @@ func (s *S3) ListObjects(p ListObjectsParams) ([]S3Object, error) {
 	return objs, nil
 }
 
+// WalkObjects calls fn for every object under the prefix, in the lexical
+// order of their keys.
+func (s *S3) WalkObjects(p ListObjectsParams, fn func(S3Object) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.Bucket),
+		Prefix: aws.String(p.Prefix),
+	}
+	var fnErr error
+	err := s.s3.ListObjectsV2Pages(input, func(output *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range output.Contents {
+			fnErr = fn(S3Object{
+				Key:           *obj.Key,
+				ContentLength: *obj.Size,
+				LastModified:  aws.TimeValue(obj.LastModified),
+			})
+			if fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
 type GetObjectParams struct {
 	Bucket string
 	Key    string
@@ -0,0 +1,208 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+const pathColumn = "m.path"
+
+var sortColumns = map[string]string{
+	media.FieldCreatedAt:     "m.created_at",
+	media.FieldUpdatedAt:     "m.updated_at",
+	media.FieldContentLength: "m.content_length",
+	media.FieldName:          "m.name",
+}
+
+// toOrderBy returns the ORDER BY clause, the path is the last key so that
+// the order is stable.
+func toOrderBy(keys []media.SortKey) (string, error) {
+	if len(keys) == 0 {
+		keys = media.DefaultSort
+	}
+	columns := []string{}
+	for _, k := range keys {
+		column, ok := sortColumns[k.Field]
+		if !ok {
+			return "", fmt.Errorf("sort by %s not supported", k.Field)
+		}
+		if k.Asc {
+			columns = append(columns, column+" ASC")
+		} else {
+			columns = append(columns, column+" DESC")
+		}
+	}
+	return strings.Join(append(columns, pathColumn+" ASC"), ", "), nil
+}
+
+// whereClause builds a WHERE clause over the medias table aliased as m.
+type whereClause struct {
+	clauses []string
+	args    []interface{}
+}
+
+func (w *whereClause) add(clause string, args ...interface{}) {
+	w.clauses = append(w.clauses, clause)
+	w.args = append(w.args, args...)
+}
+
+func (w *whereClause) sql() string {
+	if len(w.clauses) == 0 {
+		return "1 = 1"
+	}
+	return strings.Join(w.clauses, " AND ")
+}
+
+// after matches the medias sorted after the cursor, the keys must have been
+// checked by toOrderBy.
+func (w *whereClause) after(keys []media.SortKey, c *media.Cursor) {
+	if c == nil {
+		return
+	}
+	if len(keys) == 0 {
+		keys = media.DefaultSort
+	}
+	clauses := []string{}
+	args := []interface{}{}
+	equal := ""
+	equalArgs := []interface{}{}
+	for _, k := range keys {
+		column := sortColumns[k.Field]
+		op := " < ?"
+		if k.Asc {
+			op = " > ?"
+		}
+		clauses = append(clauses, equal+column+op)
+		args = append(append(args, equalArgs...), c.Value(k.Field))
+		equal += column + " = ? AND "
+		equalArgs = append(equalArgs, c.Value(k.Field))
+	}
+	clauses = append(clauses, equal+pathColumn+" > ?")
+	args = append(append(args, equalArgs...), c.Path)
+	w.add("("+strings.Join(clauses, " OR ")+")", args...)
+}
+
+// path matches the medias in the folder and its sub folders.
+func (w *whereClause) path(path media.Path) {
+	p := folder.CleanPath(path.ToString())
+	if p == "/" {
+		return
+	}
+	// "0" follows "/" so the range matches the sub folders with the index.
+	w.add("(m.dir = ? OR (m.dir >= ? AND m.dir < ?))", p, p+"/", p+"0")
+}
+
+func (w *whereClause) tagFilter(f media.TagFilter) {
+	if f.IsEmpty() {
+		return
+	}
+	if len(f.Values) == 0 {
+		w.add("EXISTS (SELECT 1 FROM media_tags t WHERE t.path = m.path AND t.confidence >= ?)", f.MinConfidence)
+		return
+	}
+	if f.MatchAll {
+		for _, v := range f.Values {
+			w.add("EXISTS (SELECT 1 FROM media_tags t WHERE t.path = m.path AND t.value = ? AND t.confidence >= ?)", media.NormalizeTagValue(v), f.MinConfidence)
+		}
+		return
+	}
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, v := range f.Values {
+		placeholders = append(placeholders, "?")
+		args = append(args, media.NormalizeTagValue(v))
+	}
+	args = append(args, f.MinConfidence)
+	w.add("EXISTS (SELECT 1 FROM media_tags t WHERE t.path = m.path AND t.value IN ("+strings.Join(placeholders, ", ")+") AND t.confidence >= ?)", args...)
+}
+
+func (w *whereClause) condition(c media.Condition) error {
+	clause, args, err := translateCondition(c)
+	if err != nil {
+		return err
+	}
+	w.add(clause, args...)
+	return nil
+}
+
+// translateCondition translates the search AST to SQL.
+func translateCondition(c media.Condition) (string, []interface{}, error) {
+	switch c := c.(type) {
+	case media.And:
+		return translateConditions(c, " AND ")
+	case media.Or:
+		return translateConditions(c, " OR ")
+	case media.Not:
+		clause, args, err := translateCondition(c.Condition)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + clause + ")", args, nil
+	case media.Text:
+		terms := []string{}
+		for _, t := range strings.Fields(string(c)) {
+			terms = append(terms, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+		}
+		return "m.path IN (SELECT path FROM medias_fts WHERE medias_fts MATCH ?)", []interface{}{strings.Join(terms, " ")}, nil
+	case media.Comparison:
+		return translateComparison(c)
+	}
+	return "", nil, fmt.Errorf("condition %T not supported", c)
+}
+
+func translateConditions(conditions []media.Condition, sep string) (string, []interface{}, error) {
+	clauses := []string{}
+	args := []interface{}{}
+	for _, c := range conditions {
+		clause, a, err := translateCondition(c)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, "("+clause+")")
+		args = append(args, a...)
+	}
+	return "(" + strings.Join(clauses, sep) + ")", args, nil
+}
+
+func translateComparison(c media.Comparison) (string, []interface{}, error) {
+	switch c.Field {
+	case media.FieldContentLength:
+		return numericComparison("m.content_length", c.Op, c.Value)
+	case media.FieldWidth, media.FieldHeight, media.FieldCreatedAt, media.FieldUpdatedAt:
+		return numericComparison("m."+c.Field, c.Op, c.Value)
+	case media.FieldContentType:
+		return "m.content_type = lower(?)", []interface{}{fmt.Sprint(c.Value)}, nil
+	case media.FieldTag:
+		return "EXISTS (SELECT 1 FROM media_tags t WHERE t.path = m.path AND t.value = ?)", []interface{}{media.NormalizeTagValue(fmt.Sprint(c.Value))}, nil
+	case media.FieldCameraMake:
+		return "lower(m.camera_make) = lower(?)", []interface{}{fmt.Sprint(c.Value)}, nil
+	case media.FieldGps:
+		return "m.has_gps = ?", []interface{}{c.Value == true}, nil
+	}
+	if media.IsMetadataField(c.Field) {
+		key := strings.TrimPrefix(c.Field, media.MetadataFieldPrefix)
+		return "EXISTS (SELECT 1 FROM media_metadata mm WHERE mm.path = m.path AND mm.key = ? AND lower(mm.value) = lower(?))", []interface{}{key, fmt.Sprint(c.Value)}, nil
+	}
+	return "", nil, fmt.Errorf("field %s not supported", c.Field)
+}
+
+func numericComparison(column string, op media.Operator, value interface{}) (string, []interface{}, error) {
+	var n int64
+	switch v := value.(type) {
+	case int64:
+		n = v
+	case time.Time:
+		n = v.Unix()
+	default:
+		return "", nil, fmt.Errorf("invalid value %v for %s", value, column)
+	}
+	switch op {
+	case media.OpEq, media.OpGt, media.OpGte, media.OpLt, media.OpLte:
+		return fmt.Sprintf("%s %s ?", column, op), []interface{}{n}, nil
+	}
+	return "", nil, fmt.Errorf("operator %s not supported", op)
+}
@@ -0,0 +1,310 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+const (
+	maxSearchLimit  = 100
+	maxFacetResults = 20
+)
+
+var mediaMigrations = []string{
+	`CREATE TABLE medias (
+		path           TEXT PRIMARY KEY,
+		dir            TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		content_type   TEXT NOT NULL,
+		content_length INTEGER NOT NULL,
+		width          INTEGER NOT NULL DEFAULT 0,
+		height         INTEGER NOT NULL DEFAULT 0,
+		camera_make    TEXT NOT NULL DEFAULT '',
+		has_gps        INTEGER NOT NULL DEFAULT 0,
+		created_at     INTEGER NOT NULL,
+		updated_at     INTEGER NOT NULL,
+		data           TEXT NOT NULL
+	);
+	CREATE INDEX medias_dir ON medias (dir);
+	CREATE INDEX medias_content_type ON medias (content_type);
+	CREATE INDEX medias_created_at ON medias (created_at);
+	CREATE INDEX medias_updated_at ON medias (updated_at);
+
+	CREATE TABLE media_tags (
+		path       TEXT NOT NULL,
+		value      TEXT NOT NULL,
+		confidence REAL NOT NULL,
+		provider   TEXT NOT NULL
+	);
+	CREATE INDEX media_tags_path ON media_tags (path);
+	CREATE INDEX media_tags_value ON media_tags (value, confidence);
+
+	CREATE TABLE media_metadata (
+		path  TEXT NOT NULL,
+		key   TEXT NOT NULL,
+		value TEXT NOT NULL
+	);
+	CREATE INDEX media_metadata_path ON media_metadata (path);
+	CREATE INDEX media_metadata_key ON media_metadata (key, value);
+
+	CREATE VIRTUAL TABLE medias_fts USING fts5(path, tags, metadata);`,
+}
+
+type MediaStorage struct {
+	db *sql.DB
+}
+
+func NewMediaStorage(path string) (*MediaStorage, error) {
+	db, err := Open(path, mediaMigrations)
+	if err != nil {
+		return nil, err
+	}
+	return &MediaStorage{
+		db: db,
+	}, nil
+}
+
+func (s *MediaStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *MediaStorage) Get(path media.Path) (*media.Media, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM medias WHERE path = ?`, path.ToString()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeMediaNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeMedia(data)
+}
+
+func decodeMedia(data string) (*media.Media, error) {
+	var m media.Media
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+	}
+	return &m, nil
+}
+
+func (s *MediaStorage) GetMultiple(q media.ListQuery) (*media.ListResult, error) {
+	cursor, err := media.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	where := whereClause{}
+	where.path(q.Path)
+	where.tagFilter(q.TagFilter)
+
+	medias, next, total, err := s.list(where, q.Sort, cursor, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := media.ListResult{
+		Medias:     medias,
+		Total:      total,
+		NextCursor: next,
+	}
+	return &result, nil
+}
+
+func (s *MediaStorage) Search(q media.SearchQuery) (*media.SearchResult, error) {
+	cursor, err := media.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	where := whereClause{}
+	where.path(q.Path)
+	if q.Condition != nil {
+		if err := where.condition(q.Condition); err != nil {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+		}
+	}
+
+	medias, next, total, err := s.list(where, q.Sort, cursor, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := media.SearchResult{
+		Medias:     medias,
+		Total:      total,
+		NextCursor: next,
+	}
+
+	if len(q.Facets) > 0 {
+		result.Facets = map[string][]media.FacetValue{}
+		for _, f := range q.Facets {
+			values, err := s.facet(where, f)
+			if err != nil {
+				return nil, err
+			}
+			result.Facets[f] = values
+		}
+	}
+	return &result, nil
+}
+
+// list returns the page of the medias matching the clause after the cursor,
+// the cursor of the next page and the number of medias matching the clause.
+func (s *MediaStorage) list(where whereClause, sort []media.SortKey, cursor *media.Cursor, limit int) ([]media.Media, string, int, error) {
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	orderBy, err := toOrderBy(sort)
+	if err != nil {
+		return nil, "", 0, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+
+	var total int
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM medias m WHERE `+where.sql(), where.args...).Scan(&total)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	where.after(sort, cursor)
+	args := append(append([]interface{}{}, where.args...), limit+1)
+	rows, err := s.db.Query(`SELECT m.data FROM medias m WHERE `+where.sql()+` ORDER BY `+orderBy+` LIMIT ?`, args...)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer rows.Close()
+
+	medias := []media.Media{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, "", 0, err
+		}
+		m, err := decodeMedia(data)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		medias = append(medias, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", 0, err
+	}
+	medias, next := media.Page(medias, limit)
+	return medias, next, total, nil
+}
+
+// facet counts the medias matching the clause by value of the field.
+func (s *MediaStorage) facet(where whereClause, field string) ([]media.FacetValue, error) {
+	var query string
+	switch field {
+	case media.FieldContentType:
+		query = `SELECT m.content_type, COUNT(*) FROM medias m WHERE ` + where.sql() + ` GROUP BY 1`
+	case media.FieldCameraMake:
+		query = `SELECT m.camera_make, COUNT(*) FROM medias m WHERE m.camera_make != '' AND ` + where.sql() + ` GROUP BY 1`
+	case media.FieldTag:
+		query = `SELECT t.value, COUNT(DISTINCT m.path) FROM medias m JOIN media_tags t ON t.path = m.path WHERE ` + where.sql() + ` GROUP BY 1`
+	default:
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("facet %s not supported", field)}
+	}
+	rows, err := s.db.Query(query+` ORDER BY 2 DESC, 1 LIMIT `+strconv.Itoa(maxFacetResults), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []media.FacetValue{}
+	for rows.Next() {
+		var v media.FacetValue
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func (s *MediaStorage) Save(m *media.Media) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	width, _ := strconv.Atoi(m.EmbeddedMetadata[media.WidthMetadataKey])
+	height, _ := strconv.Atoi(m.EmbeddedMetadata[media.HeightMetadataKey])
+	hasGps := m.EmbeddedMetadata[media.LatitudeMetadataKey] != ""
+	path := m.Path.ToString()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteMedia(tx, path); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO medias (path, dir, name, content_type, content_length, width, height, camera_make, has_gps, created_at, updated_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		path,
+		folder.CleanPath(m.Path.Dir()),
+		strings.ToLower(m.Path.Filename()),
+		strings.ToLower(m.ContentType),
+		m.ContentLength,
+		width,
+		height,
+		m.EmbeddedMetadata[media.CameraMakeMetadataKey],
+		hasGps,
+		m.CreatedAt.Unix(),
+		m.UpdatedAt.Unix(),
+		string(data),
+	)
+	if err != nil {
+		return err
+	}
+
+	tagValues := []string{}
+	for _, t := range m.Tags {
+		value := media.NormalizeTagValue(t.Value)
+		tagValues = append(tagValues, value)
+		_, err := tx.Exec(`INSERT INTO media_tags (path, value, confidence, provider) VALUES (?, ?, ?, ?)`, path, value, t.ConfidenceScore, t.Provider)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range m.CustomMetadata.Fields() {
+		key, value, _ := strings.Cut(f, ":")
+		_, err := tx.Exec(`INSERT INTO media_metadata (path, key, value) VALUES (?, ?, ?)`, path, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO medias_fts (path, tags, metadata) VALUES (?, ?, ?)`, path, strings.Join(tagValues, " "), m.CustomMetadata.Text())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MediaStorage) Delete(path media.Path) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteMedia(tx, path.ToString()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteMedia(tx *sql.Tx, path string) error {
+	for _, table := range []string{"medias", "media_tags", "media_metadata", "medias_fts"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE path = ?`, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
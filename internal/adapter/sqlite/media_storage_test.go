@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/media/mediatest"
+)
+
+func TestMediaStorage(t *testing.T) {
+	mediatest.TestStorer(t, func(t *testing.T) media.Storer {
+		s, err := NewMediaStorage(filepath.Join(t.TempDir(), "mindia.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestMigrationsAreIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mindia.db")
+	for i := 0; i < 2; i++ {
+		s, err := NewMediaStorage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+	}
+}
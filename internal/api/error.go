@@ -23,6 +23,20 @@ func apiHandler(fn apiFunc) http.HandlerFunc {
 					writeError(w, http.StatusInternalServerError, err)
 				case mindiaerr.ErrCodeNamedTransformationNotFound:
 					writeError(w, http.StatusBadRequest, err)
+				case mindiaerr.ErrBadRequest:
+					writeError(w, http.StatusBadRequest, err)
+				case mindiaerr.ErrCodeTaskNotFound:
+					writeError(w, http.StatusNotFound, err)
+				case mindiaerr.ErrCodeWebhookNotFound:
+					writeError(w, http.StatusNotFound, err)
+				case mindiaerr.ErrCodePluginNotFound:
+					writeError(w, http.StatusNotFound, err)
+				case mindiaerr.ErrCodeFolderNotFound:
+					writeError(w, http.StatusNotFound, err)
+				case mindiaerr.ErrCodeCollectionNotFound:
+					writeError(w, http.StatusNotFound, err)
+				case mindiaerr.ErrCodeVersionNotFound:
+					writeError(w, http.StatusNotFound, err)
 				default:
 					writeError(w, http.StatusInternalServerError, err)
 				}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +22,7 @@ import (
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
 	mindialog "github.com/jeremybastin1207/mindia-core/internal/logging"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/plugin"
 	"github.com/jeremybastin1207/mindia-core/internal/settings"
 	"github.com/jeremybastin1207/mindia-core/internal/task"
 	pathutils "github.com/jeremybastin1207/mindia-core/pkg/path"
@@ -31,14 +33,21 @@ type Tasks struct {
 	ClearCache                  task.ClearCacheTask
 	NamedTransformationOperator task.NamedTransformationOperator
 	ApiKeyOperator              task.ApiKeyOperator
+	WebhookOperator             task.WebhookOperator
+	FolderSettingsOperator      task.FolderSettingsOperator
+	FolderOperator              task.FolderOperator
+	WasmModuleOperator          task.WasmModuleOperator
 	AnalyticsOperator           task.AnalyticsOperator
 	TaskOperator                task.TaskOperator
 	GetMedia                    task.GetMediaTask
 	DownloadMedia               task.DownloadMediaTask
 	UploadMedia                 task.UploadMediaTask
+	UpdateMedia                 task.UpdateMediaTask
 	DeleteMedia                 task.DeleteMediaTask
 	MoveMedia                   task.MoveMediaTask
 	CopyMedia                   task.CopyMediaTask
+	VersionMedia                task.VersionMediaTask
+	CollectionOperator          task.CollectionOperator
 	TagMedia                    task.TagMediaTask
 	ColorizeMedia               task.ColorizeMediaTask
 }
@@ -50,6 +59,7 @@ type ApiServer struct {
 	apikeyStorage apikey.Storer
 	logger        mindialog.Logger
 	tasks         Tasks
+	pluginRoutes  map[string][]plugin.Route
 }
 
 func NewApiServer(
@@ -59,6 +69,7 @@ func NewApiServer(
 	apikeyStorage apikey.Storer,
 	logger mindialog.Logger,
 	tasks Tasks,
+	pluginRoutes map[string][]plugin.Route,
 ) ApiServer {
 	return ApiServer{
 		host,
@@ -67,6 +78,7 @@ func NewApiServer(
 		apikeyStorage,
 		logger,
 		tasks,
+		pluginRoutes,
 	}
 }
 
@@ -94,13 +106,64 @@ func (s *ApiServer) Serve() {
 	sr.Methods("POST", "OPTIONS").HandlerFunc(apiHandler(s.handleCreateKey))
 	sr.Methods("DELETE", "OPTIONS").Path("/{api_key}").HandlerFunc(apiHandler(s.handleDeleteKey))
 
+	sr = apir.PathPrefix("/webhook").Subrouter()
+	sr.Use(masterKeyMiddleware(s.masterKey))
+	sr.Methods("GET", "OPTIONS").Path("").HandlerFunc(apiHandler(s.handleReadWebhooks))
+	sr.Methods("POST", "OPTIONS").Path("").HandlerFunc(apiHandler(s.handleCreateWebhook))
+	sr.Methods("GET", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleGetWebhook))
+	sr.Methods("PATCH", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleUpdateWebhook))
+	sr.Methods("DELETE", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleDeleteWebhook))
+	sr.Methods("GET", "OPTIONS").Path("/{id}/deliveries").HandlerFunc(apiHandler(s.handleReadWebhookDeliveries))
+
+	sr = apir.PathPrefix("/folder_settings").Subrouter()
+	sr.Use(masterKeyMiddleware(s.masterKey))
+	sr.Methods("GET", "OPTIONS").Path("").HandlerFunc(apiHandler(s.handleReadFolderSettings))
+	sr.Methods("GET", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleGetFolderSettings))
+	sr.Methods("PUT", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleSaveFolderSettings))
+	sr.Methods("DELETE", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleDeleteFolderSettings))
+
+	sr = apir.PathPrefix("/folder").Subrouter()
+	sr.Use(apiKeyMiddleware(s.apikeyStorage, s.masterKey))
+	sr.Methods("GET", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleListFolder))
+	sr.Methods("POST", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleCreateFolder))
+	sr.Methods("PUT", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleMoveFolder))
+	sr.Methods("DELETE", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleDeleteFolder))
+
+	sr = apir.PathPrefix("/collection").Subrouter()
+	sr.Use(apiKeyMiddleware(s.apikeyStorage, s.masterKey))
+	sr.Methods("GET", "OPTIONS").Path("").HandlerFunc(apiHandler(s.handleReadCollections))
+	sr.Methods("POST", "OPTIONS").Path("").HandlerFunc(apiHandler(s.handleCreateCollection))
+	sr.Methods("GET", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleGetCollection))
+	sr.Methods("PATCH", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleUpdateCollection))
+	sr.Methods("DELETE", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleDeleteCollection))
+	sr.Methods("POST", "OPTIONS").Path("/{id}/medias").HandlerFunc(apiHandler(s.handleAddCollectionMedias))
+	sr.Methods("DELETE", "OPTIONS").Path("/{id}/medias").HandlerFunc(apiHandler(s.handleRemoveCollectionMedias))
+	sr.Methods("PUT", "OPTIONS").Path("/{id}/medias").HandlerFunc(apiHandler(s.handleReorderCollectionMedias))
+	sr.Methods("PUT", "OPTIONS").Path("/{id}/cover").HandlerFunc(apiHandler(s.handleSetCollectionCover))
+	sr.Methods("GET", "OPTIONS").Path("/{id}/download").HandlerFunc(apiHandler(s.handleDownloadCollection))
+
+	sr = apir.PathPrefix("/wasm_module").Subrouter()
+	sr.Use(masterKeyMiddleware(s.masterKey))
+	sr.Methods("GET", "OPTIONS").Path("").HandlerFunc(apiHandler(s.handleReadWasmModules))
+	sr.Methods("PUT", "OPTIONS").Path("/{name}").HandlerFunc(apiHandler(s.handleUploadWasmModule))
+
 	sr = apir.PathPrefix("/task").Subrouter()
 	sr.Use(apiKeyMiddleware(s.apikeyStorage, s.masterKey))
+	sr.Methods("GET", "OPTIONS").Path("/{id}").HandlerFunc(apiHandler(s.handleGetTask))
+	sr.Methods("GET", "OPTIONS").Path("/{id}/events").HandlerFunc(apiHandler(s.handleStreamTaskEvents))
 
 	sr = apir.PathPrefix("/analytics").Subrouter()
 	sr.Use(apiKeyMiddleware(s.apikeyStorage, s.masterKey))
 	sr.Methods("GET", "OPTIONS").Path("/space").HandlerFunc(apiHandler(s.handleReadSpaceUsage))
 
+	for name, routes := range s.pluginRoutes {
+		sr = apir.PathPrefix("/plugin/" + name).Subrouter()
+		sr.Use(apiKeyMiddleware(s.apikeyStorage, s.masterKey))
+		for _, route := range routes {
+			sr.Methods(route.Method, "OPTIONS").Path(route.Path).HandlerFunc(route.Handler)
+		}
+	}
+
 	sr = apir.PathPrefix("/download").Subrouter()
 	sr.Methods("GET", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleDownloadMedia))
 
@@ -112,9 +175,15 @@ func (s *ApiServer) Serve() {
 	sr.Methods("PUT", "OPTIONS").Path("/move").HandlerFunc(apiHandler(s.handleMoveMedia))
 	sr.Methods("PUT", "OPTIONS").Path("/copy").HandlerFunc(apiHandler(s.handleCopyMedia))
 	sr.Methods("POST", "OPTIONS").Path("/tag/{path:.*}").HandlerFunc(apiHandler(s.handleTagMedia))
+	sr.Methods("POST", "OPTIONS").Path("/tags/{path:.*}").HandlerFunc(apiHandler(s.handleAddMediaTags))
+	sr.Methods("DELETE", "OPTIONS").Path("/tags/{path:.*}").HandlerFunc(apiHandler(s.handleRemoveMediaTags))
 	sr.Methods("POST", "OPTIONS").Path("/colorize/{path:.*}").HandlerFunc(apiHandler(s.handleColorizeMedia))
+	sr.Methods("GET", "OPTIONS").Path("/file/{path:.*}/versions").HandlerFunc(apiHandler(s.handleGetMediaVersions))
+	sr.Methods("POST", "OPTIONS").Path("/file/{path:.*}/versions/{id}/restore").HandlerFunc(apiHandler(s.handleRestoreMediaVersion))
 	sr.Methods("GET", "OPTIONS").Path("/file/{path:.*}").HandlerFunc(apiHandler(s.handleGetMedia))
+	sr.Methods("PATCH", "OPTIONS").Path("/file/{path:.*}").HandlerFunc(apiHandler(s.handleUpdateMedia))
 	sr.Methods("GET", "OPTIONS").Path("/files/{path:.*}").HandlerFunc(apiHandler(s.handleGetMultipleMedias))
+	sr.Methods("GET", "OPTIONS").Path("/search/{path:.*}").HandlerFunc(apiHandler(s.handleSearchMedias))
 	sr.Methods("DELETE", "OPTIONS").Path("/delete_bulk").HandlerFunc(apiHandler(s.handleDeleteMultipleMedias))
 	sr.Methods("DELETE", "OPTIONS").Path("/{path:.*}").HandlerFunc(apiHandler(s.handleDeleteMedia))
 
@@ -137,7 +206,7 @@ func (s *ApiServer) Serve() {
 
 	done := make(chan bool)
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-quit
@@ -265,6 +334,337 @@ func (s *ApiServer) handleDeleteKey(w http.ResponseWriter, r *http.Request) erro
 	return writeMessage(w, "successfully deleted apikey")
 }
 
+func (s *ApiServer) handleReadWebhooks(w http.ResponseWriter, r *http.Request) error {
+	webhooks, err := s.tasks.WebhookOperator.GetAll()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, webhooks))
+}
+
+func (s *ApiServer) handleGetWebhook(w http.ResponseWriter, r *http.Request) error {
+	webhook, err := s.tasks.WebhookOperator.Get(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *webhook))
+}
+
+type webhookBody struct {
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (s *ApiServer) handleCreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[webhookBody](w, r)
+	if err != nil {
+		return err
+	}
+	webhook, err := s.tasks.WebhookOperator.Create(b.Url, b.Events)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *webhook))
+}
+
+func (s *ApiServer) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[webhookBody](w, r)
+	if err != nil {
+		return err
+	}
+	webhook, err := s.tasks.WebhookOperator.Update(mux.Vars(r)["id"], b.Url, b.Events)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *webhook))
+}
+
+func (s *ApiServer) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	err := s.tasks.WebhookOperator.Delete(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, "successfully deleted webhook")
+}
+
+func (s *ApiServer) handleReadFolderSettings(w http.ResponseWriter, r *http.Request) error {
+	folders, err := s.tasks.FolderSettingsOperator.GetAll()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, folders))
+}
+
+func (s *ApiServer) handleGetFolderSettings(w http.ResponseWriter, r *http.Request) error {
+	settings, err := s.tasks.FolderSettingsOperator.Get(mux.Vars(r)["path"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, settings))
+}
+
+// handleSaveFolderSettings updates the fields of the body, the ones absent
+// are left unchanged and the null ones are cleared.
+func (s *ApiServer) handleSaveFolderSettings(w http.ResponseWriter, r *http.Request) error {
+	type Body struct {
+		MetadataSchema json.RawMessage `json:"metadata_schema"`
+		Versioning     json.RawMessage `json:"versioning"`
+	}
+	body, err := parseBody[Body](w, r)
+	if err != nil {
+		return err
+	}
+	settings, err := s.tasks.FolderSettingsOperator.Save(mux.Vars(r)["path"], body.MetadataSchema, body.Versioning)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, settings))
+}
+
+func (s *ApiServer) handleListFolder(w http.ResponseWriter, r *http.Request) error {
+	listing, err := s.tasks.FolderOperator.List(mux.Vars(r)["path"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, listing))
+}
+
+func (s *ApiServer) handleCreateFolder(w http.ResponseWriter, r *http.Request) error {
+	settings, err := s.tasks.FolderOperator.Create(mux.Vars(r)["path"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, settings))
+}
+
+func (s *ApiServer) handleMoveFolder(w http.ResponseWriter, r *http.Request) error {
+	type Body struct {
+		Dst string `json:"dst"`
+	}
+	body, err := parseBody[Body](w, r)
+	if err != nil {
+		return err
+	}
+	t, err := s.tasks.FolderOperator.EnqueueMove(mux.Vars(r)["path"], body.Dst)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, t))
+}
+
+func (s *ApiServer) handleDeleteFolder(w http.ResponseWriter, r *http.Request) error {
+	t, err := s.tasks.FolderOperator.EnqueueDelete(mux.Vars(r)["path"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, t))
+}
+
+type collectionBody struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type collectionMediasBody struct {
+	Paths    []string `json:"paths"`
+	Position *int     `json:"position"`
+}
+
+func (s *ApiServer) handleReadCollections(w http.ResponseWriter, r *http.Request) error {
+	collections, err := s.tasks.CollectionOperator.GetAll()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, collections))
+}
+
+func (s *ApiServer) handleCreateCollection(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[collectionBody](w, r)
+	if err != nil {
+		return err
+	}
+	c, err := s.tasks.CollectionOperator.Create(b.Name, b.Description)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleGetCollection(w http.ResponseWriter, r *http.Request) error {
+	c, err := s.tasks.CollectionOperator.Get(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleUpdateCollection(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[collectionBody](w, r)
+	if err != nil {
+		return err
+	}
+	c, err := s.tasks.CollectionOperator.Update(mux.Vars(r)["id"], b.Name, b.Description)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleDeleteCollection(w http.ResponseWriter, r *http.Request) error {
+	err := s.tasks.CollectionOperator.Delete(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, "successfully deleted collection")
+}
+
+func (s *ApiServer) handleAddCollectionMedias(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[collectionMediasBody](w, r)
+	if err != nil {
+		return err
+	}
+	position := -1
+	if b.Position != nil {
+		position = *b.Position
+	}
+	c, err := s.tasks.CollectionOperator.AddMedias(mux.Vars(r)["id"], b.Paths, position)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleRemoveCollectionMedias(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[collectionMediasBody](w, r)
+	if err != nil {
+		return err
+	}
+	c, err := s.tasks.CollectionOperator.RemoveMedias(mux.Vars(r)["id"], b.Paths)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleReorderCollectionMedias(w http.ResponseWriter, r *http.Request) error {
+	b, err := parseBody[collectionMediasBody](w, r)
+	if err != nil {
+		return err
+	}
+	c, err := s.tasks.CollectionOperator.Reorder(mux.Vars(r)["id"], b.Paths)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleSetCollectionCover(w http.ResponseWriter, r *http.Request) error {
+	type Body struct {
+		Path string `json:"path"`
+	}
+	b, err := parseBody[Body](w, r)
+	if err != nil {
+		return err
+	}
+	c, err := s.tasks.CollectionOperator.SetCover(mux.Vars(r)["id"], b.Path)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *c))
+}
+
+func (s *ApiServer) handleDownloadCollection(w http.ResponseWriter, r *http.Request) error {
+	bytes, err := s.tasks.CollectionOperator.Download(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=collection.zip")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(*bytes)
+	return err
+}
+
+func (s *ApiServer) handleDeleteFolderSettings(w http.ResponseWriter, r *http.Request) error {
+	err := s.tasks.FolderSettingsOperator.Delete(mux.Vars(r)["path"])
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, "successfully deleted folder settings")
+}
+
+func (s *ApiServer) handleReadWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	deliveries, err := s.tasks.WebhookOperator.GetDeliveries(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, deliveries))
+}
+
+func (s *ApiServer) handleReadWasmModules(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, encodeJSON(w, s.tasks.WasmModuleOperator.GetAll()))
+}
+
+func (s *ApiServer) handleUploadWasmModule(w http.ResponseWriter, r *http.Request) error {
+	err := s.tasks.WasmModuleOperator.Upload(mux.Vars(r)["name"], r.Body)
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, "successfully uploaded wasm module")
+}
+
+func (s *ApiServer) handleGetTask(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	t, err := s.tasks.TaskOperator.Get(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, t))
+}
+
+func (s *ApiServer) handleStreamTaskEvents(w http.ResponseWriter, r *http.Request) error {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	updates, err := s.tasks.TaskOperator.Watch(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	stream := newEventStream(w)
+	if err := stream.Open(); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// The status is already written, write errors are only logged.
+	for {
+		select {
+		case t, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(string(t.Status), t); err != nil {
+				s.logger.Error(fmt.Sprintf("unable to stream the events of task %s: %v", id, err))
+				return nil
+			}
+		case <-heartbeat.C:
+			if err := stream.Heartbeat(); err != nil {
+				s.logger.Error(fmt.Sprintf("unable to stream the events of task %s: %v", id, err))
+				return nil
+			}
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
+
 func (s *ApiServer) handleReadSpaceUsage(w http.ResponseWriter, r *http.Request) error {
 	spaceUsage, err := s.tasks.AnalyticsOperator.SpaceUsage()
 	if err != nil {
@@ -328,6 +728,8 @@ func (s *ApiServer) handleUploadMedia(w http.ResponseWriter, r *http.Request) er
 		body                  io.Reader
 		filename              string
 		contentType           string
+		customMetadata        media.CustomMetadata
+		expiresAt             *time.Time
 	)
 
 	transformations, imagePath := parsePath(path)
@@ -353,6 +755,24 @@ func (s *ApiServer) handleUploadMedia(w http.ResponseWriter, r *http.Request) er
 			io.Copy(bufio.NewWriter(&b), bufio.NewReader(p))
 			json.Unmarshal(b.Bytes(), &parsedTransformations)
 		}
+		// Like transformations, custom metadata must be sent before the file.
+		if p.FormName() == "custom_metadata" {
+			if err := json.NewDecoder(p).Decode(&customMetadata); err != nil {
+				return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid custom metadata: %w", err)}
+			}
+		}
+		if p.FormName() == "expires_in" {
+			b, err := io.ReadAll(p)
+			if err != nil {
+				return &mindiaerr.Error{ErrCode: mindiaerr.ErrCodeInternal, Msg: err}
+			}
+			d, err := time.ParseDuration(strings.TrimSpace(string(b)))
+			if err != nil || d <= 0 {
+				return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid expires_in %q, a positive duration is expected", b)}
+			}
+			t := time.Now().Add(d)
+			expiresAt = &t
+		}
 		if p.FormName() == "file" {
 			body = bufio.NewReader(p)
 			filename = p.FileName()
@@ -368,12 +788,32 @@ func (s *ApiServer) handleUploadMedia(w http.ResponseWriter, r *http.Request) er
 		}
 	}
 
+	// Uploading to the path of a media replaces its original.
+	if p := media.NewPath(imagePath); p.Uuid() != "" && p.Extension() != "" {
+		if expiresAt != nil {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("expires_in is only supported by new uploads")}
+		}
+		replacedMedia, err := s.tasks.VersionMedia.Upload(
+			p,
+			body,
+			media.ContentType(contentType),
+			parsedTransformations,
+			customMetadata,
+		)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, encodeJSON(w, *replacedMedia))
+	}
+
 	uploadedMedia, err := s.tasks.UploadMedia.Upload(
 		pathutils.JoinPath(imagePath, uuid.New().String()+filepath.Ext(filename)),
 		body,
 		media.ContentType(contentType),
 		0,
 		parsedTransformations,
+		customMetadata,
+		expiresAt,
 	)
 
 	if err != nil {
@@ -400,15 +840,28 @@ func (s *ApiServer) handleMoveMedia(w http.ResponseWriter, r *http.Request) erro
 
 func (s *ApiServer) handleCopyMedia(w http.ResponseWriter, r *http.Request) error {
 	type Body struct {
-		Src string
-		Dst string
+		Src     string
+		Dst     string
+		Derived task.DerivedCopyMode
+		// Recursive copies the folder Src and its sub folders in a task.
+		Recursive bool
 	}
-	var b Body
-	err := json.NewDecoder(r.Body).Decode(&b)
+	b, err := parseBody[Body](w, r)
 	if err != nil {
 		return err
 	}
-	m, err := s.tasks.CopyMedia.Copy(media.NewPath(b.Src), media.NewPath(b.Dst))
+	if b.Recursive {
+		t, err := s.tasks.CopyMedia.EnqueueCopyFolder(task.CopyFolderDetails{
+			Src:         b.Src,
+			Dst:         b.Dst,
+			CopyOptions: task.CopyOptions{Derived: b.Derived},
+		})
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, encodeJSON(w, t))
+	}
+	m, err := s.tasks.CopyMedia.Copy(media.NewPath(b.Src), media.NewPath(b.Dst), task.CopyOptions{Derived: b.Derived})
 	if err != nil {
 		return err
 	}
@@ -425,6 +878,56 @@ func (s *ApiServer) handleTagMedia(w http.ResponseWriter, r *http.Request) error
 	return writeJSON(w, encodeJSON(w, result))
 }
 
+type mediaTagsBody struct {
+	Tags []string `json:"tags"`
+}
+
+func (b *mediaTagsBody) validate() error {
+	if len(b.Tags) == 0 {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("tags are required")}
+	}
+	for _, t := range b.Tags {
+		if media.NormalizeTagValue(t) == "" {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("tags must not be empty")}
+		}
+	}
+	return nil
+}
+
+func (s *ApiServer) handleAddMediaTags(w http.ResponseWriter, r *http.Request) error {
+	path := media.NewPath(toAbsolutePath(mux.Vars(r)["path"]))
+
+	body, err := parseBody[mediaTagsBody](w, r)
+	if err != nil {
+		return err
+	}
+	if err := body.validate(); err != nil {
+		return err
+	}
+	result, err := s.tasks.TagMedia.AddUserTags(path, body.Tags)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, result))
+}
+
+func (s *ApiServer) handleRemoveMediaTags(w http.ResponseWriter, r *http.Request) error {
+	path := media.NewPath(toAbsolutePath(mux.Vars(r)["path"]))
+
+	body, err := parseBody[mediaTagsBody](w, r)
+	if err != nil {
+		return err
+	}
+	if err := body.validate(); err != nil {
+		return err
+	}
+	result, err := s.tasks.TagMedia.RemoveUserTags(path, body.Tags)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, result))
+}
+
 func (s *ApiServer) handleColorizeMedia(w http.ResponseWriter, r *http.Request) error {
 	path := media.NewPath(toAbsolutePath(mux.Vars(r)["path"]))
 
@@ -445,36 +948,129 @@ func (s *ApiServer) handleGetMedia(w http.ResponseWriter, r *http.Request) error
 	return writeJSON(w, encodeJSON(w, file))
 }
 
-func (s *ApiServer) handleGetMultipleMedias(w http.ResponseWriter, r *http.Request) error {
+func (s *ApiServer) handleGetMediaVersions(w http.ResponseWriter, r *http.Request) error {
+	path := media.NewPath(toAbsolutePath(mux.Vars(r)["path"]))
+
+	versions, err := s.tasks.VersionMedia.GetVersions(path)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, versions))
+}
+
+func (s *ApiServer) handleRestoreMediaVersion(w http.ResponseWriter, r *http.Request) error {
+	var (
+		vars = mux.Vars(r)
+		path = media.NewPath(toAbsolutePath(vars["path"]))
+	)
+	m, err := s.tasks.VersionMedia.Restore(path, vars["id"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, *m))
+}
+
+func (s *ApiServer) handleUpdateMedia(w http.ResponseWriter, r *http.Request) error {
+	path := media.NewPath(toAbsolutePath(mux.Vars(r)["path"]))
+
 	type Body struct {
-		Offset int    `schema:"offset"`
-		Limit  int    `schema:"limit"`
+		CustomMetadata media.CustomMetadata `json:"custom_metadata"`
+	}
+	body, err := parseBody[Body](w, r)
+	if err != nil {
+		return err
+	}
+	m, err := s.tasks.UpdateMedia.UpdateCustomMetadata(path, body.CustomMetadata)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, encodeJSON(w, m))
+}
+
+func (s *ApiServer) handleSearchMedias(w http.ResponseWriter, r *http.Request) error {
+	type Body struct {
+		Query  string `schema:"q"`
 		SortBy string `schema:"sort_by"`
+		Cursor string `schema:"cursor"`
+		Limit  int    `schema:"limit"`
+		Facets string `schema:"facets"`
 	}
 	query, err := parseQuery[Body](r.URL)
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+
+	condition, err := media.ParseSearchQuery(query.Query)
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	sort, err := media.ParseSortKeys(query.SortBy)
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	facets := []string{}
+	for _, f := range strings.Split(query.Facets, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			facets = append(facets, f)
+		}
+	}
+
+	result, err := s.tasks.GetMedia.Search(media.SearchQuery{
+		Path:      media.NewPath(toAbsolutePath(mux.Vars(r)["path"])),
+		Condition: condition,
+		Sort:      sort,
+		Cursor:    query.Cursor,
+		Limit:     query.Limit,
+		Facets:    facets,
+	})
 	if err != nil {
 		return err
 	}
+	return writeJSON(w, encodeJSON(w, result))
+}
+
+func (s *ApiServer) handleGetMultipleMedias(w http.ResponseWriter, r *http.Request) error {
+	type Body struct {
+		Cursor        string  `schema:"cursor"`
+		Limit         int     `schema:"limit"`
+		SortBy        string  `schema:"sort_by"`
+		Tags          string  `schema:"tags"`
+		TagsMatch     string  `schema:"tags_match"`
+		MinConfidence float32 `schema:"min_confidence"`
+	}
+	query, err := parseQuery[Body](r.URL)
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
 
-	sortBy := strings.Split(query.SortBy, ":")
-	var ascBool bool
-	if sortBy[1] == "asc" {
-		ascBool = true
-	} else {
-		ascBool = false
+	// Format: "sort_by=created_at:desc,name:asc", newest first by default.
+	sort, err := media.ParseSortKeys(query.SortBy)
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
 	}
 
-	medias, err := s.tasks.GetMedia.GetMultiple(
-		media.NewPath(toAbsolutePath(mux.Vars(r)["path"])),
-		query.Offset,
-		query.Limit,
-		sortBy[0],
-		ascBool,
-	)
+	// Format: "tags=dog,beach&tags_match=all", tags_match defaults to any.
+	tagFilter := media.TagFilter{
+		MatchAll:      query.TagsMatch == "all",
+		MinConfidence: query.MinConfidence,
+	}
+	for _, t := range strings.Split(query.Tags, ",") {
+		if t = media.NormalizeTagValue(t); t != "" {
+			tagFilter.Values = append(tagFilter.Values, t)
+		}
+	}
+
+	result, err := s.tasks.GetMedia.GetMultiple(media.ListQuery{
+		Path:      media.NewPath(toAbsolutePath(mux.Vars(r)["path"])),
+		TagFilter: tagFilter,
+		Sort:      sort,
+		Cursor:    query.Cursor,
+		Limit:     query.Limit,
+	})
 	if err != nil {
 		return err
 	}
-	return writeJSON(w, encodeJSON(w, medias))
+	return writeJSON(w, encodeJSON(w, result))
 }
 
 func (s *ApiServer) handleDeleteMedia(w http.ResponseWriter, r *http.Request) error {
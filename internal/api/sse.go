@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+type eventStream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	lastId int
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	return &eventStream{
+		w:  w,
+		rc: http.NewResponseController(w),
+	}
+}
+
+func (s *eventStream) Open() error {
+	// Streams outlive the server write timeout.
+	if err := s.rc.SetWriteDeadline(time.Time{}); err != nil {
+		return err
+	}
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("Connection", "keep-alive")
+	s.w.WriteHeader(http.StatusOK)
+	return s.rc.Flush()
+}
+
+func (s *eventStream) Send(event string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	s.lastId++
+	_, err = fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.lastId, event, data)
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *eventStream) Heartbeat() error {
+	_, err := fmt.Fprint(s.w, ": heartbeat\n\n")
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
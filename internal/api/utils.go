@@ -92,11 +92,8 @@ func parseQuery[T any](url *url.URL) (*T, error) {
 		params  T
 		decoder = schema.NewDecoder()
 	)
-	u, err := url.Parse(url.RawPath)
-	if err != nil {
-		return nil, err
-	}
-	err = decoder.Decode(&params, u.Query())
+	decoder.IgnoreUnknownKeys(true)
+	err := decoder.Decode(&params, url.Query())
 	if err != nil {
 		return nil, err
 	}
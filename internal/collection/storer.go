@@ -0,0 +1,14 @@
+package collection
+
+type Storer interface {
+	GetAll() ([]Collection, error)
+	Get(id string) (*Collection, error)
+	// GetByMedia returns the collections the media is a member or the cover
+	// of.
+	GetByMedia(path string) ([]Collection, error)
+	Save(c Collection) error
+	// Update applies fn to the collection then saves it, concurrent updates
+	// of the collection can't be lost.
+	Update(id string, fn func(c *Collection) error) (*Collection, error)
+	Delete(id string) error
+}
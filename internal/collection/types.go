@@ -0,0 +1,37 @@
+package collection
+
+import (
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Collection groups medias from any folder in a curated order.
+type Collection struct {
+	Id          string `json:"id" yaml:"id"`
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+	// Medias are the paths of the members, in their order.
+	Medias    []string  `json:"medias" yaml:"medias"`
+	Cover     string    `json:"cover,omitempty" yaml:"cover"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+func (c *Collection) Contains(path string) bool {
+	return slices.Contains(c.Medias, path)
+}
+
+// Refers tells whether the media is a member or the cover of the collection.
+func (c *Collection) Refers(path string) bool {
+	return c.Cover == path || c.Contains(path)
+}
+
+// Paths returns the paths of the members and of the cover.
+func (c *Collection) Paths() []string {
+	paths := slices.Clone(c.Medias)
+	if c.Cover != "" && !c.Contains(c.Cover) {
+		paths = append(paths, c.Cover)
+	}
+	return paths
+}
@@ -1,10 +1,17 @@
 package config
 
+import "time"
+
 type Config struct {
 	MasterKey string          `yaml:"-"`
 	Server    ServerConfig    `yaml:"api,omitempty" validate:"required"`
 	Storage   StorageConfig   `yaml:"storage,omitempty" validate:"required"`
 	Adapters  AdapatersConfig `yaml:"adapter,omitempty" validate:"required"`
+	Scheduler SchedulerConfig `yaml:"scheduler,omitempty"`
+	Events    EventsConfig    `yaml:"events,omitempty"`
+	Plugins   PluginsConfig   `yaml:"plugins,omitempty"`
+	Wasm      WasmConfig      `yaml:"wasm,omitempty"`
+	Tagging   TaggingConfig   `yaml:"tagging,omitempty"`
 }
 
 func NewConfig() Config {
@@ -21,7 +28,29 @@ func NewConfig() Config {
 			NamedTransforationStorage: NamedTransforationStorageConfig{},
 			ApiKeyStorage:             ApiKeyStorageConfig{},
 			TaskStorage:               TaskStorageConfig{},
+			WebhookStorage:            WebhookStorageConfig{},
+			FolderStorage:             FolderStorageConfig{},
+			JournalStorage:            JournalStorageConfig{},
+			CollectionStorage:         CollectionStorageConfig{},
 		},
 		Adapters: AdapatersConfig{},
+		Scheduler: SchedulerConfig{
+			Workers:           4,
+			DrainTimeout:      30 * time.Second,
+			ConcurrencyLimits: map[string]int{},
+			RecurringJobs: map[string]string{
+				"storage_usage":     "*/5 * * * *",
+				"resume_operations": "* * * * *",
+			},
+		},
+		Plugins: PluginsConfig{},
+		Wasm: WasmConfig{
+			ModulesDir:       "wasm_modules",
+			MemoryLimitPages: 256,
+			Timeout:          5 * time.Second,
+		},
+		Tagging: TaggingConfig{
+			Provider: "local",
+		},
 	}
 }
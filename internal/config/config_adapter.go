@@ -13,7 +13,13 @@ type RedisAdapterConfig struct {
 	Password string `yaml:"password,omitempty" validate:""`
 }
 
+type PostgresAdapterConfig struct {
+	Url      string `yaml:"url,omitempty" validate:"required"`
+	MaxConns int    `yaml:"max_conns,omitempty"`
+}
+
 type AdapatersConfig struct {
-	S3    *S3AdapterConfig    `yaml:"s3,omitempty"`
-	Redis *RedisAdapterConfig `yaml:"redis,omitempty"`
+	S3       *S3AdapterConfig       `yaml:"s3,omitempty"`
+	Redis    *RedisAdapterConfig    `yaml:"redis,omitempty"`
+	Postgres *PostgresAdapterConfig `yaml:"postgres,omitempty"`
 }
@@ -0,0 +1,17 @@
+package config
+
+type RedisEventSinkConfig struct {
+	Channel string `yaml:"channel,omitempty"`
+}
+
+type NatsEventSinkConfig struct {
+	Url     string `yaml:"url,omitempty" validate:"required"`
+	Subject string `yaml:"subject,omitempty"`
+}
+
+// EventsConfig lists the sinks events are published to besides the
+// in-process subscribers.
+type EventsConfig struct {
+	Redis *RedisEventSinkConfig `yaml:"redis,omitempty"`
+	Nats  *NatsEventSinkConfig  `yaml:"nats,omitempty"`
+}
@@ -0,0 +1,9 @@
+package config
+
+type PluginConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// PluginsConfig maps a plugin name to its configuration.
+type PluginsConfig = map[string]PluginConfig
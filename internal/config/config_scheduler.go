@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+type SchedulerConfig struct {
+	Workers           int            `yaml:"workers,omitempty"`
+	DrainTimeout      time.Duration  `yaml:"drain_timeout,omitempty"`
+	ConcurrencyLimits map[string]int `yaml:"concurrency_limits,omitempty"`
+	// RecurringJobs maps a task name, or orphan_cleanup which deletes the
+	// files fsck finds orphaned, to the cron expression it must run at. The
+	// jobs deleting data, evict_cache, orphan_cleanup and expire_uploads, are
+	// not scheduled by default.
+	RecurringJobs map[string]string `yaml:"recurring_jobs,omitempty"`
+}
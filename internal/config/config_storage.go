@@ -13,8 +13,14 @@ type FileStorageConfig struct {
 	S3StorageConfig         *S3StorageConfig         `yaml:"s3,omitempty"`
 }
 
+type SqliteStorageConfig struct {
+	Path string `yaml:"path" validate:"required"`
+}
+
 type MetadataStorageConfig struct {
-	Redis *string `yaml:"redis"`
+	Redis    *string              `yaml:"redis"`
+	Sqlite   *SqliteStorageConfig `yaml:"sqlite,omitempty"`
+	Postgres *string              `yaml:"postgres"`
 }
 
 type MediaStorageConfig struct {
@@ -26,16 +32,41 @@ type MediaStorageConfig struct {
 type NamedTransforationStorageConfig struct {
 	Filesystem *string `yaml:"filesystem"`
 	Redis      *string `yaml:"redis"`
+	Postgres   *string `yaml:"postgres"`
 }
 
 type ApiKeyStorageConfig struct {
 	Filesystem *string `yaml:"filesystem"`
 	Redis      *string `yaml:"redis"`
+	Postgres   *string `yaml:"postgres"`
 }
 
 type TaskStorageConfig struct {
 	Filesystem *string `yaml:"filesystem"`
 	Redis      *string `yaml:"redis"`
+	Postgres   *string `yaml:"postgres"`
+}
+
+type WebhookStorageConfig struct {
+	Filesystem *string `yaml:"filesystem"`
+	Redis      *string `yaml:"redis"`
+}
+
+type FolderStorageConfig struct {
+	Filesystem *string `yaml:"filesystem"`
+	Redis      *string `yaml:"redis"`
+}
+
+type JournalStorageConfig struct {
+	Filesystem *string `yaml:"filesystem"`
+	Redis      *string `yaml:"redis"`
+	Postgres   *string `yaml:"postgres"`
+}
+
+type CollectionStorageConfig struct {
+	Filesystem *string `yaml:"filesystem"`
+	Redis      *string `yaml:"redis"`
+	Postgres   *string `yaml:"postgres"`
 }
 
 type StorageConfig struct {
@@ -43,4 +74,8 @@ type StorageConfig struct {
 	NamedTransforationStorage NamedTransforationStorageConfig `yaml:"named_transformation" validate:"required"`
 	ApiKeyStorage             ApiKeyStorageConfig             `yaml:"apikey" validate:"required"`
 	TaskStorage               TaskStorageConfig               `yaml:"task" validate:"required"`
+	WebhookStorage            WebhookStorageConfig            `yaml:"webhook"`
+	FolderStorage             FolderStorageConfig             `yaml:"folder"`
+	JournalStorage            JournalStorageConfig            `yaml:"journal"`
+	CollectionStorage         CollectionStorageConfig         `yaml:"collection"`
 }
@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+type HttpTaggerConfig struct {
+	Url     string        `yaml:"url,omitempty" validate:"required"`
+	Token   string        `yaml:"-"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// TaggingConfig selects the provider used to tag the medias: "local",
+// "google" or "http".
+type TaggingConfig struct {
+	Provider string            `yaml:"provider,omitempty"`
+	Http     *HttpTaggerConfig `yaml:"http,omitempty"`
+}
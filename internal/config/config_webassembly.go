@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type WasmConfig struct {
+	// ModulesDir is the directory WASM transformation modules are loaded from
+	// and uploaded to.
+	ModulesDir       string        `yaml:"modules_dir,omitempty"`
+	MemoryLimitPages uint32        `yaml:"memory_limit_pages,omitempty"`
+	Timeout          time.Duration `yaml:"timeout,omitempty"`
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -10,12 +12,25 @@ import (
 const fileName = "config.yml"
 
 type FilesystemStorage struct {
+	envPrefix string
 }
 
 func NewFilesystemStorage() FilesystemStorage {
 	return FilesystemStorage{}
 }
 
+// NewPrefixedFilesystemStorage reads the environment variables prefixed by
+// envPrefix, e.g. TARGET_REDIS_HOST, to describe a second set of storages.
+func NewPrefixedFilesystemStorage(envPrefix string) FilesystemStorage {
+	return FilesystemStorage{
+		envPrefix: envPrefix,
+	}
+}
+
+func (c *FilesystemStorage) lookupEnv(key string) (string, bool) {
+	return os.LookupEnv(c.envPrefix + key)
+}
+
 func (c *FilesystemStorage) LoadConfig() (*Config, error) {
 	config := NewConfig()
 
@@ -29,46 +44,46 @@ func (c *FilesystemStorage) LoadConfig() (*Config, error) {
 	   		return nil, err
 	   	}
 	*/
-	apiHost, isEnv := os.LookupEnv("API_HOST")
+	apiHost, isEnv := c.lookupEnv("API_HOST")
 	if isEnv {
 		config.Server.HttpApiConfig.Host = apiHost
 	}
-	apiPort, isEnv := os.LookupEnv("API_PORT")
+	apiPort, isEnv := c.lookupEnv("API_PORT")
 	if isEnv {
 		port, _ := strconv.Atoi(apiPort)
 		config.Server.HttpApiConfig.Port = port
 	}
 
-	s3AccessKeyId, isEnv := os.LookupEnv("S3_ACCESS_KEY_ID")
+	s3AccessKeyId, isEnv := c.lookupEnv("S3_ACCESS_KEY_ID")
 	if isEnv {
 		config.Adapters.S3 = &S3AdapterConfig{
 			AccessKeyId: s3AccessKeyId,
 		}
-		s3SecretAccessKey, isEnv := os.LookupEnv("S3_SECRET_ACCESS_KEY")
+		s3SecretAccessKey, isEnv := c.lookupEnv("S3_SECRET_ACCESS_KEY")
 		if isEnv {
 			config.Adapters.S3.SecretAccessKey = s3SecretAccessKey
 		}
-		s3Endpoint, isEnv := os.LookupEnv("S3_ENDPOINT")
+		s3Endpoint, isEnv := c.lookupEnv("S3_ENDPOINT")
 		if isEnv {
 			config.Adapters.S3.Endpoint = s3Endpoint
 		}
-		s3Region, isEnv := os.LookupEnv("S3_REGION")
+		s3Region, isEnv := c.lookupEnv("S3_REGION")
 		if isEnv {
 			config.Adapters.S3.Region = s3Region
 		}
 	}
 
-	redisHost, isEnv := os.LookupEnv("REDIS_HOST")
+	redisHost, isEnv := c.lookupEnv("REDIS_HOST")
 	if isEnv {
 		config.Adapters.Redis = &RedisAdapterConfig{
 			Host: redisHost,
 		}
-		redisPort, isEnv := os.LookupEnv("REDIS_PORT")
+		redisPort, isEnv := c.lookupEnv("REDIS_PORT")
 		if isEnv {
 			port, _ := strconv.Atoi(redisPort)
 			config.Adapters.Redis.Port = port
 		}
-		redisPassword, isEnv := os.LookupEnv("REDIS_PASSWORD")
+		redisPassword, isEnv := c.lookupEnv("REDIS_PASSWORD")
 		if isEnv {
 			config.Adapters.Redis.Password = redisPassword
 		}
@@ -78,19 +93,226 @@ func (c *FilesystemStorage) LoadConfig() (*Config, error) {
 		config.Storage.TaskStorage.Redis = &redis
 		config.Storage.NamedTransforationStorage.Redis = &redis
 		config.Storage.ApiKeyStorage.Redis = &redis
+		config.Storage.WebhookStorage.Redis = &redis
+		config.Storage.FolderStorage.Redis = &redis
+		config.Storage.JournalStorage.Redis = &redis
+		config.Storage.CollectionStorage.Redis = &redis
+	}
+
+	postgresUrl, isEnv := c.lookupEnv("POSTGRES_URL")
+	if isEnv {
+		config.Adapters.Postgres = &PostgresAdapterConfig{
+			Url: postgresUrl,
+		}
+		postgresMaxConns, isEnv := c.lookupEnv("POSTGRES_MAX_CONNS")
+		if isEnv {
+			maxConns, _ := strconv.Atoi(postgresMaxConns)
+			config.Adapters.Postgres.MaxConns = maxConns
+		}
+
+		postgres := ""
+		config.Storage.MediaStorage.MetadataStorage.Postgres = &postgres
+		config.Storage.MediaStorage.MetadataStorage.Redis = nil
+		config.Storage.TaskStorage.Postgres = &postgres
+		config.Storage.TaskStorage.Redis = nil
+		config.Storage.NamedTransforationStorage.Postgres = &postgres
+		config.Storage.NamedTransforationStorage.Redis = nil
+		config.Storage.ApiKeyStorage.Postgres = &postgres
+		config.Storage.ApiKeyStorage.Redis = nil
+		config.Storage.JournalStorage.Postgres = &postgres
+		config.Storage.JournalStorage.Redis = nil
+		config.Storage.CollectionStorage.Postgres = &postgres
+		config.Storage.CollectionStorage.Redis = nil
 	}
 
-	fileBucketName, isEnv := os.LookupEnv("FILE_BUCKET_NAME")
+	metadataSqlitePath, isEnv := c.lookupEnv("METADATA_SQLITE_PATH")
+	if isEnv {
+		config.Storage.MediaStorage.MetadataStorage.Sqlite = &SqliteStorageConfig{
+			Path: metadataSqlitePath,
+		}
+		config.Storage.MediaStorage.MetadataStorage.Redis = nil
+		config.Storage.MediaStorage.MetadataStorage.Postgres = nil
+	}
+
+	taskStorageFile, isEnv := c.lookupEnv("TASK_STORAGE_FILE")
+	if isEnv || (config.Storage.TaskStorage.Redis == nil && config.Storage.TaskStorage.Postgres == nil) {
+		config.Storage.TaskStorage.Filesystem = &taskStorageFile
+		config.Storage.TaskStorage.Redis = nil
+		config.Storage.TaskStorage.Postgres = nil
+	}
+
+	journalStorageFile, isEnv := c.lookupEnv("JOURNAL_STORAGE_FILE")
+	if isEnv || (config.Storage.JournalStorage.Redis == nil && config.Storage.JournalStorage.Postgres == nil) {
+		config.Storage.JournalStorage.Filesystem = &journalStorageFile
+		config.Storage.JournalStorage.Redis = nil
+		config.Storage.JournalStorage.Postgres = nil
+	}
+
+	if config.Storage.WebhookStorage.Redis == nil {
+		filesystem := ""
+		config.Storage.WebhookStorage.Filesystem = &filesystem
+	}
+
+	if config.Storage.FolderStorage.Redis == nil {
+		filesystem := ""
+		config.Storage.FolderStorage.Filesystem = &filesystem
+	}
+
+	if config.Storage.CollectionStorage.Redis == nil && config.Storage.CollectionStorage.Postgres == nil {
+		filesystem := ""
+		config.Storage.CollectionStorage.Filesystem = &filesystem
+	}
+
+	fileMountDir, isEnv := c.lookupEnv("FILE_MOUNT_DIR")
+	if isEnv {
+		config.Storage.MediaStorage.FileStorage.FilesystemStorageConfig = &FilesystemStorageConfig{
+			MountDir: fileMountDir,
+		}
+	}
+	cacheMountDir, isEnv := c.lookupEnv("CACHE_MOUNT_DIR")
+	if isEnv {
+		config.Storage.MediaStorage.CacheStorage.FilesystemStorageConfig = &FilesystemStorageConfig{
+			MountDir: cacheMountDir,
+		}
+	}
+	fileBucketName, isEnv := c.lookupEnv("FILE_BUCKET_NAME")
 	if isEnv {
 		config.Storage.MediaStorage.FileStorage.S3StorageConfig = &S3StorageConfig{}
 		config.Storage.MediaStorage.FileStorage.S3StorageConfig.Bucket = fileBucketName
 	}
-	cacheBucketName, isEnv := os.LookupEnv("CACHE_BUCKET_NAME")
+	cacheBucketName, isEnv := c.lookupEnv("CACHE_BUCKET_NAME")
 	if isEnv {
 		config.Storage.MediaStorage.CacheStorage.S3StorageConfig = &S3StorageConfig{}
 		config.Storage.MediaStorage.CacheStorage.S3StorageConfig.Bucket = cacheBucketName
 	}
 
+	schedulerWorkers, isEnv := c.lookupEnv("SCHEDULER_WORKERS")
+	if isEnv {
+		workers, _ := strconv.Atoi(schedulerWorkers)
+		config.Scheduler.Workers = workers
+	}
+	schedulerDrainTimeout, isEnv := c.lookupEnv("SCHEDULER_DRAIN_TIMEOUT")
+	if isEnv {
+		drainTimeout, err := time.ParseDuration(schedulerDrainTimeout)
+		if err != nil {
+			return nil, err
+		}
+		config.Scheduler.DrainTimeout = drainTimeout
+	}
+	schedulerConcurrencyLimits, isEnv := c.lookupEnv("SCHEDULER_CONCURRENCY_LIMITS")
+	if isEnv {
+		// Format: "task_name=limit,other_task=limit"
+		for _, l := range strings.Split(schedulerConcurrencyLimits, ",") {
+			parts := strings.SplitN(strings.TrimSpace(l), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			limit, _ := strconv.Atoi(parts[1])
+			config.Scheduler.ConcurrencyLimits[parts[0]] = limit
+		}
+	}
+
+	schedulerRecurringJobs, isEnv := c.lookupEnv("SCHEDULER_RECURRING_JOBS")
+	if isEnv {
+		// Format: "task_name=cron expression;other_task=cron expression"
+		for _, j := range strings.Split(schedulerRecurringJobs, ";") {
+			parts := strings.SplitN(strings.TrimSpace(j), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			config.Scheduler.RecurringJobs[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	eventsRedisChannel, isEnv := c.lookupEnv("EVENTS_REDIS_CHANNEL")
+	if isEnv {
+		config.Events.Redis = &RedisEventSinkConfig{
+			Channel: eventsRedisChannel,
+		}
+	}
+	natsUrl, isEnv := c.lookupEnv("NATS_URL")
+	if isEnv {
+		natsSubject, _ := c.lookupEnv("NATS_SUBJECT")
+		config.Events.Nats = &NatsEventSinkConfig{
+			Url:     natsUrl,
+			Subject: natsSubject,
+		}
+	}
+
+	plugins, isEnv := c.lookupEnv("PLUGINS")
+	if isEnv {
+		// Format: "plugin,other_plugin", only the listed plugins are enabled.
+		config.Plugins = PluginsConfig{}
+		for _, name := range strings.Split(plugins, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				config.Plugins[name] = PluginConfig{Enabled: true}
+			}
+		}
+	}
+	pluginPrefix := c.envPrefix + "PLUGIN_"
+	for _, env := range os.Environ() {
+		// Format: "PLUGIN_<NAME>_<OPTION>=value"
+		kv := strings.SplitN(env, "=", 2)
+		if !strings.HasPrefix(kv[0], pluginPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(kv[0], pluginPrefix), "_", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.ToLower(parts[0])
+		pluginConfig, ok := config.Plugins[name]
+		if !ok {
+			continue
+		}
+		if pluginConfig.Options == nil {
+			pluginConfig.Options = map[string]string{}
+		}
+		pluginConfig.Options[strings.ToLower(parts[1])] = kv[1]
+		config.Plugins[name] = pluginConfig
+	}
+
+	wasmModulesDir, isEnv := c.lookupEnv("WASM_MODULES_DIR")
+	if isEnv {
+		config.Wasm.ModulesDir = wasmModulesDir
+	}
+	wasmMemoryLimitPages, isEnv := c.lookupEnv("WASM_MEMORY_LIMIT_PAGES")
+	if isEnv {
+		pages, _ := strconv.Atoi(wasmMemoryLimitPages)
+		config.Wasm.MemoryLimitPages = uint32(pages)
+	}
+	wasmTimeout, isEnv := c.lookupEnv("WASM_TIMEOUT")
+	if isEnv {
+		timeout, err := time.ParseDuration(wasmTimeout)
+		if err != nil {
+			return nil, err
+		}
+		config.Wasm.Timeout = timeout
+	}
+
+	tagger, isEnv := c.lookupEnv("TAGGER")
+	if isEnv {
+		config.Tagging.Provider = tagger
+	}
+	taggerUrl, isEnv := c.lookupEnv("TAGGER_HTTP_URL")
+	if isEnv {
+		taggerToken, _ := c.lookupEnv("TAGGER_HTTP_TOKEN")
+		config.Tagging.Http = &HttpTaggerConfig{
+			Url:     taggerUrl,
+			Token:   taggerToken,
+			Timeout: 30 * time.Second,
+		}
+		taggerTimeout, isEnv := c.lookupEnv("TAGGER_HTTP_TIMEOUT")
+		if isEnv {
+			timeout, err := time.ParseDuration(taggerTimeout)
+			if err != nil {
+				return nil, err
+			}
+			config.Tagging.Http.Timeout = timeout
+		}
+	}
+
 	config.MasterKey = os.Getenv("MASTER_KEY")
 
 	return &config, nil
@@ -13,6 +13,12 @@ const (
 	ErrCodeTransformationNotFound
 	ErrCodeUnauthorizedRequest
 	ErrCodeServiceUnavailable
+	ErrCodeTaskNotFound
+	ErrCodeWebhookNotFound
+	ErrCodePluginNotFound
+	ErrCodeFolderNotFound
+	ErrCodeCollectionNotFound
+	ErrCodeVersionNotFound
 )
 
 func (e ErrCode) Code() string {
@@ -31,6 +37,18 @@ func (e ErrCode) Code() string {
 		return "err_unauthorized_request"
 	case ErrCodeServiceUnavailable:
 		return "err_service_unavailable"
+	case ErrCodeTaskNotFound:
+		return "err_task_not_found"
+	case ErrCodeWebhookNotFound:
+		return "err_webhook_not_found"
+	case ErrCodePluginNotFound:
+		return "err_plugin_not_found"
+	case ErrCodeFolderNotFound:
+		return "err_folder_not_found"
+	case ErrCodeCollectionNotFound:
+		return "err_collection_not_found"
+	case ErrCodeVersionNotFound:
+		return "err_version_not_found"
 	case ErrBadRequest:
 		return "err_bad_request"
 	case ErrCodeInternal:
@@ -56,6 +74,18 @@ func (e ErrCode) RawMessage() string {
 		return "unauthroized request"
 	case ErrCodeServiceUnavailable:
 		return "service (temporarely) unavailable"
+	case ErrCodeTaskNotFound:
+		return "unable to find the task"
+	case ErrCodeWebhookNotFound:
+		return "unable to find the webhook"
+	case ErrCodePluginNotFound:
+		return "unable to find the plugin"
+	case ErrCodeFolderNotFound:
+		return "unable to find the folder"
+	case ErrCodeCollectionNotFound:
+		return "unable to find the collection"
+	case ErrCodeVersionNotFound:
+		return "unable to find the version"
 	case ErrBadRequest:
 		return "bad request"
 	case ErrCodeInternal:
@@ -0,0 +1,44 @@
+package event
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+type Handler func(e Event)
+
+type Sink interface {
+	Name() string
+	Publish(e Event) error
+}
+
+// Bus publishes domain events to the in-process subscribers and to every
+// registered sink. A sink failing never fails the operation that emitted the
+// event.
+type Bus struct {
+	local *LocalSink
+	sinks []Sink
+}
+
+func NewBus(sinks ...Sink) *Bus {
+	local := NewLocalSink()
+	return &Bus{
+		local: local,
+		sinks: append([]Sink{local}, sinks...),
+	}
+}
+
+func (b *Bus) Subscribe(t Type, handler Handler) {
+	b.local.Subscribe(t, handler)
+}
+
+func (b *Bus) Publish(t Type, data interface{}) {
+	if b == nil {
+		return
+	}
+	e := New(t, data)
+	for _, s := range b.sinks {
+		if err := s.Publish(e); err != nil {
+			log.Warn().Err(err).Msgf("unable to publish %s to %s sink", e.Type, s.Name())
+		}
+	}
+}
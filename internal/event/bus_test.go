@@ -0,0 +1,29 @@
+package event
+
+import (
+	"errors"
+	"testing"
+)
+
+type failingSink struct{}
+
+func (s failingSink) Name() string          { return "failing" }
+func (s failingSink) Publish(e Event) error { return errors.New("unavailable") }
+
+func TestBusDispatchesToSubscribers(t *testing.T) {
+	b := NewBus(failingSink{})
+
+	got := map[string][]Type{}
+	b.Subscribe(MediaUploaded, func(e Event) { got["uploaded"] = append(got["uploaded"], e.Type) })
+	b.Subscribe(AllEvents, func(e Event) { got["all"] = append(got["all"], e.Type) })
+
+	b.Publish(MediaUploaded, nil)
+	b.Publish(CacheCleared, nil)
+
+	if len(got["uploaded"]) != 1 {
+		t.Errorf("got %v, wanted a single media.uploaded event", got["uploaded"])
+	}
+	if len(got["all"]) != 2 {
+		t.Errorf("got %v, wanted every published event", got["all"])
+	}
+}
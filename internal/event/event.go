@@ -0,0 +1,55 @@
+package event
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+type Type = string
+
+const (
+	MediaUploaded  Type = "media.uploaded"
+	DerivedCreated Type = "derived.created"
+	MediaDeleted   Type = "media.deleted"
+	MediaMoved     Type = "media.moved"
+	MediaCopied    Type = "media.copied"
+	MediaReplaced  Type = "media.replaced"
+	CacheCleared   Type = "cache.cleared"
+	TaskFinished   Type = "task.finished"
+)
+
+// AllEvents subscribes a handler to every event type.
+const AllEvents Type = "*"
+
+var Types = []Type{
+	MediaUploaded,
+	DerivedCreated,
+	MediaDeleted,
+	MediaMoved,
+	MediaCopied,
+	MediaReplaced,
+	CacheCleared,
+	TaskFinished,
+}
+
+func IsTypeSupported(t Type) bool {
+	return slices.Contains(Types, t)
+}
+
+type Event struct {
+	Id         string      `json:"id"`
+	Type       Type        `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+func New(t Type, data interface{}) Event {
+	return Event{
+		Id:         uuid.New().String(),
+		Type:       t,
+		OccurredAt: time.Now(),
+		Data:       data,
+	}
+}
@@ -0,0 +1,37 @@
+package event
+
+import "sync"
+
+// LocalSink dispatches events to in-process handlers. Handlers are called
+// synchronously and must hand off any slow work, e.g. by enqueuing a task.
+type LocalSink struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+}
+
+func NewLocalSink() *LocalSink {
+	return &LocalSink{
+		handlers: map[Type][]Handler{},
+	}
+}
+
+func (s *LocalSink) Name() string {
+	return "local"
+}
+
+func (s *LocalSink) Subscribe(t Type, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[t] = append(s.handlers[t], handler)
+}
+
+func (s *LocalSink) Publish(e Event) error {
+	s.mu.RLock()
+	handlers := append(append([]Handler{}, s.handlers[e.Type]...), s.handlers[AllEvents]...)
+	s.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+	return nil
+}
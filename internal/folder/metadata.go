@@ -0,0 +1,66 @@
+package folder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func CompileSchema(schema json.RawMessage) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile("schema.json")
+}
+
+type MetadataValidator struct {
+	storer Storer
+}
+
+func NewMetadataValidator(storer Storer) MetadataValidator {
+	return MetadataValidator{
+		storer,
+	}
+}
+
+// Validate validates the custom metadata of a media against the schema of
+// the closest folder having one.
+func (v *MetadataValidator) Validate(path media.Path, metadata media.CustomMetadata) error {
+	schema, err := v.findSchema(path.Dir())
+	if err != nil || schema == nil {
+		return err
+	}
+
+	// Values are decoded the way the schema validator expects them.
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if metadata == nil {
+		doc = map[string]interface{}{}
+	}
+
+	if err := schema.Validate(doc); err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid custom metadata: %w", err)}
+	}
+	return nil
+}
+
+func (v *MetadataValidator) findSchema(dir string) (*jsonschema.Schema, error) {
+	settings, err := Closest(v.storer, dir, func(s *Settings) bool {
+		return len(s.MetadataSchema) > 0
+	})
+	if err != nil || settings == nil {
+		return nil, err
+	}
+	return CompileSchema(settings.MetadataSchema)
+}
@@ -0,0 +1,61 @@
+package folder
+
+import (
+	"encoding/json"
+	"testing"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+type memoryStorer map[string]Settings
+
+func (s memoryStorer) GetAll() ([]Settings, error) { return nil, nil }
+
+func (s memoryStorer) Get(path string) (*Settings, error) {
+	if settings, ok := s[path]; ok {
+		return &settings, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeFolderNotFound)
+}
+
+func (s memoryStorer) Save(settings Settings) error { return nil }
+
+func (s memoryStorer) Delete(path string) error { return nil }
+
+func TestParents(t *testing.T) {
+	parents := Parents("/products/shoes/")
+	if len(parents) != 3 || parents[0] != "/products/shoes" || parents[1] != "/products" || parents[2] != "/" {
+		t.Errorf("unexpected parents %v", parents)
+	}
+}
+
+func TestMetadataValidator(t *testing.T) {
+	v := NewMetadataValidator(memoryStorer{
+		"/products": {
+			Path:           "/products",
+			MetadataSchema: json.RawMessage(`{"type": "object", "required": ["sku"], "properties": {"sku": {"type": "string"}}}`),
+		},
+		"/products/drafts": {
+			Path: "/products/drafts",
+		},
+	})
+
+	cases := []struct {
+		path     string
+		metadata media.CustomMetadata
+		valid    bool
+	}{
+		{"/blog/a.jpg", nil, true},
+		{"/products/a.jpg", media.CustomMetadata{"sku": "A-1"}, true},
+		{"/products/shoes/a.jpg", media.CustomMetadata{"sku": 12}, false},
+		{"/products/drafts/a.jpg", nil, false},
+		{"/products/a.jpg", nil, false},
+	}
+	for _, c := range cases {
+		err := v.Validate(media.NewPath(c.path), c.metadata)
+		if (err == nil) != c.valid {
+			t.Errorf("%s %v: got %v", c.path, c.metadata, err)
+		}
+	}
+}
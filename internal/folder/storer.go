@@ -0,0 +1,8 @@
+package folder
+
+type Storer interface {
+	GetAll() ([]Settings, error)
+	Get(path string) (*Settings, error)
+	Save(settings Settings) error
+	Delete(path string) error
+}
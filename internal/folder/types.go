@@ -0,0 +1,57 @@
+package folder
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+)
+
+// Settings are the settings of a folder, they apply to the medias of the
+// folder and of its sub folders unless a sub folder has its own settings.
+type Settings struct {
+	Path string `json:"path"`
+	// MetadataSchema is the JSON schema the custom metadata of the medias
+	// must be valid against.
+	MetadataSchema json.RawMessage `json:"metadata_schema,omitempty"`
+	// Versioning keeps the previous originals of the medias uploaded again,
+	// a sub folder can disable it.
+	Versioning *Versioning `json:"versioning,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// CleanPath returns the canonical path of a folder, "/" for the root.
+func CleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Parents returns the folder and its parents, from the deepest to the root.
+func Parents(p string) []string {
+	p = CleanPath(p)
+	parents := []string{p}
+	for p != "/" {
+		p = path.Dir(p)
+		parents = append(parents, p)
+	}
+	return parents
+}
+
+// Closest returns the settings of the folder or of its closest parent
+// matching has, nil when there are none.
+func Closest(storer Storer, dir string, has func(s *Settings) bool) (*Settings, error) {
+	for _, p := range Parents(dir) {
+		settings, err := storer.Get(p)
+		if err != nil {
+			if e, ok := err.(*mindiaerr.Error); ok && e.ErrCode == mindiaerr.ErrCodeFolderNotFound {
+				continue
+			}
+			return nil, err
+		}
+		if has(settings) {
+			return settings, nil
+		}
+	}
+	return nil, nil
+}
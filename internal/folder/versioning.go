@@ -0,0 +1,41 @@
+package folder
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+type Versioning struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// MaxVersions is the number of versions kept by media, 0 keeps them all.
+	MaxVersions int `json:"max_versions,omitempty" yaml:"max_versions,omitempty"`
+	// MaxAgeDays is the number of days a version is kept, 0 keeps them
+	// forever. Versions are only pruned when a new one is created.
+	MaxAgeDays int `json:"max_age_days,omitempty" yaml:"max_age_days,omitempty"`
+}
+
+func (v *Versioning) Validate() error {
+	if v.MaxVersions < 0 || v.MaxAgeDays < 0 {
+		return errors.New("retention limits can't be negative")
+	}
+	return nil
+}
+
+// Prune splits the versions, oldest first, into the ones the retention
+// limits keep and the others.
+func (v *Versioning) Prune(versions []media.Version, now time.Time) (kept []media.Version, pruned []media.Version) {
+	kept = []media.Version{}
+	pruned = []media.Version{}
+	for i, version := range versions {
+		tooMany := v.MaxVersions > 0 && len(versions)-i > v.MaxVersions
+		tooOld := v.MaxAgeDays > 0 && now.Sub(version.CreatedAt) > time.Duration(v.MaxAgeDays)*24*time.Hour
+		if tooMany || tooOld {
+			pruned = append(pruned, version)
+		} else {
+			kept = append(kept, version)
+		}
+	}
+	return kept, pruned
+}
@@ -0,0 +1,48 @@
+package journal
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operation is the journal entry of an operation touching several storages.
+// It is recorded before the first step runs and deleted once the operation
+// completed or was compensated.
+type Operation struct {
+	Id      uuid.UUID   `json:"id"`
+	Kind    string      `json:"kind"`
+	Owner   string      `json:"owner"`
+	Details interface{} `json:"details,omitempty"`
+	// Step is the index of the step running.
+	Step int `json:"step"`
+	// Committed is set once a step that can't be compensated is done, the
+	// operation can then only be completed.
+	Committed    bool      `json:"committed"`
+	Compensating bool      `json:"compensating"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func NewOperation(kind string, owner string, details interface{}) Operation {
+	return Operation{
+		Id:        uuid.New(),
+		Kind:      kind,
+		Owner:     owner,
+		Details:   details,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// DecodeDetails decodes the operation details into v, they are a plain map
+// once the operation went through the storage.
+func (o *Operation) DecodeDetails(v interface{}) error {
+	b, err := json.Marshal(o.Details)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
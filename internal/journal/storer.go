@@ -0,0 +1,9 @@
+package journal
+
+import "github.com/google/uuid"
+
+type Storer interface {
+	Save(op *Operation) error
+	Delete(id uuid.UUID) error
+	GetAll() ([]Operation, error)
+}
@@ -0,0 +1,93 @@
+package media
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Cursor is the position after the last media of a page, its values of the
+// sort fields and its path which breaks the ties. The next page starts after
+// it, so that the pages don't shift when medias are added or deleted
+// meanwhile. The values are the ones the storers sort by: times are Unix
+// seconds and the name is lower cased.
+type Cursor struct {
+	CreatedAt     int64  `json:"c"`
+	UpdatedAt     int64  `json:"u"`
+	ContentLength int    `json:"l"`
+	Name          string `json:"n"`
+	Path          string `json:"p"`
+}
+
+// CursorAfter returns the cursor of the page after the media.
+func CursorAfter(m *Media) *Cursor {
+	return &Cursor{
+		CreatedAt:     m.CreatedAt.Unix(),
+		UpdatedAt:     m.UpdatedAt.Unix(),
+		ContentLength: m.ContentLength,
+		Name:          strings.ToLower(m.Path.Filename()),
+		Path:          m.Path.ToString(),
+	}
+}
+
+// Value returns the value of a sort field.
+func (c Cursor) Value(field string) interface{} {
+	switch field {
+	case FieldCreatedAt:
+		return c.CreatedAt
+	case FieldUpdatedAt:
+		return c.UpdatedAt
+	case FieldContentLength:
+		return c.ContentLength
+	case FieldName:
+		return c.Name
+	}
+	return nil
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes an opaque cursor, an empty one is the first page and
+// decodes to nil.
+func DecodeCursor(cursor string) (*Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Path == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Page trims the medias of a page fetched with one more media than the limit,
+// and returns the cursor of the next page when there is one.
+func Page(medias []Media, limit int) ([]Media, string) {
+	if len(medias) <= limit {
+		return medias, ""
+	}
+	medias = medias[:limit]
+	return medias, CursorAfter(&medias[limit-1]).Encode()
+}
+
+type ListQuery struct {
+	Path      Path
+	TagFilter TagFilter
+	Sort      []SortKey
+	Cursor    string
+	Limit     int
+}
+
+type ListResult struct {
+	Medias     []Media `json:"medias"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,33 @@
+package media
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CustomMetadata is the metadata set by the API users, e.g. alt text.
+type CustomMetadata map[string]interface{}
+
+// Fields returns the scalar fields formatted as "key:value", sorted by key,
+// so they can be indexed by the storers. Nested values are not indexed.
+func (c CustomMetadata) Fields() []string {
+	fields := []string{}
+	for k, v := range c {
+		switch v.(type) {
+		case string, bool, float64, float32, int, int64:
+			fields = append(fields, fmt.Sprintf("%s:%v", k, v))
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Text returns the scalar values joined by spaces for full text search.
+func (c CustomMetadata) Text() string {
+	values := []string{}
+	for _, f := range c.Fields() {
+		values = append(values, f[strings.Index(f, ":")+1:])
+	}
+	return strings.Join(values, " ")
+}
@@ -1,6 +1,9 @@
 package media
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 type UploadInput struct {
 	Path          Path
@@ -20,6 +23,8 @@ type FileInfo struct {
 	Path
 	ContentType   ContentType   `json:"content_type,omitempty"`
 	ContentLength ContentLength `json:"content_length,omitempty"`
+	// ModifiedAt is only set by Walk.
+	ModifiedAt time.Time `json:"-"`
 }
 
 type FileStorer interface {
@@ -28,6 +33,10 @@ type FileStorer interface {
 	DownloadMultiple(p []Path) ([]*DownloadResult, error)
 	Get(p Path) (*FileInfo, error)
 	GetMultiple(p Path) ([]FileInfo, error)
+	// Walk calls fn for every file under the folder p, sub folders included,
+	// in the lexical order of their paths. The content types are left empty.
+	// The modification times are set.
+	Walk(p Path, fn func(FileInfo) error) error
 	Move(src, dst Path) error
 	Copy(src, dst Path) error
 	Delete(p Path) error
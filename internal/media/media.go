@@ -6,16 +6,30 @@ type Body = *[]byte
 type ContentLength = int
 type Metadata map[string]string
 
+// Keys of the embedded metadata used by the search.
+const (
+	WidthMetadataKey      = "width"
+	HeightMetadataKey     = "height"
+	CameraMakeMetadataKey = "make"
+	LatitudeMetadataKey   = "lat"
+	LongitudeMetadataKey  = "long"
+)
+
 type Media struct {
 	Path
 	Body             Body           `json:"-"`
 	ContentType      ContentType    `json:"content_type,omitempty"`
 	ContentLength    ContentLength  `json:"content_length,omitempty"`
 	EmbeddedMetadata Metadata       `json:"embedded_metadata,omitempty"`
+	CustomMetadata   CustomMetadata `json:"custom_metadata,omitempty"`
 	Tags             []Tag          `json:"tags,omitempty"`
 	DerivedMedias    []DerivedMedia `json:"derived_medias,omitempty"`
-	CreatedAt        time.Time      `json:"created_at,omitempty"`
-	UpdatedAt        time.Time      `json:"updated_at,omitempty"`
+	// Versions are the previous originals kept, oldest first.
+	Versions  []Version `json:"versions,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// ExpiresAt is when the media is deleted by the expire_uploads job.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 type DerivedMedia struct {
@@ -26,3 +40,14 @@ type DerivedMedia struct {
 	CreatedAt     time.Time     `json:"created_at,omitempty"`
 	UpdatedAt     time.Time     `json:"updated_at,omitempty"`
 }
+
+// Version is a previous original of a media, stored under its own path.
+type Version struct {
+	Id string `json:"id"`
+	Path
+	ContentType      ContentType   `json:"content_type,omitempty"`
+	ContentLength    ContentLength `json:"content_length,omitempty"`
+	EmbeddedMetadata Metadata      `json:"embedded_metadata,omitempty"`
+	// CreatedAt is when the original was replaced.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
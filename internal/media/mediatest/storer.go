@@ -0,0 +1,278 @@
+// Package mediatest holds the behavioural tests every media.Storer must pass.
+package mediatest
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+// TestStorer runs the suite against the storers returned by newStorer, each
+// test gets an empty storer.
+func TestStorer(t *testing.T, newStorer func(t *testing.T) media.Storer) {
+	t.Run("GetSaveDelete", func(t *testing.T) { testGetSaveDelete(t, newStorer(t)) })
+	t.Run("List", func(t *testing.T) { testList(t, newStorer(t)) })
+	t.Run("ListTags", func(t *testing.T) { testListTags(t, newStorer(t)) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, newStorer(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newStorer(t)) })
+	t.Run("Facets", func(t *testing.T) { testFacets(t, newStorer(t)) })
+}
+
+var baseTime = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func fixtures() []media.Media {
+	return []media.Media{
+		{
+			Path:             media.NewPath("/photos/dog.jpg"),
+			ContentType:      "image/jpeg",
+			ContentLength:    2048,
+			EmbeddedMetadata: media.Metadata{media.WidthMetadataKey: "1920", media.HeightMetadataKey: "1080", media.CameraMakeMetadataKey: "Canon", media.LatitudeMetadataKey: "50.8"},
+			CustomMetadata:   media.CustomMetadata{"sku": "A-1", "title": "A brown dog"},
+			Tags:             []media.Tag{{Value: "dog", ConfidenceScore: 0.9, Provider: "local"}, {Value: "Brown", ConfidenceScore: 0.4, Provider: "local"}},
+			CreatedAt:        baseTime,
+			UpdatedAt:        baseTime,
+		},
+		{
+			Path:             media.NewPath("/photos/2023/cat.png"),
+			ContentType:      "image/png",
+			ContentLength:    512,
+			EmbeddedMetadata: media.Metadata{media.WidthMetadataKey: "640", media.HeightMetadataKey: "480"},
+			CustomMetadata:   media.CustomMetadata{"sku": "B-2"},
+			Tags:             []media.Tag{{Value: "cat", ConfidenceScore: 0.8, Provider: "local"}},
+			CreatedAt:        baseTime.Add(time.Hour),
+			UpdatedAt:        baseTime.Add(time.Hour),
+		},
+		{
+			Path:          media.NewPath("/photos-old/bird.jpg"),
+			ContentType:   "image/jpeg",
+			ContentLength: 100,
+			Tags:          []media.Tag{{Value: "dog", ConfidenceScore: 0.7, Provider: "user"}, {Value: "bird", ConfidenceScore: 1, Provider: "user"}},
+			CreatedAt:     baseTime.Add(2 * time.Hour),
+			UpdatedAt:     baseTime.Add(2 * time.Hour),
+		},
+		{
+			Path:          media.NewPath("/readme.txt"),
+			ContentType:   "text/plain",
+			ContentLength: 10,
+			CreatedAt:     baseTime.Add(3 * time.Hour),
+			UpdatedAt:     baseTime.Add(3 * time.Hour),
+		},
+	}
+}
+
+func save(t *testing.T, s media.Storer) {
+	t.Helper()
+	for _, m := range fixtures() {
+		m := m
+		if err := s.Save(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func paths(medias []media.Media) []string {
+	p := []string{}
+	for _, m := range medias {
+		p = append(p, m.Path.ToString())
+	}
+	return p
+}
+
+func sorted(p []string) []string {
+	sort.Strings(p)
+	return p
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testGetSaveDelete(t *testing.T, s media.Storer) {
+	save(t, s)
+
+	m, err := s.Get(media.NewPath("/photos/dog.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ContentLength != 2048 || len(m.Tags) != 2 || m.CustomMetadata["sku"] != "A-1" || !m.CreatedAt.Equal(baseTime) {
+		t.Errorf("unexpected media %+v", m)
+	}
+
+	m.ContentLength = 4096
+	m.Tags = nil
+	if err := s.Save(m); err != nil {
+		t.Fatal(err)
+	}
+	m, err = s.Get(media.NewPath("/photos/dog.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ContentLength != 4096 || len(m.Tags) != 0 {
+		t.Errorf("media not updated %+v", m)
+	}
+
+	if err := s.Delete(media.NewPath("/photos/dog.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get(media.NewPath("/photos/dog.jpg"))
+	if e, ok := err.(*mindiaerr.Error); !ok || e.ErrCode != mindiaerr.ErrCodeMediaNotFound {
+		t.Errorf("got %v, wanted media not found", err)
+	}
+}
+
+func testList(t *testing.T, s media.Storer) {
+	save(t, s)
+
+	cases := []struct {
+		path string
+		sort []media.SortKey
+		want []string
+	}{
+		{"/", nil, []string{"/readme.txt", "/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{"/photos", nil, []string{"/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{"/photos/2023", nil, []string{"/photos/2023/cat.png"}},
+		{"/photos/20", nil, []string{}},
+		{"/", []media.SortKey{{Field: media.FieldContentLength, Asc: true}}, []string{"/readme.txt", "/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{"/", []media.SortKey{{Field: media.FieldName, Asc: true}}, []string{"/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg", "/readme.txt"}},
+	}
+	for _, c := range cases {
+		result, err := s.GetMultiple(media.ListQuery{Path: media.NewPath(c.path), Sort: c.sort})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := paths(result.Medias); !equal(got, c.want) || result.Total != len(c.want) {
+			t.Errorf("%s %v: got %v (%d), wanted %v", c.path, c.sort, got, result.Total, c.want)
+		}
+	}
+}
+
+func testListTags(t *testing.T, s media.Storer) {
+	save(t, s)
+
+	cases := []struct {
+		filter media.TagFilter
+		want   []string
+	}{
+		{media.TagFilter{Values: []string{"dog"}}, []string{"/photos-old/bird.jpg", "/photos/dog.jpg"}},
+		{media.TagFilter{Values: []string{"dog", "cat"}}, []string{"/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{media.TagFilter{Values: []string{"dog", "bird"}, MatchAll: true}, []string{"/photos-old/bird.jpg"}},
+		{media.TagFilter{Values: []string{"BROWN"}}, []string{"/photos/dog.jpg"}},
+		{media.TagFilter{Values: []string{"dog"}, MinConfidence: 0.8}, []string{"/photos/dog.jpg"}},
+	}
+	for _, c := range cases {
+		result, err := s.GetMultiple(media.ListQuery{Path: media.NewPath("/"), TagFilter: c.filter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sorted(paths(result.Medias)); !equal(got, c.want) || result.Total != len(c.want) {
+			t.Errorf("%+v: got %v (%d), wanted %v", c.filter, got, result.Total, c.want)
+		}
+	}
+}
+
+func testPagination(t *testing.T, s media.Storer) {
+	save(t, s)
+
+	got := []string{}
+	cursor := ""
+	for i := 0; i < 3; i++ {
+		result, err := s.GetMultiple(media.ListQuery{Path: media.NewPath("/"), Cursor: cursor, Limit: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && result.Total != 4 {
+			t.Errorf("got total %d, wanted 4", result.Total)
+		}
+		got = append(got, paths(result.Medias)...)
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+
+		// The next pages start after the last media listed, a media added
+		// before it or the deletion of a listed one doesn't shift them.
+		if err := s.Save(&media.Media{Path: media.NewPath("/new.txt"), ContentType: "text/plain", CreatedAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(media.NewPath("/readme.txt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"/readme.txt", "/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg"}
+	if !equal(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+
+	if _, err := s.GetMultiple(media.ListQuery{Path: media.NewPath("/"), Cursor: "invalid"}); err == nil {
+		t.Errorf("should reject an invalid cursor")
+	}
+}
+
+func testSearch(t *testing.T, s media.Storer) {
+	save(t, s)
+
+	cases := []struct {
+		path string
+		q    string
+		want []string
+	}{
+		{"/", "", []string{"/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg", "/readme.txt"}},
+		{"/photos", "", []string{"/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{"/", "type:image/jpeg", []string{"/photos-old/bird.jpg", "/photos/dog.jpg"}},
+		{"/", "size>=512 size<4kb", []string{"/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{"/", "width>1000", []string{"/photos/dog.jpg"}},
+		{"/", "created>=2023-06-01T13:00:00Z", []string{"/photos-old/bird.jpg", "/photos/2023/cat.png", "/readme.txt"}},
+		{"/", "tag:dog OR tag:cat", []string{"/photos-old/bird.jpg", "/photos/2023/cat.png", "/photos/dog.jpg"}},
+		{"/", "tag:dog -tag:bird", []string{"/photos/dog.jpg"}},
+		{"/", "make:canon", []string{"/photos/dog.jpg"}},
+		{"/", "has:gps", []string{"/photos/dog.jpg"}},
+		{"/", "-has:gps type:image/png", []string{"/photos/2023/cat.png"}},
+		{"/", "metadata.sku:B-2", []string{"/photos/2023/cat.png"}},
+		{"/", "brown", []string{"/photos/dog.jpg"}},
+		{"/", "bird", []string{"/photos-old/bird.jpg"}},
+		{"/", "readme", []string{"/readme.txt"}},
+	}
+	for _, c := range cases {
+		condition, err := media.ParseSearchQuery(c.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := s.Search(media.SearchQuery{Path: media.NewPath(c.path), Condition: condition})
+		if err != nil {
+			t.Fatalf("%s: %v", c.q, err)
+		}
+		if got := sorted(paths(result.Medias)); !equal(got, c.want) || result.Total != len(c.want) {
+			t.Errorf("%s: got %v (%d), wanted %v", c.q, got, result.Total, c.want)
+		}
+	}
+}
+
+func testFacets(t *testing.T, s media.Storer) {
+	save(t, s)
+
+	result, err := s.Search(media.SearchQuery{
+		Path:   media.NewPath("/"),
+		Facets: []string{media.FieldContentType, media.FieldTag},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentTypes := result.Facets[media.FieldContentType]
+	if len(contentTypes) != 3 || contentTypes[0].Value != "image/jpeg" || contentTypes[0].Count != 2 {
+		t.Errorf("unexpected content type facets %v", contentTypes)
+	}
+	tags := result.Facets[media.FieldTag]
+	if len(tags) != 4 || tags[0].Value != "dog" || tags[0].Count != 2 {
+		t.Errorf("unexpected tag facets %v", tags)
+	}
+}
@@ -10,6 +10,10 @@ import (
 
 const uuidRegex = "[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}"
 
+// VersionsDir is the folder of the file storage holding the versions of the
+// medias, apart from the medias.
+const VersionsDir = "/.versions"
+
 type Path struct {
 	Path string `json:"path"`
 }
@@ -63,6 +67,13 @@ func (p *Path) AppendSuffix(suffix string) Path {
 	)
 }
 
+// Transformations returns the transformations a derived media was generated
+// with, read back from the suffix AppendSuffix added to its name.
+func (p *Path) Transformations() string {
+	suffix := strings.TrimPrefix(strings.TrimSuffix(p.Filename(), p.Extension()), p.Uuid())
+	return strings.ReplaceAll(suffix, "-", "/")
+}
+
 func (p *Path) SetExtension(ext string) Path {
 	return NewPath(
 		path.JoinPath(p.Dir(), p.Uuid()+ext),
@@ -74,3 +85,19 @@ func (p *Path) WithDir(dir string) Path {
 		path.JoinPath(dir, p.Filename()),
 	)
 }
+
+// VersionPath returns the path the version id of the media is stored at.
+func (p *Path) VersionPath(id string) Path {
+	return NewPath(
+		path.JoinPath(VersionsDir, p.Dir(), p.Basename(), id+p.Extension()),
+	)
+}
+
+// VersionOf returns the path of the media the version was kept for.
+func (p *Path) VersionOf() Path {
+	return NewPath(strings.TrimPrefix(filepath.Dir(p.Path), VersionsDir) + p.Extension())
+}
+
+func (p *Path) IsVersion() bool {
+	return strings.HasPrefix(p.Path, VersionsDir+"/")
+}
@@ -0,0 +1,89 @@
+package media
+
+import "strings"
+
+// Searchable fields, custom metadata fields are prefixed by
+// MetadataFieldPrefix, e.g. "metadata.sku".
+const (
+	FieldContentType   = "content_type"
+	FieldContentLength = "size"
+	FieldCreatedAt     = "created_at"
+	FieldUpdatedAt     = "updated_at"
+	FieldWidth         = "width"
+	FieldHeight        = "height"
+	FieldTag           = "tag"
+	FieldCameraMake    = "camera_make"
+	FieldGps           = "gps"
+
+	MetadataFieldPrefix = "metadata."
+)
+
+// FacetFields are the fields counts can be requested for.
+var FacetFields = []string{FieldContentType, FieldTag, FieldCameraMake}
+
+type Operator string
+
+const (
+	OpEq  Operator = "="
+	OpGt  Operator = ">"
+	OpGte Operator = ">="
+	OpLt  Operator = "<"
+	OpLte Operator = "<="
+)
+
+// Condition is a node of the search query AST, the storers translate it to
+// their own query language.
+type Condition interface {
+	condition()
+}
+
+type And []Condition
+
+type Or []Condition
+
+type Not struct {
+	Condition Condition
+}
+
+// Comparison compares a field to a value. Values are int64 for sizes and
+// dimensions, time.Time for dates, bool for gps and strings otherwise.
+type Comparison struct {
+	Field string
+	Op    Operator
+	Value interface{}
+}
+
+// Text matches the medias whose path, tags or custom metadata contain the
+// terms.
+type Text string
+
+func (And) condition()        {}
+func (Or) condition()         {}
+func (Not) condition()        {}
+func (Comparison) condition() {}
+func (Text) condition()       {}
+
+func IsMetadataField(field string) bool {
+	return strings.HasPrefix(field, MetadataFieldPrefix)
+}
+
+type SearchQuery struct {
+	Path      Path
+	Condition Condition
+	Sort      []SortKey
+	Cursor    string
+	Limit     int
+	Facets    []string
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type SearchResult struct {
+	Medias     []Media                 `json:"medias"`
+	Total      int                     `json:"total"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	Facets     map[string][]FacetValue `json:"facets,omitempty"`
+}
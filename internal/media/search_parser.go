@@ -0,0 +1,291 @@
+package media
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var fieldAliases = map[string]string{
+	"type":    FieldContentType,
+	"created": FieldCreatedAt,
+	"updated": FieldUpdatedAt,
+	"make":    FieldCameraMake,
+}
+
+var sizeUnits = map[string]int64{
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+// ParseSearchQuery parses a search query, e.g.
+//
+//	type:image/jpeg size>2mb created>=2023-01-01 (tag:dog OR tag:cat) -has:gps "red car"
+//
+// Terms are joined with AND unless separated by OR, a leading - negates a
+// term and words without field are searched as text. It returns nil for an
+// empty query.
+func ParseSearchQuery(q string) (Condition, error) {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+	p := searchParser{tokens: tokens}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return c, nil
+}
+
+type searchParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *searchParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *searchParser) parseOr() (Condition, error) {
+	or := Or{}
+	for {
+		c, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			or = append(or, c)
+		}
+		if p.peek() != "OR" {
+			break
+		}
+		p.pos++
+	}
+	switch len(or) {
+	case 0:
+		return nil, nil
+	case 1:
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *searchParser) parseAnd() (Condition, error) {
+	and := And{}
+	for p.pos < len(p.tokens) {
+		token := p.peek()
+		if token == "OR" || token == ")" {
+			break
+		}
+		p.pos++
+		if token == "AND" {
+			continue
+		}
+		c, err := p.parseTerm(token)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, c)
+	}
+	switch len(and) {
+	case 0:
+		return nil, nil
+	case 1:
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *searchParser) parseTerm(token string) (Condition, error) {
+	if token == "-" || (strings.HasPrefix(token, "-") && len(token) > 1) {
+		var (
+			c   Condition
+			err error
+		)
+		if token == "-" {
+			if p.pos >= len(p.tokens) {
+				return nil, fmt.Errorf("missing term after -")
+			}
+			p.pos++
+			c, err = p.parseTerm(p.tokens[p.pos-1])
+		} else {
+			c, err = p.parseTerm(token[1:])
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Not{c}, nil
+	}
+	if token == "(" {
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		if c == nil {
+			return nil, fmt.Errorf("empty group")
+		}
+		return c, nil
+	}
+	if token == ")" {
+		return nil, fmt.Errorf("unexpected )")
+	}
+	if field, op, value, ok := splitComparison(token); ok {
+		return parseComparison(field, op, value)
+	}
+	return Text(unquote(token)), nil
+}
+
+// splitComparison splits "size>=10" into its field, operator and value.
+func splitComparison(token string) (string, Operator, string, bool) {
+	i := strings.IndexAny(token, ":=<>")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	field := token[:i]
+	for _, r := range field {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+			return "", "", "", false
+		}
+	}
+	rest := token[i:]
+	for _, op := range []Operator{OpGte, OpLte, OpGt, OpLt, OpEq} {
+		if strings.HasPrefix(rest, string(op)) {
+			return field, op, rest[len(op):], true
+		}
+	}
+	return field, OpEq, rest[1:], true
+}
+
+func parseComparison(field string, op Operator, value string) (Condition, error) {
+	field = strings.ToLower(field)
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+	value = unquote(value)
+	if value == "" {
+		return nil, fmt.Errorf("missing value for %s", field)
+	}
+
+	if field == "has" {
+		if strings.ToLower(value) != FieldGps {
+			return nil, fmt.Errorf("has:%s not supported", value)
+		}
+		return Comparison{Field: FieldGps, Op: OpEq, Value: true}, nil
+	}
+
+	switch {
+	case field == FieldContentLength:
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, err
+		}
+		return Comparison{Field: field, Op: op, Value: size}, nil
+	case field == FieldWidth || field == FieldHeight:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", field, value)
+		}
+		return Comparison{Field: field, Op: op, Value: n}, nil
+	case field == FieldCreatedAt || field == FieldUpdatedAt:
+		t, err := parseDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", field, value)
+		}
+		return Comparison{Field: field, Op: op, Value: t}, nil
+	}
+
+	if op != OpEq {
+		return nil, fmt.Errorf("operator %s not supported on %s", op, field)
+	}
+	switch {
+	case field == FieldGps:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gps %q", value)
+		}
+		return Comparison{Field: field, Op: op, Value: b}, nil
+	case field == FieldContentType || field == FieldTag || field == FieldCameraMake:
+		return Comparison{Field: field, Op: op, Value: value}, nil
+	case IsMetadataField(field) && len(field) > len(MetadataFieldPrefix):
+		return Comparison{Field: field, Op: op, Value: value}, nil
+	}
+	return nil, fmt.Errorf("unknown field %s", field)
+}
+
+func parseSize(value string) (int64, error) {
+	v := strings.ToLower(value)
+	unit := int64(1)
+	for suffix, u := range sizeUnits {
+		if strings.HasSuffix(v, suffix) && len(suffix) > 1 {
+			v, unit = strings.TrimSuffix(v, suffix), u
+			break
+		}
+	}
+	v = strings.TrimSuffix(v, "b")
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func unquote(s string) string {
+	return strings.ReplaceAll(s, `"`, "")
+}
+
+func tokenize(q string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case quoted:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
@@ -0,0 +1,80 @@
+package media
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	cases := []struct {
+		q    string
+		want Condition
+	}{
+		{"", nil},
+		{"dog", Text("dog")},
+		{`type:image/jpeg size>2mb "red car"`, And{
+			Comparison{FieldContentType, OpEq, "image/jpeg"},
+			Comparison{FieldContentLength, OpGt, int64(2 << 20)},
+			Text("red car"),
+		}},
+		{"created>=2023-01-01 (tag:dog OR tag:cat) -has:gps", And{
+			Comparison{FieldCreatedAt, OpGte, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Or{Comparison{FieldTag, OpEq, "dog"}, Comparison{FieldTag, OpEq, "cat"}},
+			Not{Comparison{FieldGps, OpEq, true}},
+		}},
+		{`width<=800 OR make:"Canon EOS" metadata.sku:A-1`, Or{
+			Comparison{FieldWidth, OpLte, int64(800)},
+			And{
+				Comparison{FieldCameraMake, OpEq, "Canon EOS"},
+				Comparison{"metadata.sku", OpEq, "A-1"},
+			},
+		}},
+	}
+	for _, c := range cases {
+		got, err := ParseSearchQuery(c.q)
+		if err != nil {
+			t.Errorf("%s: %v", c.q, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, wanted %#v", c.q, got, c.want)
+		}
+	}
+
+	for _, q := range []string{"unknown:1", "size>big", "tag>dog", "(tag:dog", `"open`, "has:faces", "metadata.:x"} {
+		if _, err := ParseSearchQuery(q); err == nil {
+			t.Errorf("%s: should have failed", q)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c := CursorAfter(&Media{Path: NewPath("/photos/Dog.jpg"), ContentLength: 40})
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil || *decoded != *c || decoded.Value(FieldName) != "dog.jpg" {
+		t.Errorf("got %v, %v", decoded, err)
+	}
+	if first, err := DecodeCursor(""); err != nil || first != nil {
+		t.Errorf("an empty cursor should be the first page, got %v, %v", first, err)
+	}
+	if _, err := DecodeCursor("not a cursor"); err == nil {
+		t.Errorf("should fail to decode an invalid cursor")
+	}
+}
+
+func TestParseSortKeys(t *testing.T) {
+	keys, err := ParseSortKeys("updated_at:desc, name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SortKey{{FieldUpdatedAt, false}, {FieldName, true}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, wanted %v", keys, want)
+	}
+	for _, s := range []string{"created_at:up", "unknown", "name,name"} {
+		if _, err := ParseSortKeys(s); err == nil {
+			t.Errorf("%s: should have failed", s)
+		}
+	}
+}
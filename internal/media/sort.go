@@ -1,8 +1,68 @@
 package media
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
 	Asc  = "asc"
 	Desc = "desc"
 )
 
 type SortBy = map[string]string
+
+// FieldName sorts by file name.
+const FieldName = "name"
+
+// SortFields are the fields medias can be sorted by.
+var SortFields = []string{FieldCreatedAt, FieldUpdatedAt, FieldContentLength, FieldName}
+
+type SortKey struct {
+	Field string
+	Asc   bool
+}
+
+// DefaultSort lists the newest medias first.
+var DefaultSort = []SortKey{{Field: FieldCreatedAt}}
+
+// ParseSortKeys parses sort keys formatted as "created_at:desc,name:asc",
+// the order defaults to asc.
+func ParseSortKeys(s string) ([]SortKey, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultSort, nil
+	}
+	keys := []SortKey{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		field, order, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if field == "content_length" {
+			field = FieldContentLength
+		}
+		if !isSortField(field) {
+			return nil, fmt.Errorf("sort by %q not supported", field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("sort by %s given twice", field)
+		}
+		seen[field] = true
+		switch order {
+		case "", Asc:
+			keys = append(keys, SortKey{Field: field, Asc: true})
+		case Desc:
+			keys = append(keys, SortKey{Field: field})
+		default:
+			return nil, fmt.Errorf("sort order %q not supported", order)
+		}
+	}
+	return keys, nil
+}
+
+func isSortField(field string) bool {
+	for _, f := range SortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
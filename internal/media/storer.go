@@ -2,7 +2,8 @@ package media
 
 type Storer interface {
 	Get(path Path) (*Media, error)
-	GetMultiple(path Path, offset int, limit int, sortBy string, asc bool) ([]Media, error)
+	GetMultiple(query ListQuery) (*ListResult, error)
+	Search(query SearchQuery) (*SearchResult, error)
 	Save(media *Media) error
 	Delete(path Path) error
 }
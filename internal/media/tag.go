@@ -1,7 +1,53 @@
 package media
 
+import "strings"
+
+// UserTagProvider is the provider of the tags set by the API users.
+const UserTagProvider = "user"
+
 type Tag struct {
 	Value           string  `json:"value"`
 	ConfidenceScore float32 `json:"confidence_score"`
 	Provider        string  `json:"provider"`
 }
+
+func NormalizeTagValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// TagFilter matches the medias having all (or any) of the tag values with at
+// least the minimum confidence. The zero value matches all the medias.
+type TagFilter struct {
+	Values        []string
+	MatchAll      bool
+	MinConfidence float32
+}
+
+func (f TagFilter) IsEmpty() bool {
+	return len(f.Values) == 0 && f.MinConfidence == 0
+}
+
+func (f TagFilter) Matches(m *Media) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	found := map[string]bool{}
+	for _, t := range m.Tags {
+		if t.ConfidenceScore >= f.MinConfidence {
+			found[NormalizeTagValue(t.Value)] = true
+		}
+	}
+	if len(f.Values) == 0 {
+		return len(found) > 0
+	}
+	for _, v := range f.Values {
+		ok := found[NormalizeTagValue(v)]
+		if ok && !f.MatchAll {
+			return true
+		}
+		if !ok && f.MatchAll {
+			return false
+		}
+	}
+	return f.MatchAll
+}
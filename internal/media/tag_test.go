@@ -0,0 +1,28 @@
+package media
+
+import "testing"
+
+func TestTagFilterMatches(t *testing.T) {
+	m := &Media{Tags: []Tag{
+		{Value: "dog", ConfidenceScore: 0.9},
+		{Value: "Beach", ConfidenceScore: 0.4},
+	}}
+
+	cases := []struct {
+		filter TagFilter
+		want   bool
+	}{
+		{TagFilter{}, true},
+		{TagFilter{Values: []string{"dog", "cat"}}, true},
+		{TagFilter{Values: []string{"dog", "cat"}, MatchAll: true}, false},
+		{TagFilter{Values: []string{"dog", "beach"}, MatchAll: true}, true},
+		{TagFilter{Values: []string{"beach"}, MinConfidence: 0.5}, false},
+		{TagFilter{Values: []string{"dog"}, MinConfidence: 0.5}, true},
+		{TagFilter{MinConfidence: 0.95}, false},
+	}
+	for _, c := range cases {
+		if got := c.filter.Matches(m); got != c.want {
+			t.Errorf("%+v: got %v, wanted %v", c.filter, got, c.want)
+		}
+	}
+}
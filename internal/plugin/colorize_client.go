@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/replicate/replicate-go"
+)
+
+type PredictionStatus string
+
+const (
+	PredictionStarting   PredictionStatus = "starting"
+	PredictionProcessing PredictionStatus = "processing"
+	PredictionSucceeded  PredictionStatus = "succeeded"
+	PredictionFailed     PredictionStatus = "failed"
+	PredictionCanceled   PredictionStatus = "canceled"
+)
+
+func (s PredictionStatus) IsTerminal() bool {
+	return s == PredictionSucceeded || s == PredictionFailed || s == PredictionCanceled
+}
+
+type Prediction struct {
+	Id     string
+	Status PredictionStatus
+	// Output is the URL of the predicted picture once succeeded.
+	Output string
+}
+
+type PredictionInput = map[string]interface{}
+
+// PredictionClient runs the colorization model.
+type PredictionClient interface {
+	CreatePrediction(ctx context.Context, input PredictionInput) (*Prediction, error)
+	GetPrediction(ctx context.Context, id string) (*Prediction, error)
+}
+
+type ReplicatePredictionClient struct {
+	client  *replicate.Client
+	version string
+}
+
+// NewReplicatePredictionClient creates a client for the Replicate API, or any
+// API compatible with it when endpoint is set.
+func NewReplicatePredictionClient(endpoint string, token string, version string) (*ReplicatePredictionClient, error) {
+	opts := []replicate.ClientOption{replicate.WithTokenFromEnv()}
+	if token != "" {
+		opts = []replicate.ClientOption{replicate.WithToken(token)}
+	}
+	if endpoint != "" {
+		opts = append(opts, replicate.WithBaseURL(endpoint))
+	}
+	client, err := replicate.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplicatePredictionClient{
+		client,
+		version,
+	}, nil
+}
+
+func (c *ReplicatePredictionClient) CreatePrediction(ctx context.Context, input PredictionInput) (*Prediction, error) {
+	prediction, err := c.client.CreatePrediction(ctx, c.version, replicate.PredictionInput(input), nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return toPrediction(prediction)
+}
+
+func (c *ReplicatePredictionClient) GetPrediction(ctx context.Context, id string) (*Prediction, error) {
+	prediction, err := c.client.GetPrediction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toPrediction(prediction)
+}
+
+func toPrediction(p *replicate.Prediction) (*Prediction, error) {
+	prediction := &Prediction{
+		Id:     p.ID,
+		Status: PredictionStatus(p.Status),
+	}
+	if p.Output != nil {
+		output, ok := p.Output.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected prediction output %v", p.Output)
+		}
+		prediction.Output = output
+	}
+	return prediction, nil
+}
@@ -0,0 +1,345 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+const defaultExternalPluginTimeout = 30 * time.Second
+
+var ErrExternalPluginTimeout = errors.New("external plugin timed out")
+
+type ExternalPluginConfig struct {
+	Name    string
+	Command string
+	Args    []string
+	// Timeout bounds every request made to the plugin. The process is killed,
+	// and restarted on the next request, when it is exceeded.
+	Timeout time.Duration
+}
+
+type externalProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// ExternalPlugin runs a plugin executable speaking the JSON lines protocol
+// described in external_protocol.go. It can be used as a pipeline step through
+// the transformations it describes, and as an upload hook.
+type ExternalPlugin struct {
+	config        ExternalPluginConfig
+	pluginManager *PluginManager
+	mu            sync.Mutex
+	proc          *externalProcess
+	nextId        uint64
+	// description is the one of the first process, the transformations it
+	// lists are registered once.
+	description *describeResult
+}
+
+func NewExternalPlugin(pluginManager *PluginManager, config ExternalPluginConfig) (*ExternalPlugin, error) {
+	if config.Command == "" {
+		return nil, errors.New("external plugin command is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultExternalPluginTimeout
+	}
+	p := &ExternalPlugin{
+		config:        config,
+		pluginManager: pluginManager,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newExternalPluginFromOptions(pluginManager *PluginManager, name string, options map[string]string) (Plugin, error) {
+	config := ExternalPluginConfig{
+		Name:    name,
+		Command: options["command"],
+		Args:    strings.Fields(options["args"]),
+	}
+	if options["timeout"] != "" {
+		timeout, err := time.ParseDuration(options["timeout"])
+		if err != nil {
+			return nil, err
+		}
+		config.Timeout = timeout
+	}
+	return NewExternalPlugin(pluginManager, config)
+}
+
+func (p *ExternalPlugin) Name() string {
+	return p.config.Name
+}
+
+func (p *ExternalPlugin) Execute(task *scheduler.Task) (*scheduler.Task, error) {
+	var res executeResult
+	if err := p.call(methodExecute, task, &res); err != nil {
+		return nil, err
+	}
+	if !res.Continue {
+		return nil, nil
+	}
+	task.Details = res.Details
+	return task, nil
+}
+
+func (p *ExternalPlugin) HealthCheck() error {
+	return p.call(methodHealth, nil, nil)
+}
+
+func (p *ExternalPlugin) describe() describeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return *p.description
+}
+
+func (p *ExternalPlugin) ProvideTransformations() map[string]transform.StepFactory {
+	factories := map[string]transform.StepFactory{}
+	for _, name := range p.describe().Transformations {
+		name := name
+		factories[name] = func(args map[string]string) (pipeline.PipelineStep, error) {
+			return &externalStep{
+				plugin:         p,
+				transformation: name,
+				args:           args,
+			}, nil
+		}
+	}
+	return factories
+}
+
+func (p *ExternalPlugin) UploadHookMode() HookMode {
+	if p.describe().UploadHook == "async" {
+		return AsyncHook
+	}
+	return SyncHook
+}
+
+func (p *ExternalPlugin) OnUpload(m *media.Media) error {
+	if p.describe().UploadHook == "" {
+		return nil
+	}
+	downloadResult, err := p.pluginManager.GetFileStorage().Download(m.Path)
+	if err != nil {
+		return err
+	}
+	defer downloadResult.Body.Close()
+	data, err := io.ReadAll(downloadResult.Body)
+	if err != nil {
+		return err
+	}
+
+	var res mediaResult
+	err = p.call(methodUpload, mediaParams{
+		Path:        m.Path.ToString(),
+		ContentType: m.ContentType,
+		Metadata:    m.EmbeddedMetadata,
+		Data:        data,
+	}, &res)
+	if err != nil {
+		return err
+	}
+	m.Tags = append(m.Tags, res.Tags...)
+	m.EmbeddedMetadata = mergeMetadata(m.EmbeddedMetadata, res.Metadata)
+	return nil
+}
+
+func (p *ExternalPlugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	return nil
+}
+
+func (p *ExternalPlugin) call(method string, params interface{}, result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+	return p.roundTrip(method, params, result)
+}
+
+// start must be called with the lock held.
+func (p *ExternalPlugin) start() error {
+	cmd := exec.Command(p.config.Command, p.config.Args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to start external plugin %s: %w", p.config.Name, err)
+	}
+	p.proc = &externalProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	var description describeResult
+	if err := p.roundTrip(methodDescribe, nil, &description); err != nil {
+		return fmt.Errorf("unable to describe external plugin %s: %w", p.config.Name, err)
+	}
+	if p.description == nil {
+		p.description = &description
+		return nil
+	}
+	// The factories and the hook mode were registered from the first
+	// description, a restarted process must describe the same plugin.
+	if !reflect.DeepEqual(description, *p.description) {
+		p.stop()
+		return fmt.Errorf("external plugin %s changed its description, the server must be restarted", p.config.Name)
+	}
+	return nil
+}
+
+// stop must be called with the lock held.
+func (p *ExternalPlugin) stop() {
+	if p.proc == nil {
+		return
+	}
+	proc := p.proc
+	p.proc = nil
+	proc.stdin.Close()
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+	go proc.cmd.Wait()
+}
+
+// roundTrip must be called with the lock held. The process is stopped on any
+// failure since the stream may be left in an unknown state.
+func (p *ExternalPlugin) roundTrip(method string, params interface{}, result interface{}) error {
+	p.nextId++
+	req, err := json.Marshal(externalRequest{
+		Id:     p.nextId,
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return err
+	}
+
+	type reply struct {
+		res externalResponse
+		err error
+	}
+	proc := p.proc
+	done := make(chan reply, 1)
+	go func() {
+		if _, err := proc.stdin.Write(append(req, '\n')); err != nil {
+			done <- reply{err: err}
+			return
+		}
+		line, err := proc.stdout.ReadBytes('\n')
+		if err != nil {
+			done <- reply{err: err}
+			return
+		}
+		var res externalResponse
+		err = json.Unmarshal(line, &res)
+		done <- reply{res: res, err: err}
+	}()
+
+	timer := time.NewTimer(p.config.Timeout)
+	defer timer.Stop()
+
+	var r reply
+	select {
+	case r = <-done:
+	case <-timer.C:
+		p.stop()
+		return fmt.Errorf("%w: %s %s", ErrExternalPluginTimeout, p.config.Name, method)
+	}
+	if r.err != nil {
+		p.stop()
+		return fmt.Errorf("external plugin %s: %w", p.config.Name, r.err)
+	}
+	if r.res.Id != p.nextId {
+		p.stop()
+		return fmt.Errorf("external plugin %s: unexpected response id %d", p.config.Name, r.res.Id)
+	}
+	if r.res.Error != "" {
+		return fmt.Errorf("external plugin %s: %s", p.config.Name, r.res.Error)
+	}
+	if result != nil && len(r.res.Result) > 0 {
+		return json.Unmarshal(r.res.Result, result)
+	}
+	return nil
+}
+
+type externalStep struct {
+	plugin         *ExternalPlugin
+	transformation string
+	args           map[string]string
+}
+
+func (s *externalStep) Execute(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
+	var res mediaResult
+	err := s.plugin.call(methodTransform, transformParams{
+		mediaParams: mediaParams{
+			Path:        ctx.Path.ToString(),
+			ContentType: ctx.ContentType,
+			Metadata:    ctx.EmbeddedMetadata,
+			Data:        ctx.Buffer.Bytes(),
+		},
+		Transformation: s.transformation,
+		Args:           s.args,
+	}, &res)
+	if err != nil {
+		return ctx, err
+	}
+
+	if res.Data != nil {
+		ctx.Buffer = pipeline.NewBuffer(bytes.NewReader(res.Data))
+	}
+	if res.ContentType != "" {
+		ctx.ContentType = res.ContentType
+	}
+	ctx.Tags = append(ctx.Tags, res.Tags...)
+	ctx.EmbeddedMetadata = mergeMetadata(ctx.EmbeddedMetadata, res.Metadata)
+	return ctx, nil
+}
+
+func mergeMetadata(dst media.Metadata, src media.Metadata) media.Metadata {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = media.Metadata{}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"encoding/json"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+// External plugins are executables speaking JSON lines over stdio: mindia
+// writes one request per line on the plugin stdin and reads one response per
+// line on its stdout. Requests are sent one at a time. Media bytes are base64
+// encoded, as done by encoding/json for []byte.
+const (
+	methodDescribe  = "describe"
+	methodHealth    = "health"
+	methodTransform = "transform"
+	methodUpload    = "upload"
+	methodExecute   = "execute"
+)
+
+type externalRequest struct {
+	Id     uint64      `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+type externalResponse struct {
+	Id     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// describeResult is returned by the plugin once started. Transformations must
+// be prefixed by "c_", UploadHook is one of "", "sync" or "async".
+type describeResult struct {
+	Transformations []string `json:"transformations,omitempty"`
+	UploadHook      string   `json:"upload_hook,omitempty"`
+}
+
+type mediaParams struct {
+	Path        string            `json:"path"`
+	ContentType media.ContentType `json:"content_type"`
+	Metadata    media.Metadata    `json:"metadata,omitempty"`
+	Data        []byte            `json:"data,omitempty"`
+}
+
+type transformParams struct {
+	mediaParams
+	Transformation string            `json:"transformation"`
+	Args           map[string]string `json:"args,omitempty"`
+}
+
+// mediaResult holds what a plugin derived from a media, every field is
+// optional.
+type mediaResult struct {
+	Data        []byte            `json:"data,omitempty"`
+	ContentType media.ContentType `json:"content_type,omitempty"`
+	Tags        []media.Tag       `json:"tags,omitempty"`
+	Metadata    media.Metadata    `json:"metadata,omitempty"`
+}
+
+type executeResult struct {
+	// Continue asks the scheduler to run the task again later with Details.
+	Continue bool        `json:"continue,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
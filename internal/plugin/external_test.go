@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+const (
+	helperEnv = "MINDIA_EXTERNAL_PLUGIN_HELPER"
+	// helperHookEnv is the upload hook mode the helper describes.
+	helperHookEnv = "MINDIA_EXTERNAL_PLUGIN_HELPER_HOOK"
+)
+
+// TestExternalPluginHelper is not a real test, it is the external plugin
+// started by the tests below.
+func TestExternalPluginHelper(t *testing.T) {
+	if os.Getenv(helperEnv) != "1" {
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var req struct {
+			Id     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(scanner.Bytes(), &req)
+
+		var result interface{}
+		switch req.Method {
+		case methodDescribe:
+			result = describeResult{Transformations: []string{"c_upper"}, UploadHook: os.Getenv(helperHookEnv)}
+		case methodTransform:
+			var params transformParams
+			json.Unmarshal(req.Params, &params)
+			if params.Args["sleep"] != "" {
+				time.Sleep(time.Second)
+			}
+			result = mediaResult{
+				Data: bytes.ToUpper(params.Data),
+				Tags: []media.Tag{{Value: "upper", Provider: "helper"}},
+			}
+		}
+		res, _ := json.Marshal(externalResponse{Id: req.Id, Result: mustMarshal(result)})
+		os.Stdout.Write(append(res, '\n'))
+	}
+	os.Exit(0)
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func newHelperPlugin(t *testing.T, timeout time.Duration) *ExternalPlugin {
+	t.Setenv(helperEnv, "1")
+	p, err := NewExternalPlugin(nil, ExternalPluginConfig{
+		Name:    "helper",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestExternalPluginHelper"},
+		Timeout: timeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func runStep(p *ExternalPlugin, args map[string]string) (pipeline.PipelineCtx, error) {
+	step, err := p.ProvideTransformations()["c_upper"](args)
+	if err != nil {
+		return pipeline.PipelineCtx{}, err
+	}
+	return step.Execute(pipeline.PipelineCtx{
+		Path:        media.NewPath("/a.txt"),
+		Buffer:      pipeline.NewBuffer(bytes.NewReader([]byte("mindia"))),
+		ContentType: "text/plain",
+	})
+}
+
+func TestExternalPluginTransformation(t *testing.T) {
+	p := newHelperPlugin(t, 5*time.Second)
+
+	builder := transform.NewBuilder(nil)
+	pm := NewPluginManager(nil, nil, nil, nil, nil, builder)
+	if err := pm.RegisterPlugin(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := runStep(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(ctx.Buffer.Bytes()); got != "MINDIA" {
+		t.Errorf("got %s, wanted MINDIA", got)
+	}
+	if len(ctx.Tags) != 1 || ctx.Tags[0].Value != "upper" {
+		t.Errorf("got tags %v, wanted the plugin tag", ctx.Tags)
+	}
+}
+
+func TestExternalPluginTimeout(t *testing.T) {
+	p := newHelperPlugin(t, 200*time.Millisecond)
+
+	_, err := runStep(p, map[string]string{"sleep": "1"})
+	if !errors.Is(err, ErrExternalPluginTimeout) {
+		t.Fatalf("got %v, wanted a timeout", err)
+	}
+	if err := p.HealthCheck(); err != nil {
+		t.Errorf("should have restarted the plugin, got %v", err)
+	}
+}
+
+func TestExternalPluginDescriptionChange(t *testing.T) {
+	p := newHelperPlugin(t, 5*time.Second)
+
+	t.Setenv(helperHookEnv, "async")
+	p.Close()
+	if err := p.HealthCheck(); err == nil {
+		t.Errorf("should reject a restarted plugin describing other capabilities")
+	}
+	if p.UploadHookMode() != SyncHook {
+		t.Errorf("should keep the first description")
+	}
+}
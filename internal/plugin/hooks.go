@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"net/http"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+type HookMode int
+
+const (
+	// SyncHook runs while the request is being served, an error fails it.
+	SyncHook HookMode = iota
+	// AsyncHook runs in a scheduler task once the request has been served.
+	AsyncHook
+)
+
+// UploadHook is implemented by plugins that must process a media once
+// uploaded. Sync hooks are called before the media is saved and may update
+// it.
+type UploadHook interface {
+	UploadHookMode() HookMode
+	OnUpload(m *media.Media) error
+}
+
+// DerivedCreatedHook is implemented by plugins that must process a derived
+// media once generated.
+type DerivedCreatedHook interface {
+	OnDerivedCreated(m *media.Media, derived *media.DerivedMedia) error
+}
+
+// DeleteHook is implemented by plugins that must clean up after a media.
+// It is called before the media is deleted.
+type DeleteHook interface {
+	OnDelete(m *media.Media) error
+}
+
+// TransformationsProvider is implemented by plugins adding transformations,
+// keyed by their "c_" prefixed name.
+type TransformationsProvider interface {
+	ProvideTransformations() map[string]transform.StepFactory
+}
+
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+}
+
+// RoutesProvider is implemented by plugins exposing HTTP routes. Routes are
+// served under /<api version>/plugin/<plugin name>.
+type RoutesProvider interface {
+	ProvideRoutes() []Route
+}
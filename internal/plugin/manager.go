@@ -1,23 +1,64 @@
 package plugin
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
 	"github.com/jeremybastin1207/mindia-core/internal/transform"
+	"github.com/rs/zerolog/log"
 )
 
+// HookTaskName is the name of the tasks running the async upload hooks.
+const HookTaskName = "plugin_hook"
+
 type Plugin interface {
 	Name() string
 	Execute(task *scheduler.Task) (*scheduler.Task, error)
 }
 
+// Constructor builds a plugin from the options set in its configuration.
+type Constructor func(pluginManager *PluginManager, options map[string]string) (Plugin, error)
+
+var constructors = map[string]Constructor{
+	ColorizePluginName: NewColorizePlugin,
+}
+
+// EventSubscriber is implemented by plugins reacting to domain events, e.g. to
+// tag a media once uploaded. HandleEvent is called synchronously by the event
+// bus, long running work must be enqueued as a task.
+type EventSubscriber interface {
+	Subscriptions() []event.Type
+	HandleEvent(e event.Event)
+}
+
+// HealthChecker is implemented by plugins depending on a process or a service
+// which may become unavailable.
+type HealthChecker interface {
+	HealthCheck() error
+}
+
+type hookTaskDetails struct {
+	Plugin string `json:"plugin"`
+	Path   string `json:"path"`
+}
+
 type PluginManager struct {
-	fileStorage       media.FileStorer
-	cacheStorage      media.FileStorer
-	mediaStorage      media.Storer
-	taskStorage       scheduler.Storer
-	mediaOptimization *transform.MediaOptimization
-	plugins           map[string]Plugin
+	fileStorage            media.FileStorer
+	cacheStorage           media.FileStorer
+	mediaStorage           media.Storer
+	taskStorage            scheduler.Storer
+	eventBus               *event.Bus
+	transformationsBuilder *transform.Builder
+	mediaOptimization      *transform.MediaOptimization
+	plugins                map[string]Plugin
+	order                  []string
 }
 
 func NewPluginManager(
@@ -25,23 +66,217 @@ func NewPluginManager(
 	cacheStorage media.FileStorer,
 	mediaStorage media.Storer,
 	taskStorage scheduler.Storer,
+	eventBus *event.Bus,
+	transformationsBuilder *transform.Builder,
 ) PluginManager {
 	return PluginManager{
-		fileStorage,
-		cacheStorage,
-		mediaStorage,
-		taskStorage,
-		transform.NewMediaOptimization(),
-		map[string]Plugin{},
+		fileStorage:            fileStorage,
+		cacheStorage:           cacheStorage,
+		mediaStorage:           mediaStorage,
+		taskStorage:            taskStorage,
+		eventBus:               eventBus,
+		transformationsBuilder: transformationsBuilder,
+		mediaOptimization:      transform.NewMediaOptimization(),
+		plugins:                map[string]Plugin{},
+	}
+}
+
+// Enable builds the plugin registered under name with its options and
+// registers it. A plugin with a "command" option is run as an external
+// plugin.
+func (p *PluginManager) Enable(name string, options map[string]string) error {
+	var (
+		pl  Plugin
+		err error
+	)
+	if constructor, ok := constructors[name]; ok {
+		pl, err = constructor(p, options)
+	} else if options["command"] != "" {
+		pl, err = newExternalPluginFromOptions(p, name, options)
+	} else {
+		return fmt.Errorf("unknown plugin %s", name)
 	}
+	if err != nil {
+		return fmt.Errorf("unable to enable plugin %s: %w", name, err)
+	}
+	return p.RegisterPlugin(pl)
 }
 
-func (p *PluginManager) RegisterPlugin(pl Plugin) {
+func (p *PluginManager) RegisterPlugin(pl Plugin) error {
+	if _, ok := p.plugins[pl.Name()]; ok {
+		return fmt.Errorf("plugin %s is already registered", pl.Name())
+	}
+
+	if tp, ok := pl.(TransformationsProvider); ok {
+		if p.transformationsBuilder == nil {
+			return errors.New("no transformations builder to register the transformations into")
+		}
+		for name, factory := range tp.ProvideTransformations() {
+			if err := p.transformationsBuilder.RegisterFactory(name, factory); err != nil {
+				return err
+			}
+		}
+	}
+	if s, ok := pl.(EventSubscriber); ok && p.eventBus != nil {
+		for _, t := range s.Subscriptions() {
+			p.eventBus.Subscribe(t, s.HandleEvent)
+		}
+	}
+
 	p.plugins[pl.Name()] = pl
+	p.order = append(p.order, pl.Name())
+	return nil
 }
 
 func (p *PluginManager) GetPlugin(name string) (Plugin, error) {
-	return p.plugins[name], nil
+	pl, ok := p.plugins[name]
+	if !ok {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrCodePluginNotFound, Msg: fmt.Errorf("plugin: %s", name)}
+	}
+	return pl, nil
+}
+
+// GetPlugins returns the registered plugins in registration order.
+func (p *PluginManager) GetPlugins() []Plugin {
+	plugins := []Plugin{}
+	for _, name := range p.order {
+		plugins = append(plugins, p.plugins[name])
+	}
+	return plugins
+}
+
+// MonitorHealth checks the health of the plugins every interval until ctx is
+// done. External plugins failing their health check are restarted on their
+// next request.
+func (p *PluginManager) MonitorHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, pl := range p.GetPlugins() {
+			if h, ok := pl.(HealthChecker); ok {
+				if err := h.HealthCheck(); err != nil {
+					log.Warn().Err(err).Msgf("plugin %s is unhealthy", pl.Name())
+				}
+			}
+		}
+	}
+}
+
+func (p *PluginManager) Close() {
+	for _, pl := range p.GetPlugins() {
+		if c, ok := pl.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Warn().Err(err).Msgf("unable to close plugin %s", pl.Name())
+			}
+		}
+	}
+}
+
+func (p *PluginManager) Routes() map[string][]Route {
+	routes := map[string][]Route{}
+	for _, pl := range p.GetPlugins() {
+		if rp, ok := pl.(RoutesProvider); ok {
+			routes[pl.Name()] = rp.ProvideRoutes()
+		}
+	}
+	return routes
+}
+
+// OnUpload runs the sync upload hooks, before the media is saved.
+func (p *PluginManager) OnUpload(m *media.Media) error {
+	if p == nil {
+		return nil
+	}
+	for _, pl := range p.GetPlugins() {
+		h, ok := pl.(UploadHook)
+		if !ok || h.UploadHookMode() == AsyncHook {
+			continue
+		}
+		if err := h.OnUpload(m); err != nil {
+			return fmt.Errorf("plugin %s: %w", pl.Name(), err)
+		}
+	}
+	return nil
+}
+
+// EnqueueUploadHooks enqueues the async upload hooks, once the media is
+// saved. A hook failing to be enqueued doesn't fail the upload.
+func (p *PluginManager) EnqueueUploadHooks(m *media.Media) {
+	if p == nil {
+		return
+	}
+	for _, pl := range p.GetPlugins() {
+		h, ok := pl.(UploadHook)
+		if !ok || h.UploadHookMode() != AsyncHook {
+			continue
+		}
+		t := scheduler.NewTask(HookTaskName, hookTaskDetails{
+			Plugin: pl.Name(),
+			Path:   m.Path.ToString(),
+		})
+		t.Status = scheduler.Enqueued
+		t.EnqueuedAt = time.Now()
+		if err := p.taskStorage.EnqueueTask(&t); err != nil {
+			log.Warn().Err(err).Msgf("unable to enqueue the upload hook of plugin %s for %s", pl.Name(), m.Path.ToString())
+		}
+	}
+}
+
+func (p *PluginManager) OnDerivedCreated(m *media.Media, derived *media.DerivedMedia) {
+	if p == nil {
+		return
+	}
+	for _, pl := range p.GetPlugins() {
+		if h, ok := pl.(DerivedCreatedHook); ok {
+			if err := h.OnDerivedCreated(m, derived); err != nil {
+				log.Warn().Err(err).Msgf("plugin %s failed to process derived media %s", pl.Name(), derived.Path.ToString())
+			}
+		}
+	}
+}
+
+func (p *PluginManager) OnDelete(m *media.Media) {
+	if p == nil {
+		return
+	}
+	for _, pl := range p.GetPlugins() {
+		if h, ok := pl.(DeleteHook); ok {
+			if err := h.OnDelete(m); err != nil {
+				log.Warn().Err(err).Msgf("plugin %s failed to process deleted media %s", pl.Name(), m.Path.ToString())
+			}
+		}
+	}
+}
+
+// Execute runs an async upload hook, it is registered as the listener of
+// HookTaskName.
+func (p *PluginManager) Execute(task *scheduler.Task) (*scheduler.Task, error) {
+	var d hookTaskDetails
+	if err := task.DecodeDetails(&d); err != nil {
+		return nil, err
+	}
+	pl, err := p.GetPlugin(d.Plugin)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := pl.(UploadHook)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s has no upload hook", d.Plugin)
+	}
+	m, err := p.mediaStorage.Get(media.NewPath(d.Path))
+	if err != nil {
+		return nil, err
+	}
+	if err := h.OnUpload(m); err != nil {
+		return nil, err
+	}
+	return nil, p.mediaStorage.Save(m)
 }
 
 func (p *PluginManager) GetFileStorage() media.FileStorer {
@@ -60,6 +295,14 @@ func (p *PluginManager) GetTaskStorage() scheduler.Storer {
 	return p.taskStorage
 }
 
+func (p *PluginManager) GetEventBus() *event.Bus {
+	return p.eventBus
+}
+
+func (p *PluginManager) GetTransformationsBuilder() *transform.Builder {
+	return p.transformationsBuilder
+}
+
 func (p *PluginManager) GetMediaOptimization() *transform.MediaOptimization {
 	return p.mediaOptimization
 }
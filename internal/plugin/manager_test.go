@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+type hookedPlugin struct {
+	uploads int
+}
+
+func (p *hookedPlugin) Name() string { return "hooked" }
+
+func (p *hookedPlugin) Execute(task *scheduler.Task) (*scheduler.Task, error) { return nil, nil }
+
+func (p *hookedPlugin) UploadHookMode() HookMode { return SyncHook }
+
+func (p *hookedPlugin) OnUpload(m *media.Media) error {
+	p.uploads++
+	m.Tags = append(m.Tags, media.Tag{Value: "hooked"})
+	return nil
+}
+
+func (p *hookedPlugin) ProvideTransformations() map[string]transform.StepFactory {
+	return map[string]transform.StepFactory{
+		"c_hooked": func(args map[string]string) (pipeline.PipelineStep, error) {
+			return nil, nil
+		},
+	}
+}
+
+type asyncHookedPlugin struct {
+	hookedPlugin
+}
+
+func (p *asyncHookedPlugin) Name() string { return "async_hooked" }
+
+func (p *asyncHookedPlugin) UploadHookMode() HookMode { return AsyncHook }
+
+func (p *asyncHookedPlugin) ProvideTransformations() map[string]transform.StepFactory {
+	return nil
+}
+
+func TestPluginManagerHooks(t *testing.T) {
+	builder := transform.NewBuilder(nil)
+	pm := NewPluginManager(nil, nil, nil, nil, nil, builder)
+	p := &hookedPlugin{}
+	if err := pm.RegisterPlugin(p); err != nil {
+		t.Fatal(err)
+	}
+	async := &asyncHookedPlugin{}
+	if err := pm.RegisterPlugin(async); err != nil {
+		t.Fatal(err)
+	}
+
+	m := media.Media{Path: media.NewPath("/a.jpg")}
+	if err := pm.OnUpload(&m); err != nil {
+		t.Fatal(err)
+	}
+	if p.uploads != 1 || len(m.Tags) != 1 {
+		t.Errorf("sync upload hook should have updated the media")
+	}
+	if async.uploads != 0 {
+		t.Errorf("async upload hook should only be enqueued once the media is saved")
+	}
+
+	if _, err := builder.Build([]transform.Transformation{{Name: "c_hooked"}}); err != nil {
+		t.Errorf("should have registered the plugin transformation, got %v", err)
+	}
+	if err := pm.RegisterPlugin(&hookedPlugin{}); err == nil {
+		t.Errorf("should not register a plugin twice")
+	}
+	if _, err := pm.GetPlugin("unknown"); err == nil {
+		t.Errorf("should fail to get an unknown plugin")
+	}
+}
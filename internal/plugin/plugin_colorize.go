@@ -3,22 +3,63 @@ package plugin
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
-	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jeremybastin1207/mindia-core/internal/event"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
 	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
-	"github.com/jeremybastin1207/mindia-core/pkg/utils"
-	"github.com/replicate/replicate-go"
-	"github.com/stretchr/objx"
 )
 
-const ColorizePluginName = "colorize"
-const version = "376c74a2c9eb442a2ff9391b84dc5b949cd4e80b4dc0565115be0a19b7df0ae6"
-const modelName = "Artistic"
-const renderFactor = 35
+const (
+	ColorizePluginName  = "colorize"
+	defaultModelVersion = "376c74a2c9eb442a2ff9391b84dc5b949cd4e80b4dc0565115be0a19b7df0ae6"
+	defaultModelName    = "Artistic"
+	defaultRenderFactor = 35
+)
+
+type ColorizeConfig struct {
+	// Endpoint is the base URL of the Replicate compatible API.
+	Endpoint     string
+	Token        string
+	ModelVersion string
+	ModelName    string
+	RenderFactor int
+	// PublicBaseUrl is the URL the prediction API downloads the medias from.
+	PublicBaseUrl string
+}
+
+func colorizeConfigFromOptions(options map[string]string) (ColorizeConfig, error) {
+	c := ColorizeConfig{
+		Endpoint:      options["endpoint"],
+		Token:         options["token"],
+		ModelVersion:  options["model_version"],
+		ModelName:     options["model"],
+		RenderFactor:  defaultRenderFactor,
+		PublicBaseUrl: options["public_base_url"],
+	}
+	if c.ModelVersion == "" {
+		c.ModelVersion = defaultModelVersion
+	}
+	if c.ModelName == "" {
+		c.ModelName = defaultModelName
+	}
+	if options["render_factor"] != "" {
+		renderFactor, err := strconv.Atoi(options["render_factor"])
+		if err != nil {
+			return c, fmt.Errorf("invalid render factor: %w", err)
+		}
+		c.RenderFactor = renderFactor
+	}
+	if c.PublicBaseUrl == "" {
+		return c, errors.New("public base url is required")
+	}
+	return c, nil
+}
 
 type colorizeTaskDetails struct {
 	Path         string `json:"path"`
@@ -34,19 +75,30 @@ func NewColorizeTask(path media.Path) scheduler.Task {
 }
 
 type ColorizePlugin struct {
-	pluginManager   *PluginManager
-	replicateClient *replicate.Client
+	pluginManager    *PluginManager
+	predictionClient PredictionClient
+	config           ColorizeConfig
 }
 
-func NewColorizePlugin(pluginManager *PluginManager) ColorizePlugin {
-	replicateClient, err := replicate.NewClient(replicate.WithTokenFromEnv())
+// NewColorizePlugin is the plugin constructor, it creates a Replicate client
+// from the plugin options.
+func NewColorizePlugin(pluginManager *PluginManager, options map[string]string) (Plugin, error) {
+	config, err := colorizeConfigFromOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	predictionClient, err := NewReplicatePredictionClient(config.Endpoint, config.Token, config.ModelVersion)
 	if err != nil {
-		utils.ExitErrorf("unable to create replicate client", err)
+		return nil, fmt.Errorf("unable to create replicate client: %w", err)
 	}
+	return NewColorizePluginWithClient(pluginManager, predictionClient, config), nil
+}
 
-	return ColorizePlugin{
+func NewColorizePluginWithClient(pluginManager *PluginManager, predictionClient PredictionClient, config ColorizeConfig) *ColorizePlugin {
+	return &ColorizePlugin{
 		pluginManager,
-		replicateClient,
+		predictionClient,
+		config,
 	}
 }
 
@@ -56,14 +108,8 @@ func (p *ColorizePlugin) Name() string {
 
 func (p *ColorizePlugin) Execute(task *scheduler.Task) (*scheduler.Task, error) {
 	var d colorizeTaskDetails
-
-	if reflect.TypeOf(task.Details) == reflect.TypeOf(map[string]interface{}{}) {
-		d = colorizeTaskDetails{
-			Path:         objx.New(task.Details).Get("path").Str(),
-			PredictionId: objx.New(task.Details).Get("prediction_id").Str(),
-		}
-	} else {
-		d = task.Details.(colorizeTaskDetails)
+	if err := task.DecodeDetails(&d); err != nil {
+		return nil, err
 	}
 
 	if d.Path == "" {
@@ -79,21 +125,34 @@ func (p *ColorizePlugin) Execute(task *scheduler.Task) (*scheduler.Task, error)
 		}
 		details := colorizeTaskDetails{
 			Path:         path.ToString(),
-			PredictionId: prediction.ID,
+			PredictionId: prediction.Id,
 		}
 		task.Details = details
 		task.Status = scheduler.Processing
 		task.EnqueuedAt = time.Now()
+		task.ReportProgress(10, "prediction created", nil)
 	} else {
-		url, status, err := p.fetchPrediction(path, d.PredictionId)
+		prediction, err := p.predictionClient.GetPrediction(context.Background(), d.PredictionId)
 		if err != nil {
 			return nil, err
 		}
-		if *status == replicate.Succeeded {
-			err := p.savePicture(path, url)
-			return nil, err
+		switch prediction.Status {
+		case PredictionSucceeded:
+			task.ReportProgress(90, "saving colorized picture", nil)
+			err := p.savePicture(path, prediction.Output)
+			if err != nil {
+				return nil, err
+			}
+			colorizedPath := path.AppendSuffix(ColorizePluginName)
+			task.ReportProgress(100, "colorized picture saved", map[string]string{
+				"path": colorizedPath.ToString(),
+			})
+			return nil, nil
+		case PredictionFailed, PredictionCanceled:
+			return nil, fmt.Errorf("prediction %s %s", prediction.Id, prediction.Status)
 		}
-		task.Status = toTaskStatus(*status)
+		task.Status = scheduler.Processing
+		task.ReportProgress(50, fmt.Sprintf("prediction %s", prediction.Status), nil)
 	}
 
 	return task, nil
@@ -165,46 +224,29 @@ func (p *ColorizePlugin) savePicture(path media.Path, url string) error {
 		return err
 	}
 
-	return nil
-}
-
-func (p *ColorizePlugin) createPrediction(path media.Path) (*replicate.Prediction, error) {
-	media, err := p.pluginManager.GetFileStorage().Get(path)
-	if err != nil {
-		return nil, err
-	}
-
-	input := replicate.PredictionInput{
-		"input_image":   "https://mindia-storage.ams3.digitaloceanspaces.com" + path.ToString(),
-		"model_name":    modelName,
-		"model_image":   media.Path.ToString(),
-		"render_factor": renderFactor,
+	colorizedPath := m.Path.AppendSuffix(ColorizePluginName)
+	for i := range m.DerivedMedias {
+		if m.DerivedMedias[i].Path == colorizedPath {
+			p.pluginManager.OnDerivedCreated(m, &m.DerivedMedias[i])
+			p.pluginManager.GetEventBus().Publish(event.DerivedCreated, m.DerivedMedias[i])
+		}
 	}
 
-	return p.replicateClient.CreatePrediction(context.Background(), version, input, nil, false)
+	return nil
 }
 
-func (p *ColorizePlugin) fetchPrediction(path media.Path, predictionId string) (string, *replicate.Status, error) {
-	prediction, err := p.replicateClient.GetPrediction(context.Background(), predictionId)
+func (p *ColorizePlugin) createPrediction(path media.Path) (*Prediction, error) {
+	_, err := p.pluginManager.GetFileStorage().Get(path)
 	if err != nil {
-		return "", nil, err
-	}
-	if prediction.Output != nil {
-		predId := prediction.Output.(string)
-		return predId, &prediction.Status, nil
+		return nil, err
 	}
-	return "", &prediction.Status, nil
-}
 
-func toTaskStatus(s replicate.Status) scheduler.TaskStatus {
-	switch s {
-	case replicate.Starting, replicate.Processing:
-		return scheduler.Processing
-	case replicate.Failed, replicate.Succeeded:
-		return scheduler.Finished
-	default:
-		return scheduler.Canceled
+	input := PredictionInput{
+		"input_image":   strings.TrimSuffix(p.config.PublicBaseUrl, "/") + path.ToString(),
+		"model_name":    p.config.ModelName,
+		"render_factor": p.config.RenderFactor,
 	}
+	return p.predictionClient.CreatePrediction(context.Background(), input)
 }
 
 func (p *ColorizePlugin) getTaskName(path media.Path) string {
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+)
+
+// newFakeReplicate serves the subset of the Replicate API used by the colorize
+// plugin, the prediction status is the one returned by the status func.
+func newFakeReplicate(t *testing.T, status func() string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/predictions":
+			var body struct {
+				Version string                 `json:"version"`
+				Input   map[string]interface{} `json:"input"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Version != "v1" || body.Input["input_image"] != "http://mindia.local/a.jpg" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "p1", "status": "starting"})
+		case r.Method == "GET" && r.URL.Path == "/predictions/p1":
+			p := map[string]interface{}{"id": "p1", "status": status()}
+			if p["status"] == "succeeded" {
+				p["output"] = "http://mindia.local/a_colorized.jpg"
+			}
+			json.NewEncoder(w).Encode(p)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestReplicatePredictionClient(t *testing.T) {
+	server := newFakeReplicate(t, func() string { return "succeeded" })
+	defer server.Close()
+
+	client, err := NewReplicatePredictionClient(server.URL, "token", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prediction, err := client.CreatePrediction(context.Background(), PredictionInput{"input_image": "http://mindia.local/a.jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prediction.Id != "p1" || prediction.Status != PredictionStarting {
+		t.Errorf("unexpected prediction %+v", prediction)
+	}
+	prediction, err = client.GetPrediction(context.Background(), "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prediction.Status != PredictionSucceeded || prediction.Output != "http://mindia.local/a_colorized.jpg" {
+		t.Errorf("unexpected prediction %+v", prediction)
+	}
+}
+
+func TestColorizeFailedPrediction(t *testing.T) {
+	status := "processing"
+	server := newFakeReplicate(t, func() string { return status })
+	defer server.Close()
+
+	client, err := NewReplicatePredictionClient(server.URL, "token", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewColorizePluginWithClient(nil, client, ColorizeConfig{PublicBaseUrl: "http://mindia.local"})
+	task := &scheduler.Task{
+		Name:    ColorizePluginName,
+		Details: colorizeTaskDetails{Path: "/a.jpg", PredictionId: "p1"},
+	}
+
+	next, err := p.Execute(task)
+	if err != nil || next == nil {
+		t.Fatalf("processing prediction should continue, got %v", err)
+	}
+	status = "failed"
+	if _, err := p.Execute(next); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("failed prediction should fail the task, got %v", err)
+	}
+}
+
+func TestColorizeConfigFromOptions(t *testing.T) {
+	if _, err := colorizeConfigFromOptions(map[string]string{}); err == nil {
+		t.Errorf("public base url should be required")
+	}
+	if _, err := colorizeConfigFromOptions(map[string]string{"public_base_url": "http://a", "render_factor": "x"}); err == nil {
+		t.Errorf("render factor should be an integer")
+	}
+	c, err := colorizeConfigFromOptions(map[string]string{"public_base_url": "http://a", "render_factor": "20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RenderFactor != 20 || c.ModelName != defaultModelName || c.ModelVersion != defaultModelVersion {
+		t.Errorf("unexpected config %+v", c)
+	}
+}
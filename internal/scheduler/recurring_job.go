@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type RecurringJob struct {
+	Name      string       `json:"name"`
+	Schedule  string       `json:"schedule"`
+	TaskName  string       `json:"task_name"`
+	Details   interface{}  `json:"details,omitempty"`
+	Priority  TaskPriority `json:"priority,omitempty"`
+	LastRunAt time.Time    `json:"last_run_at,omitempty"`
+	NextRunAt time.Time    `json:"next_run_at,omitempty"`
+}
+
+func ParseSchedule(schedule string) (cron.Schedule, error) {
+	return cron.ParseStandard(schedule)
+}
+
+func (j *RecurringJob) IsDue(now time.Time) bool {
+	return !j.NextRunAt.IsZero() && !now.Before(j.NextRunAt)
+}
@@ -1,28 +1,95 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jeremybastin1207/mindia-core/internal/logging"
+	"github.com/robfig/cron/v3"
 )
 
-const tickDuration = 10 * time.Second
+const (
+	defaultWorkers    = 4
+	dequeueTimeout    = 2 * time.Second
+	continuationDelay = 10 * time.Second
+	requeueDelay      = time.Second
+	recurringJobsTick = 10 * time.Second
+	leaderLeaseTTL    = 30 * time.Second
+)
+
+type SchedulerOptions func(*schedulerOptions)
+
+type schedulerOptions struct {
+	workers           int
+	concurrencyLimits map[string]int
+}
+
+func newSchedulerOptions() *schedulerOptions {
+	return &schedulerOptions{
+		workers:           defaultWorkers,
+		concurrencyLimits: map[string]int{},
+	}
+}
+
+func WithWorkers(workers int) SchedulerOptions {
+	return func(o *schedulerOptions) {
+		if workers > 0 {
+			o.workers = workers
+		}
+	}
+}
+
+func WithConcurrencyLimit(taskName string, limit int) SchedulerOptions {
+	return func(o *schedulerOptions) {
+		if limit > 0 {
+			o.concurrencyLimits[taskName] = limit
+		}
+	}
+}
+
+type registeredJob struct {
+	job      RecurringJob
+	schedule cron.Schedule
+}
 
 type TaskScheduler struct {
-	taskStorage Storer
-	logger      logging.Logger
-	listeners   map[string]TaskFunc
+	taskStorage       Storer
+	logger            logging.Logger
+	instanceId        string
+	workers           int
+	concurrencyLimits map[string]int
+	mu                sync.RWMutex
+	listeners         map[string]TaskFunc
+	finishedHooks     []func(task *Task)
+	recurringJobs     map[string]registeredJob
+	running           map[string]int
+	delayed           map[*time.Timer]*Task
+	started           bool
+	stopped           bool
+	stop              chan struct{}
+	wg                sync.WaitGroup
 }
 
-func NewTaskScheduler(taskStorage Storer, logger logging.Logger) TaskScheduler {
-	return TaskScheduler{
-		taskStorage: taskStorage,
-		logger:      logger,
-		listeners:   make(map[string]TaskFunc),
+func NewTaskScheduler(taskStorage Storer, logger logging.Logger, opts ...SchedulerOptions) *TaskScheduler {
+	o := newSchedulerOptions()
+	for _, optFunc := range opts {
+		optFunc(o)
+	}
+	return &TaskScheduler{
+		taskStorage:       taskStorage,
+		logger:            logger,
+		instanceId:        uuid.New().String(),
+		workers:           o.workers,
+		concurrencyLimits: o.concurrencyLimits,
+		listeners:         make(map[string]TaskFunc),
+		recurringJobs:     make(map[string]registeredJob),
+		running:           make(map[string]int),
+		delayed:           make(map[*time.Timer]*Task),
+		stop:              make(chan struct{}),
 	}
 }
 
@@ -31,48 +98,296 @@ func (s *TaskScheduler) RegisterListener(taskName string, taskFunc TaskFunc) {
 		s.logger.Error("invalid taskName or taskFunc")
 		return
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.listeners[taskName] = taskFunc
 }
 
-func (s *TaskScheduler) ProcessTasks() {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+// RegisterFinishedHook registers a hook called each time a task reaches a
+// terminal status.
+func (s *TaskScheduler) RegisterFinishedHook(hook func(task *Task)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishedHooks = append(s.finishedHooks, hook)
+}
+
+func (s *TaskScheduler) RegisterRecurringJob(job RecurringJob) error {
+	if job.Name == "" || job.TaskName == "" {
+		return errors.New("recurring job name and task name are required")
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule for recurring job %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recurringJobs[job.Name] = registeredJob{
+		job:      job,
+		schedule: schedule,
+	}
+	return nil
+}
 
-	ticker := time.NewTicker(tickDuration)
+// Start launches the worker pool. Each worker blocks on the task queue and
+// executes tasks as soon as they are dequeued.
+func (s *TaskScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	s.wg.Add(1)
+	go s.runRecurringJobs()
+	s.logger.Info(fmt.Sprintf("task scheduler started with %d workers", s.workers))
+}
+
+// Shutdown stops dequeuing new tasks and waits for the running ones to finish
+// until ctx is done. Tasks waiting for a delayed re-enqueue are enqueued right
+// away so they are not lost.
+func (s *TaskScheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	close(s.stop)
+
+	pending := []*Task{}
+	for timer, t := range s.delayed {
+		// A timer that already fired is waiting for the lock, its callback
+		// enqueues the task as long as the entry is left.
+		if timer.Stop() {
+			pending = append(pending, t)
+			delete(s.delayed, timer)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, t := range pending {
+		s.enqueue(t)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.logger.Info("task scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("task scheduler did not drain in time: %w", ctx.Err())
+	}
+}
+
+func (s *TaskScheduler) work() {
+	defer s.wg.Done()
 
 	for {
 		select {
-		case <-ticker.C:
-			s.processTask()
-		case <-quit:
-			s.logger.Info("task scheduler stopped")
-			ticker.Stop()
+		case <-s.stop:
 			return
+		default:
 		}
+
+		t, err := s.taskStorage.DequeueTask(dequeueTimeout)
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("Failed to dequeue task: %v", err))
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(requeueDelay):
+			}
+			continue
+		}
+		if t == nil {
+			continue
+		}
+		s.processTask(t)
 	}
 }
 
-func (s *TaskScheduler) processTask() {
-	t, err := s.taskStorage.DequeueTask()
+func (s *TaskScheduler) runRecurringJobs() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(recurringJobsTick)
+	defer ticker.Stop()
+
+	for {
+		s.triggerRecurringJobs(time.Now())
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *TaskScheduler) triggerRecurringJobs(now time.Time) {
+	s.mu.RLock()
+	registered := make([]registeredJob, 0, len(s.recurringJobs))
+	for _, r := range s.recurringJobs {
+		registered = append(registered, r)
+	}
+	s.mu.RUnlock()
+	if len(registered) == 0 {
+		return
+	}
+
+	isLeader, err := s.taskStorage.AcquireLeadership(s.instanceId, leaderLeaseTTL)
 	if err != nil {
+		s.logger.Error(fmt.Sprintf("Failed to acquire scheduler leadership: %v", err))
 		return
 	}
-	if t == nil {
+	if !isLeader {
 		return
 	}
-	l := s.listeners[t.Name]
-	go func(t *Task) {
-		t2, err := l.Execute(t)
-		if err != nil {
-			s.logger.Error(fmt.Sprintf("Failed to execute task: %v", err))
-			return
+
+	stored, err := s.taskStorage.GetRecurringJobs()
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Failed to get recurring jobs: %v", err))
+		return
+	}
+
+	for _, r := range registered {
+		job := r.job
+		if st, ok := stored[job.Name]; ok && st.Schedule == job.Schedule {
+			job.LastRunAt = st.LastRunAt
+			job.NextRunAt = st.NextRunAt
 		}
-		if t2 != nil {
-			err := s.taskStorage.EnqueueTask(t2)
-			if err != nil {
-				s.logger.Error(fmt.Sprintf("Failed to enqueue task: %v", err))
-				return
-			}
+
+		if job.NextRunAt.IsZero() {
+			job.NextRunAt = r.schedule.Next(now)
+		} else if job.IsDue(now) {
+			t := NewTask(job.TaskName, job.Details)
+			t.Priority = job.Priority
+			s.enqueue(&t)
+
+			job.LastRunAt = now
+			job.NextRunAt = r.schedule.Next(now)
+		} else {
+			continue
+		}
+
+		if err := s.taskStorage.SaveRecurringJob(&job); err != nil {
+			s.logger.Error(fmt.Sprintf("Failed to save recurring job %s: %v", job.Name, err))
+		}
+	}
+}
+
+func (s *TaskScheduler) processTask(t *Task) {
+	s.mu.RLock()
+	l, ok := s.listeners[t.Name]
+	s.mu.RUnlock()
+	if !ok {
+		s.logger.Error(fmt.Sprintf("No listener registered for task %s", t.Name))
+		return
+	}
+
+	if !s.acquire(t.Name) {
+		s.enqueueAfter(t, requeueDelay)
+		return
+	}
+	defer s.release(t.Name)
+
+	t.Status = Processing
+	t.StartedAt = time.Now()
+	t.reporter = s.saveTask
+	s.saveTask(t)
+
+	t2, err := l.Execute(t)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Failed to execute task: %v", err))
+		t.Status = Failed
+		t.Error = err.Error()
+		t.FinishedAt = time.Now()
+		s.saveTask(t)
+		s.taskFinished(t)
+		return
+	}
+	if t2 != nil {
+		s.saveTask(t2)
+		delay := continuationDelay
+		if t2.ContinueAfter > 0 {
+			delay = t2.ContinueAfter
+		}
+		s.enqueueAfter(t2, delay)
+		return
+	}
+	t.Status = Finished
+	t.FinishedAt = time.Now()
+	s.saveTask(t)
+	s.taskFinished(t)
+}
+
+func (s *TaskScheduler) taskFinished(t *Task) {
+	s.mu.RLock()
+	hooks := s.finishedHooks
+	s.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(t)
+	}
+}
+
+func (s *TaskScheduler) saveTask(t *Task) {
+	if err := s.taskStorage.SaveTask(t); err != nil {
+		s.logger.Error(fmt.Sprintf("Failed to save task %s: %v", t.Id, err))
+	}
+}
+
+func (s *TaskScheduler) acquire(taskName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit, ok := s.concurrencyLimits[taskName]
+	if ok && s.running[taskName] >= limit {
+		return false
+	}
+	s.running[taskName]++
+	return true
+}
+
+func (s *TaskScheduler) release(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[taskName]--
+}
+
+func (s *TaskScheduler) enqueueAfter(t *Task, delay time.Duration) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		s.enqueue(t)
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		_, ok := s.delayed[timer]
+		delete(s.delayed, timer)
+		s.mu.Unlock()
+		if ok {
+			s.enqueue(t)
 		}
-	}(t)
+	})
+	s.delayed[timer] = t
+	s.mu.Unlock()
+}
+
+func (s *TaskScheduler) enqueue(t *Task) {
+	t.Status = Enqueued
+	t.EnqueuedAt = time.Now()
+	if err := s.taskStorage.EnqueueTask(t); err != nil {
+		s.logger.Error(fmt.Sprintf("Failed to enqueue task: %v", err))
+	}
 }
@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremybastin1207/mindia-core/internal/logging"
+)
+
+type memoryStorer struct {
+	queue  chan *Task
+	jobs   map[string]RecurringJob
+	leader string
+}
+
+func newMemoryStorer() *memoryStorer {
+	return &memoryStorer{
+		queue: make(chan *Task, 100),
+		jobs:  map[string]RecurringJob{},
+	}
+}
+
+func (s *memoryStorer) EnqueueTask(task *Task) error {
+	s.queue <- task
+	return nil
+}
+
+func (s *memoryStorer) DequeueTask(timeout time.Duration) (*Task, error) {
+	select {
+	case t := <-s.queue:
+		return t, nil
+	case <-time.After(timeout):
+		return nil, nil
+	}
+}
+
+func (s *memoryStorer) SaveTask(task *Task) error {
+	return nil
+}
+
+func (s *memoryStorer) GetTask(id uuid.UUID) (*Task, error) {
+	return nil, nil
+}
+
+func (s *memoryStorer) SaveRecurringJob(job *RecurringJob) error {
+	s.jobs[job.Name] = *job
+	return nil
+}
+
+func (s *memoryStorer) GetRecurringJobs() (map[string]RecurringJob, error) {
+	return s.jobs, nil
+}
+
+func (s *memoryStorer) AcquireLeadership(instanceId string, ttl time.Duration) (bool, error) {
+	if s.leader == "" {
+		s.leader = instanceId
+	}
+	return s.leader == instanceId, nil
+}
+
+type blockingTaskFunc struct {
+	running    int32
+	maxRunning int32
+	executed   int32
+	release    chan struct{}
+}
+
+func (f *blockingTaskFunc) Execute(task *Task) (*Task, error) {
+	n := atomic.AddInt32(&f.running, 1)
+	for {
+		max := atomic.LoadInt32(&f.maxRunning)
+		if n <= max || atomic.CompareAndSwapInt32(&f.maxRunning, max, n) {
+			break
+		}
+	}
+	<-f.release
+	atomic.AddInt32(&f.running, -1)
+	atomic.AddInt32(&f.executed, 1)
+	return nil, nil
+}
+
+func enqueueTasks(t *testing.T, s Storer, name string, n int) {
+	for i := 0; i < n; i++ {
+		task := NewTask(name, nil)
+		if err := s.EnqueueTask(&task); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSchedulerRunsTasksConcurrently(t *testing.T) {
+	storer := newMemoryStorer()
+	f := &blockingTaskFunc{release: make(chan struct{})}
+
+	s := NewTaskScheduler(storer, logging.New(), WithWorkers(3))
+	s.RegisterListener("test", f)
+	enqueueTasks(t, storer, "test", 3)
+	s.Start()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&f.running) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&f.running); got != 3 {
+		t.Errorf("got %d running tasks, wanted 3", got)
+	}
+	close(f.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&f.executed); got != 3 {
+		t.Errorf("got %d executed tasks, wanted 3", got)
+	}
+}
+
+func TestSchedulerConcurrencyLimit(t *testing.T) {
+	storer := newMemoryStorer()
+	f := &blockingTaskFunc{release: make(chan struct{})}
+
+	s := NewTaskScheduler(storer, logging.New(), WithWorkers(4), WithConcurrencyLimit("limited", 1))
+	s.RegisterListener("limited", f)
+	enqueueTasks(t, storer, "limited", 3)
+	s.Start()
+
+	time.Sleep(200 * time.Millisecond)
+	close(f.release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&f.executed) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&f.maxRunning); got != 1 {
+		t.Errorf("got %d tasks running at once, wanted 1", got)
+	}
+	if got := atomic.LoadInt32(&f.executed); got != 3 {
+		t.Errorf("got %d executed tasks, wanted 3", got)
+	}
+}
+
+func TestSchedulerShutdownDeadline(t *testing.T) {
+	storer := newMemoryStorer()
+	f := &blockingTaskFunc{release: make(chan struct{})}
+	defer close(f.release)
+
+	s := NewTaskScheduler(storer, logging.New(), WithWorkers(1))
+	s.RegisterListener("test", f)
+	enqueueTasks(t, storer, "test", 1)
+	s.Start()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&f.running) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err == nil {
+		t.Errorf("should have failed to drain a blocked task")
+	}
+}
+
+func TestSchedulerRecurringJobsSingleLeader(t *testing.T) {
+	storer := newMemoryStorer()
+	job := RecurringJob{Name: "cleanup", Schedule: "@every 1m", TaskName: "cleanup"}
+
+	leader := NewTaskScheduler(storer, logging.New())
+	follower := NewTaskScheduler(storer, logging.New())
+	for _, s := range []*TaskScheduler{leader, follower} {
+		if err := s.RegisterRecurringJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	leader.triggerRecurringJobs(now)
+	if len(storer.queue) != 0 {
+		t.Errorf("should not run a job before its first occurrence")
+	}
+
+	later := now.Add(61 * time.Second)
+	follower.triggerRecurringJobs(later)
+	if len(storer.queue) != 0 {
+		t.Errorf("only the leader should trigger recurring jobs")
+	}
+	leader.triggerRecurringJobs(later)
+	leader.triggerRecurringJobs(later)
+	if len(storer.queue) != 1 {
+		t.Errorf("got %d enqueued tasks, wanted 1", len(storer.queue))
+	}
+	if got := storer.jobs["cleanup"].LastRunAt; !got.Equal(later) {
+		t.Errorf("got last run at %v, wanted %v", got, later)
+	}
+}
+
+func TestSchedulerRejectsInvalidSchedule(t *testing.T) {
+	s := NewTaskScheduler(newMemoryStorer(), logging.New())
+	err := s.RegisterRecurringJob(RecurringJob{Name: "bad", Schedule: "every day", TaskName: "bad"})
+	if err == nil {
+		t.Errorf("should have rejected an invalid cron expression")
+	}
+}
@@ -1,6 +1,22 @@
 package scheduler
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type Storer interface {
+	// EnqueueTask saves the task state and pushes it on the queue.
 	EnqueueTask(task *Task) error
-	DequeueTask() (*Task, error)
+	// DequeueTask blocks until a task is available or the timeout expires,
+	// in which case it returns a nil task. Higher priorities are served first.
+	DequeueTask(timeout time.Duration) (*Task, error)
+	SaveTask(task *Task) error
+	GetTask(id uuid.UUID) (*Task, error)
+	SaveRecurringJob(job *RecurringJob) error
+	GetRecurringJobs() (map[string]RecurringJob, error)
+	// AcquireLeadership grants the leader lease to instanceId, or renews it if
+	// instanceId already holds it. Only the leader triggers recurring jobs.
+	AcquireLeadership(instanceId string, ttl time.Duration) (bool, error)
 }
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,26 +13,78 @@ const (
 	Enqueued   TaskStatus = "enqueued"
 	Processing TaskStatus = "processing"
 	Finished   TaskStatus = "finished"
+	Failed     TaskStatus = "failed"
 	Canceled   TaskStatus = "canceled"
 )
 
+func (s TaskStatus) IsTerminal() bool {
+	return s == Finished || s == Failed || s == Canceled
+}
+
+type TaskPriority int
+
+const (
+	LowPriority    TaskPriority = -1
+	NormalPriority TaskPriority = 0
+	HighPriority   TaskPriority = 1
+)
+
+// Priorities lists the task priorities in the order queues must be drained.
+var Priorities = []TaskPriority{HighPriority, NormalPriority, LowPriority}
+
+type TaskProgress struct {
+	Percent        int         `json:"percent"`
+	Message        string      `json:"message,omitempty"`
+	PartialResults interface{} `json:"partial_results,omitempty"`
+}
+
 type Task struct {
-	Id         uuid.UUID   `json:"id"`
-	Name       string      `json:"name"`
-	Status     TaskStatus  `json:"status"`
-	Details    interface{} `json:"details,omitempty"`
-	Error      string      `json:"error,omitempty"`
-	EnqueuedAt time.Time   `json:"enqueued_at,omitempty"`
-	StartedAt  time.Time   `json:"started_at,omitempty"`
-	FinishedAt time.Time   `json:"finished_at,omitempty"`
+	Id         uuid.UUID     `json:"id"`
+	Name       string        `json:"name"`
+	Status     TaskStatus    `json:"status"`
+	Priority   TaskPriority  `json:"priority,omitempty"`
+	Details    interface{}   `json:"details,omitempty"`
+	Progress   *TaskProgress `json:"progress,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	EnqueuedAt time.Time     `json:"enqueued_at,omitempty"`
+	StartedAt  time.Time     `json:"started_at,omitempty"`
+	FinishedAt time.Time     `json:"finished_at,omitempty"`
+	// ContinueAfter is the delay before the task, returned by its listener
+	// to be continued, runs again. continuationDelay when zero.
+	ContinueAfter time.Duration `json:"continue_after,omitempty"`
+	reporter      func(task *Task)
 }
 
 func NewTask(name string, details interface{}) Task {
 	return Task{
-		Id:      uuid.New(),
-		Name:    name,
-		Details: details,
+		Id:       uuid.New(),
+		Name:     name,
+		Priority: NormalPriority,
+		Details:  details,
+	}
+}
+
+// ReportProgress updates the task progress and, when the task is run by the
+// scheduler, persists it so that it can be streamed to clients.
+func (t *Task) ReportProgress(percent int, message string, partialResults interface{}) {
+	t.Progress = &TaskProgress{
+		Percent:        percent,
+		Message:        message,
+		PartialResults: partialResults,
+	}
+	if t.reporter != nil {
+		t.reporter(t)
+	}
+}
+
+// DecodeDetails decodes the task details into v. Details are a plain map once
+// the task went through the storage, so they are converted through JSON.
+func (t *Task) DecodeDetails(v interface{}) error {
+	b, err := json.Marshal(t.Details)
+	if err != nil {
+		return err
 	}
+	return json.Unmarshal(b, v)
 }
 
 type TaskFunc interface {
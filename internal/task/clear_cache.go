@@ -2,30 +2,38 @@ package task
 
 import (
 	"github.com/jeremybastin1207/mindia-core/internal/analytics"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 )
 
 type ClearCacheTask struct {
 	cacheStorage      media.FileStorer
 	analyticsRecorder analytics.AnalyticsRecorder
+	eventBus          *event.Bus
 }
 
 func NewClearCacheTask(
 	cacheStorage media.FileStorer,
 	analyticsRecorder analytics.AnalyticsRecorder,
+	eventBus *event.Bus,
 ) ClearCacheTask {
 	return ClearCacheTask{
 		cacheStorage,
 		analyticsRecorder,
+		eventBus,
 	}
 }
 
 func (c *ClearCacheTask) Clear(p media.Path) error {
 	defer c.analyticsRecorder.RecordCacheClear()
-	return c.cacheStorage.Delete(p)
+	err := c.cacheStorage.Delete(p)
+	if err != nil {
+		return err
+	}
+	c.eventBus.Publish(event.CacheCleared, p)
+	return nil
 }
 
 func (c *ClearCacheTask) ClearAll() error {
-	defer c.analyticsRecorder.RecordCacheClear()
-	return c.cacheStorage.Delete(media.NewPath("/"))
+	return c.Clear(media.NewPath("/"))
 }
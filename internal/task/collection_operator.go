@@ -0,0 +1,223 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/exp/slices"
+)
+
+type CollectionOperator struct {
+	collectionStorage collection.Storer
+	mediaStorage      media.Storer
+	downloadMedia     *DownloadMediaTask
+}
+
+func NewCollectionOperator(
+	collectionStorage collection.Storer,
+	mediaStorage media.Storer,
+	downloadMedia *DownloadMediaTask,
+) CollectionOperator {
+	return CollectionOperator{
+		collectionStorage,
+		mediaStorage,
+		downloadMedia,
+	}
+}
+
+func (o *CollectionOperator) GetAll() ([]collection.Collection, error) {
+	return o.collectionStorage.GetAll()
+}
+
+func (o *CollectionOperator) Get(id string) (*collection.Collection, error) {
+	return o.collectionStorage.Get(id)
+}
+
+func (o *CollectionOperator) Create(name string, description string) (*collection.Collection, error) {
+	if name == "" {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("name is required")}
+	}
+	c := collection.Collection{
+		Id:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		Medias:      []string{},
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	err := o.collectionStorage.Save(c)
+	return &c, err
+}
+
+func (o *CollectionOperator) Update(id string, name string, description string) (*collection.Collection, error) {
+	if name == "" {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("name is required")}
+	}
+	return o.update(id, func(c *collection.Collection) error {
+		c.Name = name
+		c.Description = description
+		return nil
+	})
+}
+
+func (o *CollectionOperator) Delete(id string) error {
+	if _, err := o.collectionStorage.Get(id); err != nil {
+		return err
+	}
+	return o.collectionStorage.Delete(id)
+}
+
+// AddMedias inserts the medias at position, at the end when position is
+// negative. The members already in the collection are left in place.
+func (o *CollectionOperator) AddMedias(id string, paths []string, position int) (*collection.Collection, error) {
+	if err := o.validateMedias(paths); err != nil {
+		return nil, err
+	}
+	return o.update(id, func(c *collection.Collection) error {
+		added := []string{}
+		for _, p := range paths {
+			if !c.Contains(p) && !slices.Contains(added, p) {
+				added = append(added, p)
+			}
+		}
+		if position < 0 || position > len(c.Medias) {
+			position = len(c.Medias)
+		}
+		c.Medias = slices.Insert(c.Medias, position, added...)
+		return nil
+	})
+}
+
+func (o *CollectionOperator) RemoveMedias(id string, paths []string) (*collection.Collection, error) {
+	return o.update(id, func(c *collection.Collection) error {
+		removeMedias(c, paths)
+		return nil
+	})
+}
+
+// Reorder sets the order of the members, paths must list them all.
+func (o *CollectionOperator) Reorder(id string, paths []string) (*collection.Collection, error) {
+	return o.update(id, func(c *collection.Collection) error {
+		sorted := slices.Clone(paths)
+		slices.Sort(sorted)
+		current := slices.Clone(c.Medias)
+		slices.Sort(current)
+		if !slices.Equal(sorted, current) {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("paths must list every media of the collection once")}
+		}
+		c.Medias = slices.Clone(paths)
+		return nil
+	})
+}
+
+// SetCover sets the media shown for the collection, any media can be used.
+// An empty path removes the cover.
+func (o *CollectionOperator) SetCover(id string, path string) (*collection.Collection, error) {
+	if path != "" {
+		if err := o.validateMedias([]string{path}); err != nil {
+			return nil, err
+		}
+	}
+	return o.update(id, func(c *collection.Collection) error {
+		c.Cover = path
+		return nil
+	})
+}
+
+// Download returns a zip archive of the medias of the collection.
+func (o *CollectionOperator) Download(id string) (media.Body, error) {
+	c, err := o.collectionStorage.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	paths := []media.Path{}
+	for _, p := range c.Medias {
+		paths = append(paths, media.NewPath(p))
+	}
+	return o.downloadMedia.DownloadMultiple(paths)
+}
+
+func (o *CollectionOperator) update(id string, fn func(c *collection.Collection) error) (*collection.Collection, error) {
+	return o.collectionStorage.Update(id, func(c *collection.Collection) error {
+		if err := fn(c); err != nil {
+			return err
+		}
+		c.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (o *CollectionOperator) validateMedias(paths []string) error {
+	if len(paths) == 0 {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("paths are required")}
+	}
+	for _, p := range paths {
+		if len(p) == 0 || p[0] != '/' {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("path %s must be absolute", p)}
+		}
+		if _, err := o.mediaStorage.Get(media.NewPath(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeMedias(c *collection.Collection, paths []string) {
+	c.Medias = slices.DeleteFunc(c.Medias, func(p string) bool {
+		return slices.Contains(paths, p)
+	})
+	if slices.Contains(paths, c.Cover) {
+		c.Cover = ""
+	}
+}
+
+// HandleEvent keeps the collections pointing at the medias when they are
+// moved or deleted.
+func (o *CollectionOperator) HandleEvent(e event.Event) {
+	var from, to string
+	switch data := e.Data.(type) {
+	case *media.Media:
+		if e.Type != event.MediaDeleted {
+			return
+		}
+		from = data.Path.ToString()
+	case MediaWithOrigin:
+		if e.Type != event.MediaMoved {
+			return
+		}
+		from, to = data.From.ToString(), data.Media.Path.ToString()
+	default:
+		return
+	}
+
+	collections, err := o.collectionStorage.GetByMedia(from)
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to update the collections")
+		return
+	}
+	for _, c := range collections {
+		_, err := o.update(c.Id, func(c *collection.Collection) error {
+			if to == "" {
+				removeMedias(c, []string{from})
+				return nil
+			}
+			if i := slices.Index(c.Medias, from); i >= 0 {
+				c.Medias[i] = to
+			}
+			if c.Cover == from {
+				c.Cover = to
+			}
+			return nil
+		})
+		if err != nil {
+			log.Warn().Err(err).Msgf("unable to update collection %s", c.Id)
+		}
+	}
+}
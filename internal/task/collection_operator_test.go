@@ -0,0 +1,147 @@
+package task
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+type memoryCollectionStorer map[string]collection.Collection
+
+func (s memoryCollectionStorer) GetAll() ([]collection.Collection, error) {
+	collections := []collection.Collection{}
+	for _, c := range s {
+		collections = append(collections, c)
+	}
+	return collections, nil
+}
+
+func (s memoryCollectionStorer) Get(id string) (*collection.Collection, error) {
+	if c, ok := s[id]; ok {
+		return &c, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeCollectionNotFound)
+}
+
+func (s memoryCollectionStorer) GetByMedia(path string) ([]collection.Collection, error) {
+	collections := []collection.Collection{}
+	for _, c := range s {
+		if c.Refers(path) {
+			collections = append(collections, c)
+		}
+	}
+	return collections, nil
+}
+
+func (s memoryCollectionStorer) Update(id string, fn func(c *collection.Collection) error) (*collection.Collection, error) {
+	c, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(c); err != nil {
+		return nil, err
+	}
+	s[id] = *c
+	return c, nil
+}
+
+func (s memoryCollectionStorer) Save(c collection.Collection) error {
+	s[c.Id] = c
+	return nil
+}
+
+func (s memoryCollectionStorer) Delete(id string) error {
+	delete(s, id)
+	return nil
+}
+
+func TestCollectionOperator(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	bus := event.NewBus()
+	j, _ := newTestJournal(t)
+	moveMedia := NewMoveMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, bus, j)
+	deleteMedia := NewDeleteMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, nopRecorder{}, nil, bus, j)
+	operator := NewCollectionOperator(memoryCollectionStorer{}, s.MediaStorage, nil)
+	bus.Subscribe(event.MediaDeleted, operator.HandleEvent)
+	bus.Subscribe(event.MediaMoved, operator.HandleEvent)
+
+	var (
+		p1 = "/a/" + fsckUuid1 + ".txt"
+		p2 = "/b/" + fsckUuid2 + ".txt"
+		p3 = "/c/" + fsckUuid3 + ".txt"
+	)
+	for _, p := range []string{p1, p2, p3} {
+		if err := s.FileStorage.Upload(media.UploadInput{Path: media.NewPath(p), Body: bytes.NewReader([]byte("12345")), ContentLength: 5}); err != nil {
+			t.Fatal(err)
+		}
+		m := media.Media{Path: media.NewPath(p), ContentType: "text/plain", ContentLength: 5, DerivedMedias: []media.DerivedMedia{}, CreatedAt: time.Now()}
+		if err := s.MediaStorage.Save(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := operator.Create("holidays", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := operator.AddMedias(c.Id, []string{p1, p3}, -1); err != nil {
+		t.Fatal(err)
+	}
+	c, err = operator.AddMedias(c.Id, []string{p2, p1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Medias, []string{p1, p2, p3}) {
+		t.Errorf("unexpected medias %v", c.Medias)
+	}
+	if _, err := operator.AddMedias(c.Id, []string{"/unknown.txt"}, -1); err == nil {
+		t.Errorf("adding an unknown media should fail")
+	}
+	if _, err := operator.Reorder(c.Id, []string{p3, p1}); err == nil {
+		t.Errorf("reordering without every media should fail")
+	}
+	c, err = operator.Reorder(c.Id, []string{p3, p1, p2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := operator.SetCover(c.Id, p1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := moveMedia.Move(media.NewPath(p1), media.NewPath("/d/")); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteMedia.Delete(media.NewPath(p3)); err != nil {
+		t.Fatal(err)
+	}
+	c, err = operator.Get(c.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Medias, []string{"/d/" + fsckUuid1 + ".txt", p2}) || c.Cover != "/d/"+fsckUuid1+".txt" {
+		t.Errorf("collection should follow the moved and deleted medias %+v", *c)
+	}
+
+	c, err = operator.RemoveMedias(c.Id, []string{"/d/" + fsckUuid1 + ".txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Medias, []string{p2}) || c.Cover != "" {
+		t.Errorf("unexpected collection after removal %+v", *c)
+	}
+	if err := operator.Delete(c.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := operator.Get(c.Id); err == nil {
+		t.Errorf("deleted collection should not exist anymore")
+	}
+}
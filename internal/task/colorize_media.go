@@ -1,10 +1,9 @@
 package task
 
 import (
-	"fmt"
-
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/plugin"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
 )
 
 type ColorizeMediaTask struct {
@@ -17,14 +16,19 @@ func NewColorizeMediaTask(pluginManager *plugin.PluginManager) ColorizeMediaTask
 	}
 }
 
-func (t *ColorizeMediaTask) Colorize(path media.Path) (*media.Media, error) {
+func (t *ColorizeMediaTask) Colorize(path media.Path) (*scheduler.Task, error) {
 	p, err := t.pluginManager.GetPlugin(plugin.ColorizePluginName)
 	if err != nil {
 		return nil, err
 	}
 	t2 := plugin.NewColorizeTask(path)
 	t3, err := p.Execute(&t2)
-	fmt.Println(t3)
-	t.pluginManager.GetTaskStorage().EnqueueTask(t3)
-	return nil, err
+	if err != nil {
+		return nil, err
+	}
+	err = t.pluginManager.GetTaskStorage().EnqueueTask(t3)
+	if err != nil {
+		return nil, err
+	}
+	return t3, nil
 }
@@ -1,27 +1,284 @@
 package task
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/pkg/path"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CopyFolderTaskName = "copy_folder"
+
+	copyMediaOperation = "copy_media"
 )
 
+type DerivedCopyMode string
+
+const (
+	// SkipDerived leaves the derived medias of the copy to be generated on
+	// demand.
+	SkipDerived       DerivedCopyMode = ""
+	CopyDerived       DerivedCopyMode = "copy"
+	RegenerateDerived DerivedCopyMode = "regenerate"
+)
+
+type CopyOptions struct {
+	Derived DerivedCopyMode `json:"derived,omitempty"`
+}
+
+func (o CopyOptions) validate() error {
+	switch o.Derived {
+	case SkipDerived, CopyDerived, RegenerateDerived:
+		return nil
+	}
+	return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("derived must be one of copy or regenerate")}
+}
+
+type CopyFolderDetails struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+	CopyOptions
+}
+
+type CopyFolderReport struct {
+	Copied int      `json:"copied"`
+	Total  int      `json:"total"`
+	Failed []string `json:"failed"`
+}
+
+type copyMediaDetails struct {
+	Media   media.Media     `json:"media"`
+	Path    string          `json:"path"`
+	Derived DerivedCopyMode `json:"derived,omitempty"`
+}
+
 type CopyMediaTask struct {
 	fileStorage  media.FileStorer
 	cacheStorage media.FileStorer
 	mediaStorage media.Storer
+	uploadMedia  *UploadMediaTask
+	taskStorage  scheduler.Storer
+	eventBus     *event.Bus
+	journal      *Journal
 }
 
 func NewCopyMediaTask(
 	fileStorage media.FileStorer,
 	cacheStorage media.FileStorer,
 	mediaStorage media.Storer,
+	uploadMedia *UploadMediaTask,
+	taskStorage scheduler.Storer,
+	eventBus *event.Bus,
+	journal *Journal,
 ) CopyMediaTask {
-	return CopyMediaTask{
+	t := CopyMediaTask{
 		fileStorage,
 		cacheStorage,
 		mediaStorage,
+		uploadMedia,
+		taskStorage,
+		eventBus,
+		journal,
+	}
+	journal.RegisterPlanner(copyMediaOperation, t.plan)
+	return t
+}
+
+// Copy duplicates the media, under a new uuid, to the folder of dst.
+func (t *CopyMediaTask) Copy(src media.Path, dst media.Path, opts CopyOptions) (*media.Media, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	m, err := t.mediaStorage.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	return t.copyTo(m, dst.Dir(), opts)
+}
+
+func (t *CopyMediaTask) copyTo(m *media.Media, dir string, opts CopyOptions) (*media.Media, error) {
+	details := copyMediaDetails{
+		Media:   *m,
+		Path:    path.JoinPath(folder.CleanPath(dir), uuid.New().String()+m.Path.Extension()),
+		Derived: opts.Derived,
+	}
+	copied := copiedMedia(details)
+	if err := t.journal.Run(copyMediaOperation, details, t.steps(details, &copied)); err != nil {
+		return nil, err
+	}
+
+	t.eventBus.Publish(event.MediaCopied, MediaWithOrigin{m.Path, &copied})
+	for i := range copied.DerivedMedias {
+		t.eventBus.Publish(event.DerivedCreated, copied.DerivedMedias[i])
+	}
+	return &copied, nil
+}
+
+// plan rebuilds the steps of an interrupted copy, they are all compensated.
+func (t *CopyMediaTask) plan(op *journal.Operation) ([]Step, error) {
+	var details copyMediaDetails
+	if err := op.DecodeDetails(&details); err != nil {
+		return nil, err
+	}
+	copied := copiedMedia(details)
+	return t.steps(details, &copied), nil
+}
+
+func (t *CopyMediaTask) steps(details copyMediaDetails, copied *media.Media) []Step {
+	m := details.Media
+	return []Step{
+		{
+			Name: "copy original",
+			Do:   func() error { return t.fileStorage.Copy(m.Path, copied.Path) },
+			Undo: func() error { return t.fileStorage.Delete(copied.Path) },
+		},
+		{
+			Name: "save record",
+			Do:   func() error { return t.mediaStorage.Save(copied) },
+			Undo: func() error { return t.mediaStorage.Delete(copied.Path) },
+		},
+		{
+			Name: "copy derived medias",
+			Do: func() error {
+				switch details.Derived {
+				case CopyDerived:
+					t.copyDerivedMedias(m, copied)
+				case RegenerateDerived:
+					if err := t.regenerateDerivedMedias(m, copied); err != nil {
+						return err
+					}
+				default:
+					return nil
+				}
+				return t.mediaStorage.Save(copied)
+			},
+			Undo: func() error { return deleteNamedAfter(t.cacheStorage, copied.Path) },
+		},
+	}
+}
+
+func copiedMedia(details copyMediaDetails) media.Media {
+	m := details.Media
+	m.Path = media.NewPath(details.Path)
+	m.DerivedMedias = []media.DerivedMedia{}
+	m.Versions = nil
+	m.CreatedAt = time.Now()
+	m.UpdatedAt = time.Now()
+	return m
+}
+
+// copyDerivedMedias copies the derived medias, renamed after the copy. The
+// missing ones are left to be generated on demand.
+func (t *CopyMediaTask) copyDerivedMedias(m media.Media, copied *media.Media) {
+	for _, dm := range m.DerivedMedias {
+		dst := media.NewPath(path.JoinPath(copied.Path.Dir(), copied.Path.Uuid()+strings.TrimPrefix(dm.Path.Filename(), m.Path.Uuid())))
+		if err := t.cacheStorage.Copy(dm.Path, dst); err != nil {
+			log.Warn().Err(err).Msgf("unable to copy derived media %s", dm.Path.ToString())
+			continue
+		}
+		dm.Path = dst
+		dm.CreatedAt = time.Now()
+		dm.UpdatedAt = time.Now()
+		copied.DerivedMedias = append(copied.DerivedMedias, dm)
+	}
+}
+
+// regenerateDerivedMedias applies again to the copy the transformations the
+// derived medias were generated with.
+func (t *CopyMediaTask) regenerateDerivedMedias(m media.Media, copied *media.Media) error {
+	if len(m.DerivedMedias) == 0 {
+		return nil
+	}
+	transformations := []string{}
+	for _, dm := range m.DerivedMedias {
+		transformations = append(transformations, dm.Path.Transformations())
+	}
+
+	original, err := t.fileStorage.Download(copied.Path)
+	if err != nil {
+		return err
 	}
+	defer original.Body.Close()
+
+	result := pipeline.PipelineCtx{
+		Path:             copied.Path,
+		Buffer:           pipeline.NewBuffer(original.Body),
+		ContentType:      original.ContentType,
+		EmbeddedMetadata: copied.EmbeddedMetadata,
+	}
+	return t.uploadMedia.uploadDerivedMedias(copied, result, transformations)
 }
 
-func (t *CopyMediaTask) Copy(src media.Path, dst media.Path) (*media.Media, error) {
+// CopyFolder copies the medias of the folder src and its sub folders to dst,
+// keeping their relative paths. A media failing to be copied doesn't stop
+// the others.
+func (t *CopyMediaTask) CopyFolder(details CopyFolderDetails, progress func(report *CopyFolderReport)) (*CopyFolderReport, error) {
+	if err := details.validate(); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(details.Src, "/") || !strings.HasPrefix(details.Dst, "/") {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("src and dst must be absolute")}
+	}
+	src := folder.CleanPath(details.Src)
+	dst := folder.CleanPath(details.Dst)
+
+	medias, err := listMedias(t.mediaStorage, media.NewPath(src))
+	if err != nil {
+		return nil, err
+	}
+	report := CopyFolderReport{Total: len(medias), Failed: []string{}}
+	for i := range medias {
+		m := &medias[i]
+		dir := path.JoinPath(dst, strings.TrimPrefix(folder.CleanPath(m.Path.Dir()), src))
+		if _, err := t.copyTo(m, dir, details.CopyOptions); err != nil {
+			log.Warn().Err(err).Msgf("unable to copy media %s", m.Path.ToString())
+			report.Failed = append(report.Failed, m.Path.ToString())
+		} else {
+			report.Copied++
+		}
+		if progress != nil {
+			progress(&report)
+		}
+	}
+	return &report, nil
+}
+
+// EnqueueCopyFolder runs CopyFolder as a task of the scheduler.
+func (t *CopyMediaTask) EnqueueCopyFolder(details CopyFolderDetails) (*scheduler.Task, error) {
+	if err := details.validate(); err != nil {
+		return nil, err
+	}
+	task := scheduler.NewTask(CopyFolderTaskName, details)
+	if err := t.taskStorage.EnqueueTask(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (t *CopyMediaTask) Execute(task *scheduler.Task) (*scheduler.Task, error) {
+	var details CopyFolderDetails
+	if err := task.DecodeDetails(&details); err != nil {
+		return nil, err
+	}
+	report, err := t.CopyFolder(details, func(report *CopyFolderReport) {
+		done := report.Copied + len(report.Failed)
+		task.ReportProgress(done*100/report.Total, fmt.Sprintf("%d of %d medias copied", done, report.Total), report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	task.ReportProgress(100, "copy done", report)
 	return nil, nil
 }
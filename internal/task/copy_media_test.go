@@ -0,0 +1,71 @@
+package task
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func TestCopyMediaTask(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	j, _ := newTestJournal(t)
+	copier := NewCopyMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, nil, nil, event.NewBus(), j)
+
+	upload := func(st media.FileStorer, p string) {
+		err := st.Upload(media.UploadInput{Path: media.NewPath(p), Body: bytes.NewReader([]byte(p)), ContentLength: len(p)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{"/a/" + fsckUuid1 + ".txt", "/a/b/" + fsckUuid2 + ".txt"} {
+		upload(s.FileStorage, p)
+		m := media.Media{Path: media.NewPath(p), ContentType: "text/plain", DerivedMedias: []media.DerivedMedia{}, CreatedAt: time.Now()}
+		if strings.HasPrefix(p, "/a/b") {
+			derived := "/a/b/" + fsckUuid2 + "c_scale,w_10.txt"
+			upload(cacheStorage, derived)
+			m.DerivedMedias = append(m.DerivedMedias, media.DerivedMedia{Path: media.NewPath(derived)})
+		}
+		if err := s.MediaStorage.Save(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copied, err := copier.Copy(media.NewPath("/a/b/"+fsckUuid2+".txt"), media.NewPath("/c"), CopyOptions{Derived: CopyDerived})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Path.Dir() != "/c" || copied.Path.Uuid() == fsckUuid2 {
+		t.Errorf("copy should be in /c under a new uuid, got %s", copied.Path.ToString())
+	}
+	if _, err := s.MediaStorage.Get(copied.Path); err != nil {
+		t.Errorf("copy record should be saved, %v", err)
+	}
+	if len(copied.DerivedMedias) != 1 || copied.DerivedMedias[0].Path.Transformations() != "c_scale,w_10" {
+		t.Fatalf("derived media should be copied, got %+v", copied.DerivedMedias)
+	}
+	if _, err := cacheStorage.Get(copied.DerivedMedias[0].Path); err != nil {
+		t.Errorf("derived file should be copied, %v", err)
+	}
+
+	report, err := copier.CopyFolder(CopyFolderDetails{Src: "/a", Dst: "/d"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Copied != 2 || len(report.Failed) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	result, err := s.MediaStorage.GetMultiple(media.ListQuery{Path: media.NewPath("/d/b"), Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Medias) != 1 || len(result.Medias[0].DerivedMedias) != 0 {
+		t.Errorf("sub folders should be copied without their derived medias, got %+v", result.Medias)
+	}
+}
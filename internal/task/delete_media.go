@@ -2,15 +2,23 @@ package task
 
 import (
 	"github.com/jeremybastin1207/mindia-core/internal/analytics"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/plugin"
 	"github.com/rs/zerolog/log"
 )
 
+const deleteMediaOperation = "delete_media"
+
 type DeleteMediaTask struct {
 	fileStorage       media.FileStorer
 	cacheStorage      media.FileStorer
 	mediaStorage      media.Storer
 	analyticsRecorder analytics.AnalyticsRecorder
+	pluginManager     *plugin.PluginManager
+	eventBus          *event.Bus
+	journal           *Journal
 }
 
 func NewDeleteMediaTask(
@@ -18,13 +26,21 @@ func NewDeleteMediaTask(
 	cacheStorage media.FileStorer,
 	mediaStorage media.Storer,
 	analyticsRecorder analytics.AnalyticsRecorder,
+	pluginManager *plugin.PluginManager,
+	eventBus *event.Bus,
+	journal *Journal,
 ) DeleteMediaTask {
-	return DeleteMediaTask{
+	t := DeleteMediaTask{
 		fileStorage,
 		cacheStorage,
 		mediaStorage,
 		analyticsRecorder,
+		pluginManager,
+		eventBus,
+		journal,
 	}
+	journal.RegisterPlanner(deleteMediaOperation, t.plan)
+	return t
 }
 
 func (t *DeleteMediaTask) Delete(path media.Path) error {
@@ -34,17 +50,60 @@ func (t *DeleteMediaTask) Delete(path media.Path) error {
 	if err != nil {
 		return err
 	}
-	err = t.fileStorage.Delete(path)
+	t.pluginManager.OnDelete(media)
+
+	err = t.journal.Run(deleteMediaOperation, media, t.steps(*media))
 	if err != nil {
-		log.Warn().Err(err)
+		return err
 	}
-	for _, dm := range media.DerivedMedias {
-		err := t.cacheStorage.Delete(dm.Path)
-		if err != nil {
-			log.Warn().Err(err)
-		}
+	t.eventBus.Publish(event.MediaDeleted, media)
+	return nil
+}
+
+func (t *DeleteMediaTask) plan(op *journal.Operation) ([]Step, error) {
+	var m media.Media
+	if err := op.DecodeDetails(&m); err != nil {
+		return nil, err
+	}
+	return t.steps(m), nil
+}
+
+// steps deletes the record first, it is saved back as long as the original
+// is not deleted.
+func (t *DeleteMediaTask) steps(m media.Media) []Step {
+	return []Step{
+		{
+			Name: "delete record",
+			Do:   func() error { return t.mediaStorage.Delete(m.Path) },
+			Undo: func() error { return t.mediaStorage.Save(&m) },
+		},
+		{
+			Name: "delete original",
+			Do:   func() error { return t.fileStorage.Delete(m.Path) },
+		},
+		{
+			Name: "delete derived medias",
+			Do: func() error {
+				for _, dm := range m.DerivedMedias {
+					if err := t.cacheStorage.Delete(dm.Path); err != nil {
+						log.Warn().Err(err).Msgf("unable to delete derived media %s", dm.Path.ToString())
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name: "delete versions",
+			Do: func() error {
+				for _, v := range m.Versions {
+					if err := t.fileStorage.Delete(v.Path); err != nil {
+						log.Warn().Err(err).Msgf("unable to delete version %s", v.Path.ToString())
+					}
+				}
+				return nil
+			},
+		},
 	}
-	return t.mediaStorage.Delete(media.Path)
 }
 
 func (t *DeleteMediaTask) DeleteMultiple(paths []media.Path) error {
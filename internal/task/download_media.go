@@ -8,9 +8,11 @@ import (
 
 	"github.com/jeremybastin1207/mindia-core/internal/analytics"
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/parser"
 	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/jeremybastin1207/mindia-core/internal/plugin"
 	"github.com/jeremybastin1207/mindia-core/internal/transform"
 )
 
@@ -20,8 +22,10 @@ type DownloadMediaTask struct {
 	mediaStorage              media.Storer
 	namedTransformationParser parser.NamedTransformationParser
 	transformationParser      parser.Parser
-	transformationsBuiler     transform.Builder
+	transformationsBuiler     *transform.Builder
 	analyticsRecorder         analytics.AnalyticsRecorder
+	pluginManager             *plugin.PluginManager
+	eventBus                  *event.Bus
 }
 
 func NewDownloadMediaTask(
@@ -29,7 +33,10 @@ func NewDownloadMediaTask(
 	cacheStorage media.FileStorer,
 	mediaStorage media.Storer,
 	namedTransformationStorage transform.Storer,
+	transformationsBuilder *transform.Builder,
 	analyticsRecorder analytics.AnalyticsRecorder,
+	pluginManager *plugin.PluginManager,
+	eventBus *event.Bus,
 ) DownloadMediaTask {
 	return DownloadMediaTask{
 		fileStorage:               fileStorage,
@@ -37,8 +44,10 @@ func NewDownloadMediaTask(
 		mediaStorage:              mediaStorage,
 		namedTransformationParser: parser.NewNamedTransformationParser(namedTransformationStorage),
 		transformationParser:      parser.NewParser(),
-		transformationsBuiler:     transform.NewBuilder(fileStorage),
+		transformationsBuiler:     transformationsBuilder,
 		analyticsRecorder:         analyticsRecorder,
+		pluginManager:             pluginManager,
+		eventBus:                  eventBus,
 	}
 }
 
@@ -143,6 +152,15 @@ func (t *DownloadMediaTask) Download(
 	if err != nil {
 		return nil, nil, err
 	}
+
+	derivedPath := path.AppendSuffix(*parsedTransformations)
+	for i := range m.DerivedMedias {
+		if m.DerivedMedias[i].Path == derivedPath {
+			t.pluginManager.OnDerivedCreated(m, &m.DerivedMedias[i])
+			t.eventBus.Publish(event.DerivedCreated, m.DerivedMedias[i])
+		}
+	}
+
 	return io.NopCloser(result.Buffer.Reader()), &result.ContentType, nil
 }
 
@@ -0,0 +1,101 @@
+package task
+
+import (
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	EvictCacheTaskName = "evict_cache"
+
+	defaultCacheMaxAgeDays = 30
+)
+
+type EvictCacheOptions struct {
+	// MaxAgeDays is the age of the derived files evicted, 30 days by default.
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+}
+
+type EvictCacheReport struct {
+	Evicted int   `json:"evicted"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// CacheEvictor deletes the derived files generated a while ago, they are
+// generated again on demand.
+type CacheEvictor struct {
+	cacheStorage media.FileStorer
+	mediaStorage media.Storer
+}
+
+func NewCacheEvictor(cacheStorage media.FileStorer, mediaStorage media.Storer) CacheEvictor {
+	return CacheEvictor{
+		cacheStorage: cacheStorage,
+		mediaStorage: mediaStorage,
+	}
+}
+
+func (e *CacheEvictor) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	var opts EvictCacheOptions
+	if err := t.DecodeDetails(&opts); err != nil {
+		return nil, err
+	}
+	report, err := e.Evict(opts)
+	if err != nil {
+		return nil, err
+	}
+	t.ReportProgress(100, "eviction done", report)
+	return nil, nil
+}
+
+// Evict deletes the cached files older than the maximum age, then removes
+// them from the derived medias of their records.
+func (e *CacheEvictor) Evict(opts EvictCacheOptions) (*EvictCacheReport, error) {
+	maxAgeDays := opts.MaxAgeDays
+	if maxAgeDays <= 0 {
+		maxAgeDays = defaultCacheMaxAgeDays
+	}
+	before := time.Now().AddDate(0, 0, -maxAgeDays)
+
+	root := media.NewPath("/")
+	cached, err := walkFiles(e.cacheStorage, root)
+	if err != nil {
+		return nil, err
+	}
+	report := EvictCacheReport{}
+	evicted := map[string]bool{}
+	for _, f := range cached {
+		if f.ModifiedAt.IsZero() || !f.ModifiedAt.Before(before) {
+			continue
+		}
+		if err := e.cacheStorage.Delete(f.Path); err != nil {
+			log.Warn().Err(err).Msgf("unable to evict %s", f.Path.ToString())
+			continue
+		}
+		evicted[f.Path.ToString()] = true
+		report.Evicted++
+		report.Bytes += int64(f.ContentLength)
+	}
+	if len(evicted) == 0 {
+		return &report, nil
+	}
+
+	medias, err := listMedias(e.mediaStorage, root)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range medias {
+		for _, dm := range m.DerivedMedias {
+			if evicted[dm.Path.ToString()] {
+				if err := removeDerivedMedias(e.mediaStorage, m.Path, evicted); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+	return &report, nil
+}
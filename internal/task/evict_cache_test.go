@@ -0,0 +1,62 @@
+package task
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func TestCacheEvictor(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: cacheDir})
+
+	original := media.NewPath("/a/" + fsckUuid1 + ".jpg")
+	old := media.NewPath("/a/" + fsckUuid1 + "_c_scale_w_10.jpg")
+	recent := media.NewPath("/a/" + fsckUuid1 + "_c_scale_w_20.jpg")
+	for _, p := range []media.Path{old, recent} {
+		if err := cacheStorage.Upload(media.UploadInput{Path: p, Body: bytes.NewReader([]byte("derived")), ContentLength: 7}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	longAgo := time.Now().AddDate(0, 0, -40)
+	if err := os.Chtimes(filepath.Join(cacheDir, old.ToString()), longAgo, longAgo); err != nil {
+		t.Fatal(err)
+	}
+	m := media.Media{
+		Path:          original,
+		ContentType:   media.ImageJpeg,
+		DerivedMedias: []media.DerivedMedia{{Path: old}, {Path: recent}},
+		CreatedAt:     time.Now(),
+	}
+	if err := s.MediaStorage.Save(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	evictor := NewCacheEvictor(cacheStorage, s.MediaStorage)
+	report, err := evictor.Evict(EvictCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Evicted != 1 || report.Bytes != 7 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if _, err := cacheStorage.Get(old); err == nil {
+		t.Errorf("old derived file should be evicted")
+	}
+	if _, err := cacheStorage.Get(recent); err != nil {
+		t.Errorf("recent derived file should be kept, %v", err)
+	}
+	saved, err := s.MediaStorage.Get(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.DerivedMedias) != 1 || saved.DerivedMedias[0].Path != recent {
+		t.Errorf("evicted derived media should be removed from the record, got %+v", saved.DerivedMedias)
+	}
+}
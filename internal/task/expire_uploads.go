@@ -0,0 +1,59 @@
+package task
+
+import (
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+const ExpireUploadsTaskName = "expire_uploads"
+
+type ExpireUploadsReport struct {
+	Expired int `json:"expired"`
+}
+
+// MediaExpirer deletes the medias uploaded with an expiration once it is
+// past.
+type MediaExpirer struct {
+	mediaStorage media.Storer
+	deleteMedia  *DeleteMediaTask
+}
+
+func NewMediaExpirer(mediaStorage media.Storer, deleteMedia *DeleteMediaTask) MediaExpirer {
+	return MediaExpirer{
+		mediaStorage: mediaStorage,
+		deleteMedia:  deleteMedia,
+	}
+}
+
+func (e *MediaExpirer) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	report, err := e.Expire(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	t.ReportProgress(100, "expiration done", report)
+	return nil, nil
+}
+
+// Expire deletes the medias expired at now, a media failing to be deleted
+// is retried on the next run.
+func (e *MediaExpirer) Expire(now time.Time) (*ExpireUploadsReport, error) {
+	medias, err := listMedias(e.mediaStorage, media.NewPath("/"))
+	if err != nil {
+		return nil, err
+	}
+	report := ExpireUploadsReport{}
+	for _, m := range medias {
+		if m.ExpiresAt == nil || m.ExpiresAt.After(now) {
+			continue
+		}
+		if err := e.deleteMedia.Delete(m.Path); err != nil {
+			log.Warn().Err(err).Msgf("unable to delete expired media %s", m.Path.ToString())
+			continue
+		}
+		report.Expired++
+	}
+	return &report, nil
+}
@@ -0,0 +1,53 @@
+package task
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func TestMediaExpirer(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	j, _ := newTestJournal(t)
+	deleteMedia := NewDeleteMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, nopRecorder{}, nil, event.NewBus(), j)
+	uploadMedia := NewUploadMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, filesystem.NewNamedTransformationStorage(), nil, nil, event.NewBus(), memoryFolderStorer{}, j)
+
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	upload := func(p string, expiresAt *time.Time) media.Path {
+		m, err := uploadMedia.Upload(p, bytes.NewReader([]byte("body")), media.VideoMp4, 0, nil, nil, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Path
+	}
+	expired := upload("/a/"+fsckUuid1+".mp4", &past)
+	pending := upload("/a/"+fsckUuid2+".mp4", &future)
+	kept := upload("/a/"+fsckUuid3+".mp4", nil)
+
+	expirer := NewMediaExpirer(s.MediaStorage, &deleteMedia)
+	report, err := expirer.Expire(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Expired != 1 {
+		t.Errorf("unexpected report %+v", *report)
+	}
+	if _, err := s.MediaStorage.Get(expired); err == nil {
+		t.Errorf("expired media should be deleted")
+	}
+	if _, err := s.FileStorage.Get(expired); err == nil {
+		t.Errorf("original of the expired media should be deleted")
+	}
+	for _, p := range []media.Path{pending, kept} {
+		if _, err := s.MediaStorage.Get(p); err != nil {
+			t.Errorf("media %s should be kept, %v", p.ToString(), err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/pkg/path"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	MoveFolderTaskName   = "move_folder"
+	DeleteFolderTaskName = "delete_folder"
+)
+
+type FolderSummary struct {
+	Path string `json:"path"`
+	// MediaCount and Size, the size of the originals, include the sub
+	// folders.
+	MediaCount int   `json:"media_count"`
+	Size       int64 `json:"size"`
+}
+
+type FolderListing struct {
+	FolderSummary
+	Folders []FolderSummary `json:"folders"`
+}
+
+type MoveFolderDetails struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+type DeleteFolderDetails struct {
+	Path string `json:"path"`
+}
+
+type FolderTaskReport struct {
+	Processed int      `json:"processed"`
+	Total     int      `json:"total"`
+	Failed    []string `json:"failed"`
+}
+
+// FolderOperator manages the folders, the ones holding medias and the empty
+// ones created explicitly, which are recorded as folder settings.
+type FolderOperator struct {
+	mediaStorage  media.Storer
+	folderStorage folder.Storer
+	taskStorage   scheduler.Storer
+	moveMedia     *MoveMediaTask
+	deleteMedia   *DeleteMediaTask
+}
+
+func NewFolderOperator(
+	mediaStorage media.Storer,
+	folderStorage folder.Storer,
+	taskStorage scheduler.Storer,
+	moveMedia *MoveMediaTask,
+	deleteMedia *DeleteMediaTask,
+) FolderOperator {
+	return FolderOperator{
+		mediaStorage,
+		folderStorage,
+		taskStorage,
+		moveMedia,
+		deleteMedia,
+	}
+}
+
+// List returns the folder with its direct sub folders.
+func (o *FolderOperator) List(p string) (*FolderListing, error) {
+	p = folder.CleanPath(p)
+	medias, err := listMedias(o.mediaStorage, media.NewPath(p))
+	if err != nil {
+		return nil, err
+	}
+	settings, err := o.folderStorage.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		listing  = FolderListing{FolderSummary: FolderSummary{Path: p}}
+		children = map[string]*FolderSummary{}
+		exists   = p == "/"
+	)
+	child := func(dir string) *FolderSummary {
+		c := childFolder(p, dir)
+		if c == "" {
+			return nil
+		}
+		if _, ok := children[c]; !ok {
+			children[c] = &FolderSummary{Path: c}
+		}
+		return children[c]
+	}
+	for _, s := range settings {
+		exists = exists || s.Path == p
+		child(s.Path)
+	}
+	for _, m := range medias {
+		exists = true
+		listing.MediaCount++
+		listing.Size += int64(m.ContentLength)
+		if c := child(folder.CleanPath(m.Path.Dir())); c != nil {
+			c.MediaCount++
+			c.Size += int64(m.ContentLength)
+		}
+	}
+	if !exists {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeFolderNotFound)
+	}
+
+	listing.Folders = []FolderSummary{}
+	for _, c := range children {
+		listing.Folders = append(listing.Folders, *c)
+	}
+	sort.Slice(listing.Folders, func(i, j int) bool {
+		return listing.Folders[i].Path < listing.Folders[j].Path
+	})
+	return &listing, nil
+}
+
+// childFolder returns the direct sub folder of p holding dir, an empty
+// string when dir is not in a sub folder of p.
+func childFolder(p string, dir string) string {
+	if !isInFolder(p, dir) || dir == p {
+		return ""
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(dir, p), "/")
+	return path.JoinPath(p, strings.SplitN(rel, "/", 2)[0])
+}
+
+// isInFolder tells whether dir is the folder p or one of its sub folders.
+func isInFolder(p string, dir string) bool {
+	return p == "/" || dir == p || strings.HasPrefix(dir, p+"/")
+}
+
+// Create records an empty folder, it does nothing when the folder exists.
+func (o *FolderOperator) Create(p string) (*folder.Settings, error) {
+	p = folder.CleanPath(p)
+	if p == "/" {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("the root folder can't be created")}
+	}
+	settings, err := o.folderStorage.Get(p)
+	if err == nil {
+		return settings, nil
+	}
+	if e, ok := err.(*mindiaerr.Error); !ok || e.ErrCode != mindiaerr.ErrCodeFolderNotFound {
+		return nil, err
+	}
+	settings = &folder.Settings{
+		Path:      p,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	return settings, o.folderStorage.Save(*settings)
+}
+
+// EnqueueMove moves, or renames, the folder with its content in a task.
+func (o *FolderOperator) EnqueueMove(src string, dst string) (*scheduler.Task, error) {
+	details := MoveFolderDetails{Src: folder.CleanPath(src), Dst: folder.CleanPath(dst)}
+	switch {
+	case details.Src == "/":
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("the root folder can't be moved")}
+	case isInFolder(details.Src, details.Dst):
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("a folder can't be moved into itself")}
+	}
+	return o.enqueue(MoveFolderTaskName, details)
+}
+
+// EnqueueDelete deletes the folder with its content in a task.
+func (o *FolderOperator) EnqueueDelete(p string) (*scheduler.Task, error) {
+	details := DeleteFolderDetails{Path: folder.CleanPath(p)}
+	if details.Path == "/" {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("the root folder can't be deleted")}
+	}
+	return o.enqueue(DeleteFolderTaskName, details)
+}
+
+func (o *FolderOperator) enqueue(name string, details interface{}) (*scheduler.Task, error) {
+	t := scheduler.NewTask(name, details)
+	if err := o.taskStorage.EnqueueTask(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (o *FolderOperator) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	var (
+		report *FolderTaskReport
+		err    error
+	)
+	progress := func(r *FolderTaskReport) {
+		t.ReportProgress(r.Processed*100/r.Total, fmt.Sprintf("%d of %d medias processed", r.Processed, r.Total), r)
+	}
+	switch t.Name {
+	case MoveFolderTaskName:
+		var details MoveFolderDetails
+		if err := t.DecodeDetails(&details); err != nil {
+			return nil, err
+		}
+		report, err = o.Move(details, progress)
+	case DeleteFolderTaskName:
+		var details DeleteFolderDetails
+		if err := t.DecodeDetails(&details); err != nil {
+			return nil, err
+		}
+		report, err = o.Delete(details, progress)
+	default:
+		return nil, fmt.Errorf("task %s not supported", t.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Failed) > 0 {
+		return nil, fmt.Errorf("%d of %d medias failed, run the task again to retry them", len(report.Failed), report.Total)
+	}
+	t.ReportProgress(100, "done", report)
+	return nil, nil
+}
+
+// Move moves the medias of the folder, each with its derived medias, then
+// the settings of the folder and its sub folders once all medias moved.
+func (o *FolderOperator) Move(details MoveFolderDetails, progress func(*FolderTaskReport)) (*FolderTaskReport, error) {
+	return o.forEachMedia(details.Src, progress, func(m *media.Media) error {
+		dir := path.JoinPath(details.Dst, strings.TrimPrefix(folder.CleanPath(m.Path.Dir()), details.Src))
+		_, err := o.moveMedia.Move(m.Path, media.NewPath(dir))
+		return err
+	}, func(s folder.Settings) error {
+		moved := s
+		moved.Path = path.JoinPath(details.Dst, strings.TrimPrefix(s.Path, details.Src))
+		moved.UpdatedAt = time.Now()
+		if _, err := o.folderStorage.Get(moved.Path); err != nil {
+			// The settings already at the destination are kept.
+			if err := o.folderStorage.Save(moved); err != nil {
+				return err
+			}
+		}
+		return o.folderStorage.Delete(s.Path)
+	})
+}
+
+// Delete deletes the medias of the folder, each with its derived medias,
+// then the settings of the folder and its sub folders once all medias are
+// deleted.
+func (o *FolderOperator) Delete(details DeleteFolderDetails, progress func(*FolderTaskReport)) (*FolderTaskReport, error) {
+	return o.forEachMedia(details.Path, progress, func(m *media.Media) error {
+		return o.deleteMedia.Delete(m.Path)
+	}, func(s folder.Settings) error {
+		return o.folderStorage.Delete(s.Path)
+	})
+}
+
+func (o *FolderOperator) forEachMedia(
+	p string,
+	progress func(*FolderTaskReport),
+	mediaFn func(m *media.Media) error,
+	settingsFn func(s folder.Settings) error,
+) (*FolderTaskReport, error) {
+	p = folder.CleanPath(p)
+	medias, err := listMedias(o.mediaStorage, media.NewPath(p))
+	if err != nil {
+		return nil, err
+	}
+	report := FolderTaskReport{Total: len(medias), Failed: []string{}}
+	for i := range medias {
+		if err := mediaFn(&medias[i]); err != nil {
+			log.Warn().Err(err).Msgf("unable to process media %s", medias[i].Path.ToString())
+			report.Failed = append(report.Failed, medias[i].Path.ToString())
+		}
+		report.Processed++
+		if progress != nil {
+			progress(&report)
+		}
+	}
+	if len(report.Failed) > 0 {
+		return &report, nil
+	}
+
+	settings, err := o.folderStorage.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		if !isInFolder(p, s.Path) {
+			continue
+		}
+		if err := settingsFn(s); err != nil {
+			return nil, err
+		}
+	}
+	return &report, nil
+}
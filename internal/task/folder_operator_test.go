@@ -0,0 +1,151 @@
+package task
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+type memoryFolderStorer map[string]folder.Settings
+
+func (s memoryFolderStorer) GetAll() ([]folder.Settings, error) {
+	settings := []folder.Settings{}
+	for _, f := range s {
+		settings = append(settings, f)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Path < settings[j].Path })
+	return settings, nil
+}
+
+func (s memoryFolderStorer) Get(path string) (*folder.Settings, error) {
+	if settings, ok := s[path]; ok {
+		return &settings, nil
+	}
+	return nil, mindiaerr.New(mindiaerr.ErrCodeFolderNotFound)
+}
+
+func (s memoryFolderStorer) Save(settings folder.Settings) error {
+	s[settings.Path] = settings
+	return nil
+}
+
+func (s memoryFolderStorer) Delete(path string) error {
+	delete(s, path)
+	return nil
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) RecordBandwithUsage(bytesLength int)      {}
+func (nopRecorder) RecordMediaRequest()                      {}
+func (nopRecorder) RecordMediaDelete()                       {}
+func (nopRecorder) RecordDataStorageUsage(bytesUsage int64)  {}
+func (nopRecorder) RecordCacheStorageUsage(bytesUsage int64) {}
+func (nopRecorder) RecordTaskCreation()                      {}
+func (nopRecorder) RecordCacheClear()                        {}
+
+func TestFolderOperator(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	folderStorage := memoryFolderStorer{}
+	j, _ := newTestJournal(t)
+	moveMedia := NewMoveMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, event.NewBus(), j)
+	deleteMedia := NewDeleteMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, nopRecorder{}, nil, event.NewBus(), j)
+	operator := NewFolderOperator(s.MediaStorage, folderStorage, nil, &moveMedia, &deleteMedia)
+
+	for _, p := range []string{"/a/" + fsckUuid1 + ".txt", "/a/b/" + fsckUuid2 + ".txt", "/a/b/c/" + fsckUuid3 + ".txt"} {
+		if err := s.FileStorage.Upload(media.UploadInput{Path: media.NewPath(p), Body: bytes.NewReader([]byte("12345")), ContentLength: 5}); err != nil {
+			t.Fatal(err)
+		}
+		m := media.Media{Path: media.NewPath(p), ContentType: "text/plain", ContentLength: 5, DerivedMedias: []media.DerivedMedia{}, CreatedAt: time.Now()}
+		if err := s.MediaStorage.Save(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := operator.Create("/a/empty/"); err != nil {
+		t.Fatal(err)
+	}
+
+	listing, err := operator.List("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FolderListing{
+		FolderSummary: FolderSummary{Path: "/a", MediaCount: 3, Size: 15},
+		Folders: []FolderSummary{
+			{Path: "/a/b", MediaCount: 2, Size: 10},
+			{Path: "/a/empty"},
+		},
+	}
+	if !reflect.DeepEqual(*listing, expected) {
+		t.Errorf("unexpected listing %+v", *listing)
+	}
+	if _, err := operator.List("/unknown"); err == nil {
+		t.Errorf("listing an unknown folder should fail")
+	}
+
+	if _, err := operator.Move(MoveFolderDetails{Src: "/a", Dst: "/z"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	listing, err = operator.List("/z/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listing.MediaCount != 2 || len(listing.Folders) != 1 || listing.Folders[0].Path != "/z/b/c" {
+		t.Errorf("unexpected listing after the move %+v", *listing)
+	}
+	if _, ok := folderStorage["/z/empty"]; !ok {
+		t.Errorf("empty folder should be moved")
+	}
+	if _, err := operator.List("/a"); err == nil {
+		t.Errorf("moved folder should not exist anymore")
+	}
+
+	report, err := operator.Delete(DeleteFolderDetails{Path: "/z"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Processed != 3 || len(report.Failed) != 0 || len(folderStorage) != 0 {
+		t.Errorf("unexpected delete report %+v, folders %v", *report, folderStorage)
+	}
+	if _, err := s.FileStorage.Get(media.NewPath("/z/b/c/" + fsckUuid3 + ".txt")); err == nil {
+		t.Errorf("files should be deleted")
+	}
+}
+
+func TestFolderSettingsOperatorSave(t *testing.T) {
+	op := NewFolderSettingsOperator(memoryFolderStorer{})
+	schema := []byte(`{"type": "object"}`)
+	if _, err := op.Save("/a", schema, []byte(`{"enabled": true, "max_versions": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := op.Save("/a", nil, []byte(`{"enabled": true, "max_versions": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(settings.MetadataSchema) != string(schema) || settings.Versioning.MaxVersions != 3 {
+		t.Errorf("absent fields should be left unchanged, got %+v", settings)
+	}
+
+	settings, err = op.Save("/a", []byte("null"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.MetadataSchema != nil || settings.Versioning == nil {
+		t.Errorf("null fields should be cleared, got %+v", settings)
+	}
+
+	if _, err := op.Save("/a", nil, []byte(`{"max_versions": -1}`)); err == nil {
+		t.Errorf("should reject an invalid versioning")
+	}
+}
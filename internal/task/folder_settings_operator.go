@@ -0,0 +1,84 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+)
+
+type FolderSettingsOperator struct {
+	folderStorage folder.Storer
+}
+
+func NewFolderSettingsOperator(folderStorage folder.Storer) FolderSettingsOperator {
+	return FolderSettingsOperator{
+		folderStorage,
+	}
+}
+
+func (t *FolderSettingsOperator) GetAll() ([]folder.Settings, error) {
+	return t.folderStorage.GetAll()
+}
+
+func (t *FolderSettingsOperator) Get(path string) (*folder.Settings, error) {
+	return t.folderStorage.Get(folder.CleanPath(path))
+}
+
+// Save updates the settings of the folder with the JSON encoded fields, the
+// nil ones are left unchanged and the null ones are cleared.
+func (t *FolderSettingsOperator) Save(path string, metadataSchema, versioning json.RawMessage) (*folder.Settings, error) {
+	if metadataSchema != nil && !isJSONNull(metadataSchema) {
+		if _, err := folder.CompileSchema(metadataSchema); err != nil {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid metadata schema: %w", err)}
+		}
+	}
+	var v *folder.Versioning
+	if versioning != nil && !isJSONNull(versioning) {
+		if err := json.Unmarshal(versioning, &v); err != nil {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid versioning: %w", err)}
+		}
+		if err := v.Validate(); err != nil {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid versioning: %w", err)}
+		}
+	}
+
+	path = folder.CleanPath(path)
+	settings, err := t.folderStorage.Get(path)
+	if err != nil {
+		if e, ok := err.(*mindiaerr.Error); !ok || e.ErrCode != mindiaerr.ErrCodeFolderNotFound {
+			return nil, err
+		}
+		settings = &folder.Settings{
+			Path:      path,
+			CreatedAt: time.Now(),
+		}
+	}
+	if metadataSchema != nil {
+		settings.MetadataSchema = nil
+		if !isJSONNull(metadataSchema) {
+			settings.MetadataSchema = metadataSchema
+		}
+	}
+	if versioning != nil {
+		settings.Versioning = v
+	}
+	settings.UpdatedAt = time.Now()
+	err = t.folderStorage.Save(*settings)
+	return settings, err
+}
+
+func isJSONNull(b json.RawMessage) bool {
+	return string(bytes.TrimSpace(b)) == "null"
+}
+
+func (t *FolderSettingsOperator) Delete(path string) error {
+	path = folder.CleanPath(path)
+	if _, err := t.folderStorage.Get(path); err != nil {
+		return err
+	}
+	return t.folderStorage.Delete(path)
+}
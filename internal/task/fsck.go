@@ -0,0 +1,422 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/pkg/path"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FsckTaskName = "fsck"
+	// OrphanCleanupJobName is the recurring job deleting the orphaned files
+	// fsck finds, see OrphanCleanupOptions.
+	OrphanCleanupJobName = "orphan_cleanup"
+
+	listPageSize = 100
+)
+
+type FsckOptions struct {
+	// Path restricts the check to a folder, the root by default.
+	Path   string `json:"path,omitempty"`
+	Repair bool   `json:"repair,omitempty"`
+	// DeleteRecords lets the repair delete the records whose original is
+	// missing, they are only reported otherwise.
+	DeleteRecords bool `json:"delete_records,omitempty"`
+	// OrphansOnly restricts the repair to deleting the orphaned derived and
+	// version files, the records are left untouched.
+	OrphansOnly bool `json:"orphans_only,omitempty"`
+}
+
+// OrphanCleanupOptions are the options of the orphan cleanup job.
+var OrphanCleanupOptions = FsckOptions{Repair: true, OrphansOnly: true}
+
+// FsckReport lists the inconsistencies found between the file, cache and
+// metadata storages.
+type FsckReport struct {
+	// OriginalsWithoutMetadata are repaired by creating their record.
+	OriginalsWithoutMetadata []string `json:"originals_without_metadata"`
+	// MetadataWithoutFiles are repaired by deleting the record and its
+	// derived files, only with DeleteRecords.
+	MetadataWithoutFiles []string `json:"metadata_without_files"`
+	// OrphanedDerivedFiles are cached files no media derives, they are
+	// deleted.
+	OrphanedDerivedFiles []string `json:"orphaned_derived_files"`
+	// StaleDerivedMedias are derived medias whose file is missing, they are
+	// removed from their media.
+	StaleDerivedMedias []string `json:"stale_derived_medias"`
+	// OrphanedVersionFiles are versions no media lists, they are deleted.
+	OrphanedVersionFiles []string `json:"orphaned_version_files"`
+	Repaired             bool     `json:"repaired"`
+	// RepairFailures are the paths the repair failed on, they are reported
+	// again by the next check.
+	RepairFailures []string `json:"repair_failures,omitempty"`
+}
+
+func (r *FsckReport) IsConsistent() bool {
+	return len(r.OriginalsWithoutMetadata) == 0 && len(r.MetadataWithoutFiles) == 0 &&
+		len(r.OrphanedDerivedFiles) == 0 && len(r.StaleDerivedMedias) == 0 &&
+		len(r.OrphanedVersionFiles) == 0
+}
+
+type ConsistencyChecker struct {
+	fileStorage    media.FileStorer
+	cacheStorage   media.FileStorer
+	mediaStorage   media.Storer
+	journalStorage journal.Storer
+}
+
+// NewConsistencyChecker returns a checker skipping the medias of the
+// operations pending in journalStorage, nil when there is no journal.
+func NewConsistencyChecker(
+	fileStorage media.FileStorer,
+	cacheStorage media.FileStorer,
+	mediaStorage media.Storer,
+	journalStorage journal.Storer,
+) ConsistencyChecker {
+	return ConsistencyChecker{
+		fileStorage:    fileStorage,
+		cacheStorage:   cacheStorage,
+		mediaStorage:   mediaStorage,
+		journalStorage: journalStorage,
+	}
+}
+
+func (c *ConsistencyChecker) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	var opts FsckOptions
+	if err := t.DecodeDetails(&opts); err != nil {
+		return nil, err
+	}
+	report, err := c.Check(opts)
+	if err != nil {
+		return nil, err
+	}
+	t.ReportProgress(100, "check done", report)
+	return nil, nil
+}
+
+// Check compares the content of the storages under the folder. The medias
+// and files created or modified while it runs are left out, and the records
+// are read again before an inconsistency is reported. The medias of the
+// operations pending in the journal are left out too, the journal completes
+// or compensates them. A failing repair is reported and the others go on.
+func (c *ConsistencyChecker) Check(opts FsckOptions) (*FsckReport, error) {
+	root := opts.Path
+	if root == "" {
+		root = "/"
+	}
+	if !strings.HasPrefix(root, "/") {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: errors.New("path must be absolute")}
+	}
+	rootPath := media.NewPath(root)
+	snapshot := time.Now()
+
+	pending, err := listPending(c.journalStorage)
+	if err != nil {
+		// The inconsistencies of the pending operations are only reported.
+		if opts.Repair {
+			return nil, fmt.Errorf("unable to read the pending operations, %w", err)
+		}
+		log.Warn().Err(err).Msg("unable to read the pending operations")
+	}
+
+	medias, err := listMedias(c.mediaStorage, rootPath)
+	if err != nil {
+		return nil, err
+	}
+	originals, err := walkFiles(c.fileStorage, rootPath)
+	if err != nil {
+		return nil, err
+	}
+	cached, err := walkFiles(c.cacheStorage, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		report = FsckReport{Repaired: opts.Repair}
+		// The records are only repaired on demand, never by the orphan
+		// cleanup.
+		repairRecords = opts.Repair && !opts.OrphansOnly
+		recorded      = map[string]bool{}
+		derived       = map[string]bool{}
+		versions      = map[string]bool{}
+		// uuids of the medias by folder, the derived files are named after them.
+		uuids = map[string][]string{}
+	)
+	for _, m := range medias {
+		recorded[m.Path.ToString()] = true
+		if uuid := m.Path.Uuid(); uuid != "" {
+			uuids[m.Path.Dir()] = append(uuids[m.Path.Dir()], uuid)
+		}
+		for _, dm := range m.DerivedMedias {
+			derived[dm.Path.ToString()] = true
+		}
+		for _, v := range m.Versions {
+			versions[v.Path.ToString()] = true
+		}
+	}
+
+	for _, f := range originals {
+		p := f.Path.ToString()
+		if f.ModifiedAt.After(snapshot) || pending.has(f.Path) {
+			continue
+		}
+		if f.Path.IsVersion() {
+			if !versions[p] && !c.hasVersion(f.Path) {
+				report.OrphanedVersionFiles = append(report.OrphanedVersionFiles, p)
+			}
+			continue
+		}
+		if !recorded[p] && !c.hasRecord(f.Path) {
+			report.OriginalsWithoutMetadata = append(report.OriginalsWithoutMetadata, p)
+		}
+	}
+	for _, f := range cached {
+		p := f.Path.ToString()
+		if f.ModifiedAt.After(snapshot) || derived[p] || ownedByMedia(f.Path, uuids) || pending.has(f.Path) {
+			continue
+		}
+		// The media the file is named after may have been created meanwhile.
+		owner := media.NewPath(path.JoinPath(f.Path.Dir(), f.Path.Uuid()+f.Path.Extension()))
+		if f.Path.Uuid() != "" && c.hasRecord(owner) {
+			continue
+		}
+		report.OrphanedDerivedFiles = append(report.OrphanedDerivedFiles, p)
+	}
+
+	originalSet := toSet(originals)
+	cachedSet := toSet(cached)
+	for i := range medias {
+		m := &medias[i]
+		if m.CreatedAt.After(snapshot) || pending.has(m.Path) {
+			continue
+		}
+		if !originalSet[m.Path.ToString()] {
+			if _, err := c.fileStorage.Get(m.Path); err == nil || !c.hasRecord(m.Path) {
+				continue
+			}
+			report.MetadataWithoutFiles = append(report.MetadataWithoutFiles, m.Path.ToString())
+			if repairRecords && opts.DeleteRecords {
+				report.failed(m.Path, "delete record", c.deleteRecord(m))
+			}
+			continue
+		}
+		stale := map[string]bool{}
+		for _, dm := range m.DerivedMedias {
+			if !cachedSet[dm.Path.ToString()] {
+				report.StaleDerivedMedias = append(report.StaleDerivedMedias, dm.Path.ToString())
+				stale[dm.Path.ToString()] = true
+			}
+		}
+		if repairRecords && len(stale) > 0 {
+			report.failed(m.Path, "remove stale derived medias", removeDerivedMedias(c.mediaStorage, m.Path, stale))
+		}
+	}
+
+	if repairRecords {
+		for _, p := range report.OriginalsWithoutMetadata {
+			report.failed(media.NewPath(p), "create record", c.createRecord(media.NewPath(p)))
+		}
+	}
+	if opts.Repair {
+		for _, p := range report.OrphanedDerivedFiles {
+			report.failed(media.NewPath(p), "delete derived file", c.cacheStorage.Delete(media.NewPath(p)))
+		}
+		for _, p := range report.OrphanedVersionFiles {
+			report.failed(media.NewPath(p), "delete version file", c.fileStorage.Delete(media.NewPath(p)))
+		}
+	}
+	return &report, nil
+}
+
+// failed records the failure of the repair of p, if any.
+func (r *FsckReport) failed(p media.Path, repair string, err error) {
+	if err == nil {
+		return
+	}
+	log.Warn().Err(err).Msgf("unable to %s %s", repair, p.ToString())
+	r.RepairFailures = append(r.RepairFailures, p.ToString())
+}
+
+// pendingMedias are the paths and uuids of the medias the operations pending
+// in the journal touch.
+type pendingMedias struct {
+	paths map[string]bool
+	uuids map[string]bool
+}
+
+func (p pendingMedias) has(path media.Path) bool {
+	return p.paths[path.ToString()] || (path.Uuid() != "" && p.uuids[path.Uuid()])
+}
+
+// listPending collects the paths found in the details of the pending
+// operations, the files derived from them are named after their uuid.
+func listPending(s journal.Storer) (pendingMedias, error) {
+	pending := pendingMedias{paths: map[string]bool{}, uuids: map[string]bool{}}
+	if s == nil {
+		return pending, nil
+	}
+	ops, err := s.GetAll()
+	if err != nil {
+		return pending, err
+	}
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if strings.HasPrefix(v, "/") {
+				p := media.NewPath(v)
+				pending.paths[p.ToString()] = true
+				if p.Uuid() != "" {
+					pending.uuids[p.Uuid()] = true
+				}
+			}
+		case []interface{}:
+			for _, e := range v {
+				collect(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				collect(e)
+			}
+		}
+	}
+	for i := range ops {
+		var details interface{}
+		if err := ops[i].DecodeDetails(&details); err != nil {
+			return pending, err
+		}
+		collect(details)
+	}
+	return pending, nil
+}
+
+func (c *ConsistencyChecker) hasRecord(p media.Path) bool {
+	_, err := c.mediaStorage.Get(p)
+	return err == nil
+}
+
+// hasVersion tells whether the media the version file was kept for lists it.
+func (c *ConsistencyChecker) hasVersion(p media.Path) bool {
+	m, err := c.mediaStorage.Get(p.VersionOf())
+	if err != nil {
+		return false
+	}
+	for _, v := range m.Versions {
+		if v.Path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDerivedMedias removes the stale derived medias from the current
+// record, the ones added since the listing are kept.
+func removeDerivedMedias(s media.Storer, p media.Path, stale map[string]bool) error {
+	m, err := s.Get(p)
+	if err != nil {
+		if e, ok := err.(*mindiaerr.Error); ok && e.ErrCode == mindiaerr.ErrCodeMediaNotFound {
+			return nil
+		}
+		return err
+	}
+	fresh := []media.DerivedMedia{}
+	for _, dm := range m.DerivedMedias {
+		if !stale[dm.Path.ToString()] {
+			fresh = append(fresh, dm)
+		}
+	}
+	if len(fresh) == len(m.DerivedMedias) {
+		return nil
+	}
+	m.DerivedMedias = fresh
+	return s.Save(m)
+}
+
+// listMedias returns the medias of the folder and its sub folders, oldest
+// first.
+func listMedias(s media.Storer, root media.Path) ([]media.Media, error) {
+	medias := []media.Media{}
+	cursor := ""
+	for {
+		result, err := s.GetMultiple(media.ListQuery{
+			Path:   root,
+			Sort:   []media.SortKey{{Field: media.FieldCreatedAt, Asc: true}},
+			Cursor: cursor,
+			Limit:  listPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		medias = append(medias, result.Medias...)
+		if result.NextCursor == "" {
+			return medias, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+func walkFiles(s media.FileStorer, root media.Path) ([]media.FileInfo, error) {
+	files := []media.FileInfo{}
+	err := s.Walk(root, func(f media.FileInfo) error {
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+func toSet(files []media.FileInfo) map[string]bool {
+	set := map[string]bool{}
+	for _, f := range files {
+		set[f.Path.ToString()] = true
+	}
+	return set
+}
+
+// ownedByMedia tells whether the cached file is named after a media of its
+// folder, like the derived medias are.
+func ownedByMedia(p media.Path, uuids map[string][]string) bool {
+	for _, uuid := range uuids[p.Dir()] {
+		if strings.HasPrefix(p.Filename(), uuid) {
+			return true
+		}
+	}
+	return false
+}
+
+// createRecord creates a bare record for the original, unless one was saved
+// since the check.
+func (c *ConsistencyChecker) createRecord(p media.Path) error {
+	if c.hasRecord(p) {
+		return nil
+	}
+	info, err := c.fileStorage.Get(p)
+	if err != nil {
+		return err
+	}
+	return c.mediaStorage.Save(&media.Media{
+		Path:          p,
+		ContentType:   info.ContentType,
+		ContentLength: info.ContentLength,
+		DerivedMedias: []media.DerivedMedia{},
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	})
+}
+
+func (c *ConsistencyChecker) deleteRecord(m *media.Media) error {
+	for _, dm := range m.DerivedMedias {
+		if err := c.cacheStorage.Delete(dm.Path); err != nil {
+			log.Warn().Err(err).Msgf("unable to delete derived media %s", dm.Path.ToString())
+		}
+	}
+	return c.mediaStorage.Delete(m.Path)
+}
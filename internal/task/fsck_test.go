@@ -0,0 +1,192 @@
+package task
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+const (
+	fsckUuid1 = "0b9f7b0e-4a4e-4a86-9d52-6a0c1d3c8f01"
+	fsckUuid2 = "0b9f7b0e-4a4e-4a86-9d52-6a0c1d3c8f02"
+	fsckUuid3 = "0b9f7b0e-4a4e-4a86-9d52-6a0c1d3c8f03"
+)
+
+func TestConsistencyChecker(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	upload := func(st media.FileStorer, p string) {
+		err := st.Upload(media.UploadInput{Path: media.NewPath(p), Body: bytes.NewReader([]byte(p)), ContentLength: len(p)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	save := func(p string, derived ...string) {
+		m := media.Media{Path: media.NewPath(p), ContentType: "text/plain", DerivedMedias: []media.DerivedMedia{}, CreatedAt: time.Now().Add(-time.Hour)}
+		for _, d := range derived {
+			m.DerivedMedias = append(m.DerivedMedias, media.DerivedMedia{Path: media.NewPath(d)})
+		}
+		if err := s.MediaStorage.Save(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Consistent media with a derived file.
+	upload(s.FileStorage, "/a/"+fsckUuid1+".txt")
+	upload(cacheStorage, "/a/"+fsckUuid1+"-1.txt")
+	save("/a/"+fsckUuid1+".txt", "/a/"+fsckUuid1+"-1.txt", "/a/"+fsckUuid1+"-2.txt")
+	// Original without metadata.
+	upload(s.FileStorage, "/a/"+fsckUuid2+".txt")
+	// Metadata without original.
+	save("/b/" + fsckUuid3 + ".txt")
+	// Derived file of no media.
+	upload(cacheStorage, "/b/"+fsckUuid2+"-1.txt")
+
+	checker := NewConsistencyChecker(s.FileStorage, cacheStorage, s.MediaStorage, nil)
+	report, err := checker.Check(FsckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FsckReport{
+		OriginalsWithoutMetadata: []string{"/a/" + fsckUuid2 + ".txt"},
+		MetadataWithoutFiles:     []string{"/b/" + fsckUuid3 + ".txt"},
+		OrphanedDerivedFiles:     []string{"/b/" + fsckUuid2 + "-1.txt"},
+		StaleDerivedMedias:       []string{"/a/" + fsckUuid1 + "-2.txt"},
+	}
+	if !reflect.DeepEqual(*report, expected) {
+		t.Fatalf("unexpected report %+v", *report)
+	}
+
+	if _, err := checker.Check(OrphanCleanupOptions); err != nil {
+		t.Fatal(err)
+	}
+	report, err = checker.Check(FsckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.OrphanedDerivedFiles = nil
+	if !reflect.DeepEqual(*report, expected) {
+		t.Fatalf("orphan cleanup should only delete orphaned files, got %+v", *report)
+	}
+
+	if _, err := checker.Check(FsckOptions{Repair: true, DeleteRecords: true}); err != nil {
+		t.Fatal(err)
+	}
+	report, err = checker.Check(FsckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsConsistent() {
+		t.Errorf("storages should be consistent after the repair, got %+v", *report)
+	}
+	m, err := s.MediaStorage.Get(media.NewPath("/a/" + fsckUuid2 + ".txt"))
+	if err != nil || m == nil || m.ContentLength == 0 {
+		t.Errorf("a record should be created for the original, got %+v %v", m, err)
+	}
+}
+
+// unlistedStorer misses every media in its listings, like a listing taken
+// before the medias were uploaded.
+type unlistedStorer struct {
+	media.Storer
+}
+
+func (s unlistedStorer) GetMultiple(q media.ListQuery) (*media.ListResult, error) {
+	return &media.ListResult{Medias: []media.Media{}}, nil
+}
+
+func TestConsistencyCheckerRereadsRecords(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	p := media.NewPath("/a/" + fsckUuid1 + ".txt")
+	derived := media.NewPath("/a/" + fsckUuid1 + "-1.txt")
+	for _, f := range []struct {
+		s media.FileStorer
+		p media.Path
+	}{{s.FileStorage, p}, {cacheStorage, derived}} {
+		if err := f.s.Upload(media.UploadInput{Path: f.p, Body: bytes.NewReader([]byte("12345")), ContentLength: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := media.Media{
+		Path:          p,
+		ContentType:   "text/plain",
+		Tags:          []media.Tag{{Value: "cat"}},
+		DerivedMedias: []media.DerivedMedia{{Path: derived}},
+		CreatedAt:     time.Now(),
+	}
+	if err := s.MediaStorage.Save(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewConsistencyChecker(s.FileStorage, cacheStorage, unlistedStorer{s.MediaStorage}, nil)
+	report, err := checker.Check(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsConsistent() {
+		t.Errorf("media missed by the listing should not be reported, got %+v", *report)
+	}
+	saved, err := s.MediaStorage.Get(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Tags) != 1 || len(saved.DerivedMedias) != 1 {
+		t.Errorf("record should be left untouched, got %+v", *saved)
+	}
+	if _, err := cacheStorage.Get(derived); err != nil {
+		t.Errorf("derived file should be kept, %v", err)
+	}
+}
+
+// unsavableStorer fails to save any media.
+type unsavableStorer struct {
+	media.Storer
+}
+
+func (s unsavableStorer) Save(m *media.Media) error {
+	return errors.New("unavailable")
+}
+
+func TestConsistencyCheckerSkipsPendingOperations(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	pending := media.NewPath("/a/" + fsckUuid1 + ".txt")
+	unrecorded := media.NewPath("/a/" + fsckUuid2 + ".txt")
+	orphan := media.NewPath("/b/" + fsckUuid3 + "-1.txt")
+	for _, f := range []struct {
+		s media.FileStorer
+		p media.Path
+	}{{s.FileStorage, pending}, {s.FileStorage, unrecorded}, {cacheStorage, orphan}} {
+		if err := f.s.Upload(media.UploadInput{Path: f.p, Body: bytes.NewReader([]byte("12345")), ContentLength: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, storer := newTestJournal(t)
+	op := journal.NewOperation(uploadMediaOperation, "other", uploadMediaDetails{Path: pending.ToString()})
+	if err := storer.Save(&op); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewConsistencyChecker(s.FileStorage, cacheStorage, unsavableStorer{s.MediaStorage}, storer)
+	report, err := checker.Check(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.OriginalsWithoutMetadata, []string{unrecorded.ToString()}) {
+		t.Errorf("original of the pending upload should be left out, got %+v", *report)
+	}
+	if !reflect.DeepEqual(report.RepairFailures, []string{unrecorded.ToString()}) {
+		t.Errorf("failed repair should be reported, got %+v", *report)
+	}
+	if _, err := cacheStorage.Get(orphan); err == nil {
+		t.Errorf("orphaned derived file should be deleted despite the failed repair")
+	}
+}
@@ -1,8 +1,12 @@
 package task
 
 import (
+	"fmt"
+
 	"github.com/jeremybastin1207/mindia-core/internal/analytics"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"golang.org/x/exp/slices"
 )
 
 type GetMediaTask struct {
@@ -26,12 +30,15 @@ func (t *GetMediaTask) Get(path media.Path) (*media.Media, error) {
 	return t.mediaStorage.Get(path)
 }
 
-func (t *GetMediaTask) GetMultiple(
-	path media.Path,
-	offset int,
-	limit int,
-	sortBy string,
-	asc bool,
-) ([]media.Media, error) {
-	return t.mediaStorage.GetMultiple(path, offset, limit, sortBy, asc)
+func (t *GetMediaTask) GetMultiple(query media.ListQuery) (*media.ListResult, error) {
+	return t.mediaStorage.GetMultiple(query)
+}
+
+func (t *GetMediaTask) Search(query media.SearchQuery) (*media.SearchResult, error) {
+	for _, f := range query.Facets {
+		if !slices.Contains(media.FacetFields, f) {
+			return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("facet %s not supported", f)}
+		}
+	}
+	return t.mediaStorage.Search(query)
 }
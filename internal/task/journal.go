@@ -0,0 +1,262 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ResumeOperationsTaskName = "resume_operations"
+
+	// operationLeaseTTL is the time after which the operations of another
+	// instance are considered abandoned and resumed, their owner renews
+	// them every operationLeaseRenewal while they run.
+	operationLeaseTTL     = time.Minute
+	operationLeaseRenewal = operationLeaseTTL / 3
+)
+
+var errOperationInterrupted = errors.New("operation interrupted")
+
+// Step of an operation. A step interrupted by a crash runs again, or is
+// compensated, when the operation resumes so Do and Undo must be idempotent.
+type Step struct {
+	Name string
+	Do   func() error
+	// Undo compensates Do, nil when Do can't be compensated. Once such a
+	// step started the operation can only be completed.
+	Undo func() error
+}
+
+// Planner rebuilds the steps of an operation from its journal entry.
+type Planner func(op *journal.Operation) ([]Step, error)
+
+// Journal runs the operations touching several storages so that they are
+// either completed or compensated, even when the process crashes midway.
+type Journal struct {
+	storer   journal.Storer
+	owner    string
+	planners map[string]Planner
+	// running holds the last saved state of the operations this instance
+	// runs, the lease renewal saves them again. left holds the ones it left
+	// in the journal after a failure.
+	running  map[uuid.UUID]journal.Operation
+	left     map[uuid.UUID]bool
+	mu       sync.Mutex
+	resuming sync.Mutex
+}
+
+func NewJournal(storer journal.Storer) *Journal {
+	return &Journal{
+		storer:   storer,
+		owner:    uuid.New().String(),
+		planners: map[string]Planner{},
+		running:  map[uuid.UUID]journal.Operation{},
+		left:     map[uuid.UUID]bool{},
+	}
+}
+
+func (j *Journal) RegisterPlanner(kind string, p Planner) {
+	j.planners[kind] = p
+}
+
+// Run records the operation then runs its steps. The details are saved
+// again after each step, so steps may record in them what later steps or
+// their compensation need.
+func (j *Journal) Run(kind string, details interface{}, steps []Step) error {
+	op := journal.NewOperation(kind, j.owner, details)
+	defer j.release(op.Id)
+	if err := j.record(&op); err != nil {
+		return err
+	}
+	return j.run(&op, steps)
+}
+
+func (j *Journal) run(op *journal.Operation, steps []Step) error {
+	for op.Step < len(steps) {
+		step := steps[op.Step]
+		if step.Undo == nil && !op.Committed {
+			op.Committed = true
+			j.save(op)
+		}
+		if err := step.Do(); err != nil {
+			if op.Committed {
+				// Left in the journal to be completed on resume.
+				op.Error = err.Error()
+				j.save(op)
+				return err
+			}
+			if cerr := j.compensate(op, steps, err); cerr != nil {
+				log.Error().Err(cerr).Msgf("unable to compensate operation %s", op.Id)
+			}
+			return err
+		}
+		op.Step++
+		j.save(op)
+	}
+	return j.delete(op.Id)
+}
+
+// compensate undoes the steps done, the one running included, in reverse
+// order. When an undo fails the operation stays in the journal to be
+// compensated again on resume.
+func (j *Journal) compensate(op *journal.Operation, steps []Step, cause error) error {
+	op.Compensating = true
+	op.Error = cause.Error()
+	if op.Step >= len(steps) {
+		op.Step = len(steps) - 1
+	}
+	j.save(op)
+
+	for ; op.Step >= 0; op.Step-- {
+		undo := steps[op.Step].Undo
+		if undo == nil {
+			continue
+		}
+		if err := undo(); err != nil {
+			j.save(op)
+			return fmt.Errorf("step %s, %w", steps[op.Step].Name, err)
+		}
+	}
+	return j.delete(op.Id)
+}
+
+func (j *Journal) save(op *journal.Operation) {
+	if err := j.record(op); err != nil {
+		log.Warn().Err(err).Msgf("unable to save operation %s in the journal", op.Id)
+	}
+}
+
+// record saves op and keeps a copy of it for the lease renewal, the details
+// are copied as they are since the steps keep updating them.
+func (j *Journal) record(op *journal.Operation) error {
+	op.UpdatedAt = time.Now()
+	leased := *op
+	if op.Details != nil {
+		b, err := json.Marshal(op.Details)
+		if err != nil {
+			return err
+		}
+		leased.Details = json.RawMessage(b)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running[op.Id] = leased
+	return j.storer.Save(op)
+}
+
+func (j *Journal) delete(id uuid.UUID) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.running, id)
+	return j.storer.Delete(id)
+}
+
+// release stops the lease of an operation once it returned, when it is left
+// in the journal the next Resume takes it over.
+func (j *Journal) release(id uuid.UUID) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.running[id]; ok {
+		delete(j.running, id)
+		j.left[id] = true
+	} else {
+		delete(j.left, id)
+	}
+}
+
+// KeepAlive renews the lease of the operations this instance runs until ctx
+// is done, so that other instances don't resume them.
+func (j *Journal) KeepAlive(ctx context.Context) {
+	ticker := time.NewTicker(operationLeaseRenewal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		j.renewLeases()
+	}
+}
+
+func (j *Journal) renewLeases() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, op := range j.running {
+		op.UpdatedAt = time.Now()
+		if err := j.storer.Save(&op); err != nil {
+			log.Warn().Err(err).Msgf("unable to renew the lease of operation %s", id)
+			continue
+		}
+		j.running[id] = op
+	}
+}
+
+func (j *Journal) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	return nil, j.Resume()
+}
+
+// Resume completes the committed operations left in the journal and
+// compensates the others. It runs at startup, before new operations, then
+// as a recurring job to take over the operations of the instances gone.
+func (j *Journal) Resume() error {
+	j.resuming.Lock()
+	defer j.resuming.Unlock()
+
+	ops, err := j.storer.GetAll()
+	if err != nil {
+		return err
+	}
+	for i := range ops {
+		op := &ops[i]
+		if !j.isAbandoned(op) {
+			continue
+		}
+		planner, ok := j.planners[op.Kind]
+		if !ok {
+			log.Warn().Msgf("no planner for operation %s of kind %s", op.Id, op.Kind)
+			continue
+		}
+		steps, err := planner(op)
+		if err != nil {
+			log.Error().Err(err).Msgf("unable to plan operation %s", op.Id)
+			continue
+		}
+		op.Owner = j.owner
+
+		if op.Committed || op.Step >= len(steps) {
+			err = j.run(op, steps)
+		} else {
+			err = j.compensate(op, steps, errOperationInterrupted)
+		}
+		j.release(op.Id)
+		if err != nil {
+			log.Error().Err(err).Msgf("unable to resume operation %s of kind %s", op.Id, op.Kind)
+		} else {
+			log.Info().Msgf("operation %s of kind %s resumed", op.Id, op.Kind)
+		}
+	}
+	return nil
+}
+
+// isAbandoned returns whether op was left by this instance, or by another
+// one which stopped renewing its lease.
+func (j *Journal) isAbandoned(op *journal.Operation) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.running[op.Id]; ok {
+		return false
+	}
+	return j.left[op.Id] || time.Since(op.UpdatedAt) >= operationLeaseTTL
+}
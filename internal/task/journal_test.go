@@ -0,0 +1,177 @@
+package task
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func newTestJournal(t *testing.T) (*Journal, *filesystem.JournalStorage) {
+	storer := filesystem.NewJournalStorage(filepath.Join(t.TempDir(), "journal.db"))
+	t.Cleanup(func() { storer.Close() })
+	return NewJournal(storer), storer
+}
+
+func TestJournal(t *testing.T) {
+	j, storer := newTestJournal(t)
+	calls := []string{}
+	step := func(name string, fail *bool, undoable bool) Step {
+		s := Step{Name: name, Do: func() error {
+			calls = append(calls, "do "+name)
+			if *fail {
+				return errors.New(name + " failed")
+			}
+			return nil
+		}}
+		if undoable {
+			s.Undo = func() error {
+				calls = append(calls, "undo "+name)
+				return nil
+			}
+		}
+		return s
+	}
+	ok, fail := false, true
+
+	err := j.Run("test", nil, []Step{step("a", &ok, true), step("b", &fail, true), step("c", &ok, false)})
+	if err == nil {
+		t.Fatal("the failure of a step should be returned")
+	}
+	expected := []string{"do a", "do b", "undo b", "undo a"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+	if ops, _ := storer.GetAll(); len(ops) != 0 {
+		t.Errorf("compensated operation should be removed from the journal, got %+v", ops)
+	}
+
+	// A committed operation is left in the journal and completed on resume.
+	calls = []string{}
+	steps := []Step{step("a", &ok, true), step("b", &ok, false), step("c", &fail, true)}
+	j.RegisterPlanner("test", func(op *journal.Operation) ([]Step, error) { return steps, nil })
+	if err := j.Run("test", nil, steps); err == nil {
+		t.Fatal("the failure of a step should be returned")
+	}
+	ops, _ := storer.GetAll()
+	if len(ops) != 1 || !ops[0].Committed || ops[0].Step != 2 {
+		t.Fatalf("committed operation should be kept, got %+v", ops)
+	}
+	fail = false
+	calls = []string{}
+	if err := j.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"do c"}) {
+		t.Errorf("only the failed step should run again, got %v", calls)
+	}
+	if ops, _ := storer.GetAll(); len(ops) != 0 {
+		t.Errorf("completed operation should be removed from the journal, got %+v", ops)
+	}
+}
+
+func TestMoveMediaTask(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	j, storer := newTestJournal(t)
+	mover := NewMoveMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, event.NewBus(), j)
+
+	src := media.NewPath("/a/" + fsckUuid1 + ".txt")
+	body := []byte("content")
+	if err := s.FileStorage.Upload(media.UploadInput{Path: src, Body: bytes.NewReader(body), ContentLength: len(body)}); err != nil {
+		t.Fatal(err)
+	}
+	m := media.Media{Path: src, ContentType: "text/plain", DerivedMedias: []media.DerivedMedia{}, CreatedAt: time.Now()}
+	if err := s.MediaStorage.Save(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := mover.Move(src, media.NewPath("/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Path.ToString() != "/b/"+fsckUuid1+".txt" {
+		t.Errorf("unexpected moved path %s", moved.Path.ToString())
+	}
+	if _, err := s.MediaStorage.Get(moved.Path); err != nil {
+		t.Errorf("moved record should be saved, %v", err)
+	}
+	if _, err := s.MediaStorage.Get(src); err == nil {
+		t.Errorf("previous record should be deleted")
+	}
+
+	// A move interrupted after the original was moved is compensated once
+	// its lease expired.
+	op := journal.NewOperation(moveMediaOperation, "gone", moveMediaDetails{Media: *moved, Dir: "/c"})
+	op.Step = 1
+	op.UpdatedAt = time.Now().Add(-operationLeaseTTL)
+	if err := s.FileStorage.Move(moved.Path, media.NewPath("/c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := storer.Save(&op); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FileStorage.Get(moved.Path); err != nil {
+		t.Errorf("original should be moved back, %v", err)
+	}
+	if _, err := s.MediaStorage.Get(moved.Path); err != nil {
+		t.Errorf("record should be left untouched, %v", err)
+	}
+}
+
+func TestJournalLease(t *testing.T) {
+	j, storer := newTestJournal(t)
+	other := NewJournal(storer)
+	resumed := false
+	other.RegisterPlanner("test", func(op *journal.Operation) ([]Step, error) {
+		resumed = true
+		return nil, nil
+	})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- j.Run("test", nil, []Step{{Name: "a", Do: func() error {
+			close(started)
+			<-release
+			return nil
+		}}})
+	}()
+	<-started
+
+	ops, _ := storer.GetAll()
+	if len(ops) != 1 || ops[0].Owner != j.owner {
+		t.Fatalf("running operation should be journaled, got %+v", ops)
+	}
+	leased := ops[0].UpdatedAt
+	time.Sleep(time.Millisecond)
+	j.renewLeases()
+	ops, _ = storer.GetAll()
+	if !ops[0].UpdatedAt.After(leased) {
+		t.Errorf("lease of the running operation should be renewed")
+	}
+	if err := other.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if resumed {
+		t.Errorf("operation leased by another instance should not be resumed")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if ops, _ := storer.GetAll(); len(ops) != 0 {
+		t.Errorf("completed operation should be removed from the journal, got %+v", ops)
+	}
+}
@@ -0,0 +1,535 @@
+package task
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/apikey"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+const (
+	MigrateStorageTaskName = "migrate_storage"
+
+	migrationPageSize           = 100
+	migrationCheckpointInterval = 20
+)
+
+// Stores a migration copies.
+const (
+	FilesStore                = "files"
+	CacheStore                = "cache"
+	MediasStore               = "medias"
+	ApiKeysStore              = "apikeys"
+	NamedTransformationsStore = "named_transformations"
+)
+
+var MigrationStores = []string{FilesStore, CacheStore, MediasStore, ApiKeysStore, NamedTransformationsStore}
+
+// MigrationStorages are the storers a migration copies from or to, the nil
+// ones are skipped.
+type MigrationStorages struct {
+	FileStorage                media.FileStorer
+	CacheStorage               media.FileStorer
+	MediaStorage               media.Storer
+	ApiKeyStorage              apikey.Storer
+	NamedTransformationStorage transform.Storer
+}
+
+type MigrateOptions struct {
+	// Stores restricts the migration to some stores, all of them by default.
+	Stores []string `json:"stores,omitempty"`
+	DryRun bool     `json:"dry_run,omitempty"`
+	// SkipVerify skips reading back the copies to compare their checksums.
+	SkipVerify bool `json:"skip_verify,omitempty"`
+	// RateLimit is the maximum number of objects copied per second, zero is
+	// unlimited.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	// CheckpointFile records the progress, an interrupted migration resumes
+	// from it.
+	CheckpointFile string `json:"checkpoint_file,omitempty"`
+	// CatchUp copies again the medias updated since the last pass, and the
+	// files missing from the target or modified since they were copied, even
+	// when their store is done, e.g. once the writes are stopped before
+	// switching to the target.
+	CatchUp bool `json:"catch_up,omitempty"`
+}
+
+// StoreProgress is the progress of the migration of a store. Last is the
+// last path copied, the medias are copied by creation time and LastCreatedAt
+// is the one of the last media, in Unix seconds.
+type StoreProgress struct {
+	Last          string `json:"last,omitempty"`
+	LastCreatedAt int64  `json:"last_created_at,omitempty"`
+	// Since is when the last pass over the medias started, the ones updated
+	// after it are copied again by a catch-up pass.
+	Since    int64 `json:"since,omitempty"`
+	Scanned  bool  `json:"scanned,omitempty"`
+	Done     bool  `json:"done"`
+	Copied   int   `json:"copied"`
+	Recopied int   `json:"recopied,omitempty"`
+	Bytes    int64 `json:"bytes,omitempty"`
+}
+
+type MigrationReport struct {
+	DryRun bool                      `json:"dry_run"`
+	Stores map[string]*StoreProgress `json:"stores"`
+}
+
+type StorageMigrator struct {
+	source MigrationStorages
+	target MigrationStorages
+}
+
+func NewStorageMigrator(source, target MigrationStorages) StorageMigrator {
+	return StorageMigrator{
+		source: source,
+		target: target,
+	}
+}
+
+// Execute runs the migration as a scheduler task, its progress is reported
+// with the task.
+func (m *StorageMigrator) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	var opts MigrateOptions
+	if err := t.DecodeDetails(&opts); err != nil {
+		return nil, err
+	}
+	_, err := m.Migrate(opts, func(report *MigrationReport, percent int, message string) {
+		t.ReportProgress(percent, message, report)
+	})
+	return nil, err
+}
+
+// Migrate copies the stores to the target. progress is called after every
+// object with the percentage of the stores done.
+func (m *StorageMigrator) Migrate(opts MigrateOptions, progress func(report *MigrationReport, percent int, message string)) (*MigrationReport, error) {
+	stores := opts.Stores
+	if len(stores) == 0 {
+		stores = MigrationStores
+	}
+	report, err := loadMigrationReport(opts.CheckpointFile)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = opts.DryRun
+
+	run := migrationRun{
+		opts:     opts,
+		report:   report,
+		progress: func(string) {},
+	}
+	if opts.RateLimit > 0 {
+		run.interval = time.Duration(float64(time.Second) / opts.RateLimit)
+	}
+
+	for i, store := range stores {
+		p, ok := report.Stores[store]
+		if !ok {
+			p = &StoreProgress{}
+			report.Stores[store] = p
+		}
+		if p.Done && !(opts.CatchUp && (store == MediasStore || store == FilesStore || store == CacheStore)) {
+			continue
+		}
+		if progress != nil {
+			percent := i * 100 / len(stores)
+			run.progress = func(message string) { progress(report, percent, message) }
+		}
+
+		switch store {
+		case FilesStore:
+			err = run.files(m.source.FileStorage, m.target.FileStorage, p)
+		case CacheStore:
+			err = run.files(m.source.CacheStorage, m.target.CacheStorage, p)
+		case MediasStore:
+			err = run.medias(m.source.MediaStorage, m.target.MediaStorage, p)
+		case ApiKeysStore:
+			err = run.apikeys(m.source.ApiKeyStorage, m.target.ApiKeyStorage, p)
+		case NamedTransformationsStore:
+			err = run.namedTransformations(m.source.NamedTransformationStorage, m.target.NamedTransformationStorage, p)
+		default:
+			err = fmt.Errorf("unknown store %s", store)
+		}
+		if err != nil {
+			run.checkpoint()
+			return report, fmt.Errorf("%s: %w", store, err)
+		}
+		p.Done = true
+		if err := run.checkpoint(); err != nil {
+			return report, err
+		}
+	}
+	if progress != nil {
+		progress(report, 100, "migration done")
+	}
+	return report, nil
+}
+
+func loadMigrationReport(checkpointFile string) (*MigrationReport, error) {
+	report := MigrationReport{Stores: map[string]*StoreProgress{}}
+	if checkpointFile == "" {
+		return &report, nil
+	}
+	b, err := os.ReadFile(checkpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return &report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file, %w", err)
+	}
+	if report.Stores == nil {
+		report.Stores = map[string]*StoreProgress{}
+	}
+	return &report, nil
+}
+
+type migrationRun struct {
+	opts     MigrateOptions
+	report   *MigrationReport
+	progress func(message string)
+	interval time.Duration
+	last     time.Time
+	pending  int
+}
+
+// step throttles the migration and saves the checkpoint every
+// migrationCheckpointInterval objects.
+func (r *migrationRun) step(message string) error {
+	r.progress(message)
+	if r.interval > 0 {
+		if wait := r.interval - time.Since(r.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		r.last = time.Now()
+	}
+	r.pending++
+	if r.pending < migrationCheckpointInterval {
+		return nil
+	}
+	return r.checkpoint()
+}
+
+func (r *migrationRun) checkpoint() error {
+	r.pending = 0
+	if r.opts.DryRun || r.opts.CheckpointFile == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(r.report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.opts.CheckpointFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.opts.CheckpointFile)
+}
+
+func (r *migrationRun) files(source, target media.FileStorer, p *StoreProgress) error {
+	if source == nil || target == nil {
+		return errors.New("source and target storages must be configured")
+	}
+	if !p.Scanned {
+		err := source.Walk(media.NewPath("/"), func(f media.FileInfo) error {
+			path := f.Path.ToString()
+			// Walk is in lexical order, the paths up to the last one are copied.
+			if p.Last != "" && path <= p.Last {
+				return nil
+			}
+			if err := r.copyFileInfo(source, target, f, p); err != nil {
+				return err
+			}
+			p.Last = path
+			p.Copied++
+			return r.step(path)
+		})
+		if err != nil {
+			return err
+		}
+		p.Scanned = true
+	}
+	if r.opts.CatchUp {
+		return r.catchUpFiles(source, target, p)
+	}
+	return nil
+}
+
+// catchUpFiles copies again the files missing from the target, or modified
+// in the source after their copy was written.
+func (r *migrationRun) catchUpFiles(source, target media.FileStorer, p *StoreProgress) error {
+	copiedAt := map[string]time.Time{}
+	err := target.Walk(media.NewPath("/"), func(f media.FileInfo) error {
+		copiedAt[f.Path.ToString()] = f.ModifiedAt
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return source.Walk(media.NewPath("/"), func(f media.FileInfo) error {
+		path := f.Path.ToString()
+		if at, ok := copiedAt[path]; ok && !f.ModifiedAt.After(at) {
+			return nil
+		}
+		if err := r.copyFileInfo(source, target, f, p); err != nil {
+			return err
+		}
+		p.Recopied++
+		return r.step(path)
+	})
+}
+
+func (r *migrationRun) copyFileInfo(source, target media.FileStorer, f media.FileInfo, p *StoreProgress) error {
+	if r.opts.DryRun {
+		p.Bytes += int64(f.ContentLength)
+		return nil
+	}
+	size, err := r.copyFile(source, target, f.Path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Path.ToString(), err)
+	}
+	p.Bytes += size
+	return nil
+}
+
+func (r *migrationRun) copyFile(source, target media.FileStorer, path media.Path) (int64, error) {
+	res, err := source.Download(path)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	hash := sha256.New()
+	err = target.Upload(media.UploadInput{
+		Path:          path,
+		Body:          io.TeeReader(res.Body, hash),
+		ContentType:   res.ContentType,
+		ContentLength: int(res.ContentLength),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if r.opts.SkipVerify {
+		return res.ContentLength, nil
+	}
+
+	copied, err := target.Download(path)
+	if err != nil {
+		return 0, err
+	}
+	defer copied.Body.Close()
+	copiedHash := sha256.New()
+	if _, err := io.Copy(copiedHash, copied.Body); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(hash.Sum(nil), copiedHash.Sum(nil)) {
+		return 0, errors.New("checksum mismatch")
+	}
+	return res.ContentLength, nil
+}
+
+// medias copies the medias by creation time, the ones created meanwhile come
+// last. A catch-up pass then copies again the ones updated since the copy
+// started.
+func (r *migrationRun) medias(source, target media.Storer, p *StoreProgress) error {
+	if source == nil || target == nil {
+		return errors.New("source and target storages must be configured")
+	}
+	if !p.Scanned {
+		if p.Since == 0 {
+			p.Since = time.Now().Unix()
+		}
+		var cursor *media.Cursor
+		if p.Last != "" {
+			cursor = &media.Cursor{CreatedAt: p.LastCreatedAt, Path: p.Last}
+		}
+		err := r.eachMedia(func(c string) (*media.ListResult, error) {
+			return source.GetMultiple(media.ListQuery{
+				Path:   media.NewPath("/"),
+				Sort:   []media.SortKey{{Field: media.FieldCreatedAt, Asc: true}},
+				Cursor: c,
+				Limit:  migrationPageSize,
+			})
+		}, cursor, func(m *media.Media) error {
+			if err := r.copyMedia(target, m); err != nil {
+				return err
+			}
+			p.Last = m.Path.ToString()
+			p.LastCreatedAt = m.CreatedAt.Unix()
+			p.Copied++
+			return r.step(p.Last)
+		})
+		if err != nil {
+			return err
+		}
+		p.Scanned = true
+	}
+	return r.catchUp(source, target, p)
+}
+
+// catchUp copies again the medias updated since the last pass started. The
+// update times are in seconds, the medias updated during that second are
+// copied again as well.
+func (r *migrationRun) catchUp(source, target media.Storer, p *StoreProgress) error {
+	started := time.Now().Unix()
+	err := r.eachMedia(func(c string) (*media.ListResult, error) {
+		result, err := source.Search(media.SearchQuery{
+			Path:      media.NewPath("/"),
+			Condition: media.Comparison{Field: media.FieldUpdatedAt, Op: media.OpGte, Value: time.Unix(p.Since, 0)},
+			Sort:      []media.SortKey{{Field: media.FieldUpdatedAt, Asc: true}},
+			Cursor:    c,
+			Limit:     migrationPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &media.ListResult{Medias: result.Medias, NextCursor: result.NextCursor}, nil
+	}, nil, func(m *media.Media) error {
+		if err := r.copyMedia(target, m); err != nil {
+			return err
+		}
+		p.Recopied++
+		return r.step(m.Path.ToString())
+	})
+	if err != nil {
+		return err
+	}
+	p.Since = started
+	return nil
+}
+
+// eachMedia calls fn with the medias of the pages listed after the cursor.
+func (r *migrationRun) eachMedia(list func(cursor string) (*media.ListResult, error), cursor *media.Cursor, fn func(m *media.Media) error) error {
+	c := ""
+	if cursor != nil {
+		c = cursor.Encode()
+	}
+	for {
+		result, err := list(c)
+		if err != nil {
+			return err
+		}
+		for i := range result.Medias {
+			if err := fn(&result.Medias[i]); err != nil {
+				return err
+			}
+		}
+		if result.NextCursor == "" {
+			return nil
+		}
+		c = result.NextCursor
+	}
+}
+
+func (r *migrationRun) copyMedia(target media.Storer, m *media.Media) error {
+	if r.opts.DryRun {
+		return nil
+	}
+	if err := copyMedia(target, m, r.opts.SkipVerify); err != nil {
+		return fmt.Errorf("%s: %w", m.Path.ToString(), err)
+	}
+	return nil
+}
+
+func copyMedia(target media.Storer, m *media.Media, skipVerify bool) error {
+	if err := target.Save(m); err != nil {
+		return err
+	}
+	if skipVerify {
+		return nil
+	}
+	copied, err := target.Get(m.Path)
+	if err != nil {
+		return err
+	}
+	return compareRecords(m, copied)
+}
+
+// compareRecords compares the checksums of the JSON encodings of the records.
+func compareRecords(a, b interface{}) error {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(aJSON) != sha256.Sum256(bJSON) {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
+
+func (r *migrationRun) apikeys(source, target apikey.Storer, p *StoreProgress) error {
+	if source == nil || target == nil {
+		return errors.New("source and target storages must be configured")
+	}
+	apikeys, err := source.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, a := range apikeys {
+		if !r.opts.DryRun {
+			if err := target.Save(a); err != nil {
+				return err
+			}
+			if !r.opts.SkipVerify {
+				copied, err := target.GetByKey(a.Key)
+				if err != nil {
+					return err
+				}
+				if err := compareRecords(a, copied); err != nil {
+					return fmt.Errorf("%s: %w", a.Name, err)
+				}
+			}
+		}
+		p.Copied++
+		if err := r.step(a.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *migrationRun) namedTransformations(source, target transform.Storer, p *StoreProgress) error {
+	if source == nil || target == nil {
+		return errors.New("source and target storages must be configured")
+	}
+	namedTransformations, err := source.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, t := range namedTransformations {
+		if !r.opts.DryRun {
+			if err := target.Save(t); err != nil {
+				return err
+			}
+			if !r.opts.SkipVerify {
+				copied, err := target.Get(t.Name)
+				if err != nil {
+					return err
+				}
+				if err := compareRecords(t, copied); err != nil {
+					return fmt.Errorf("%s: %w", t.Name, err)
+				}
+			}
+		}
+		p.Copied++
+		if err := r.step(t.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package task
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/sqlite"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func newMigrationStorages(t *testing.T) MigrationStorages {
+	dir := t.TempDir()
+	mediaStorage, err := sqlite.NewMediaStorage(filepath.Join(dir, "mindia.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mediaStorage.Close() })
+	return MigrationStorages{
+		FileStorage:  filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(dir, "files")}),
+		MediaStorage: mediaStorage,
+	}
+}
+
+func TestStorageMigrator(t *testing.T) {
+	source := newMigrationStorages(t)
+	target := newMigrationStorages(t)
+
+	paths := []string{"/a.txt", "/a/b.txt", "/b/c/d.txt"}
+	for _, p := range paths {
+		body := []byte("content of " + p)
+		err := source.FileStorage.Upload(media.UploadInput{Path: media.NewPath(p), Body: bytes.NewReader(body), ContentLength: len(body)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = source.MediaStorage.Save(&media.Media{Path: media.NewPath(p), ContentType: "text/plain", ContentLength: len(body), CreatedAt: time.Now().Add(-time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrator := NewStorageMigrator(source, target)
+	opts := MigrateOptions{
+		Stores:         []string{FilesStore, MediasStore},
+		CheckpointFile: filepath.Join(t.TempDir(), "checkpoint.json"),
+	}
+
+	opts.DryRun = true
+	report, err := migrator.Migrate(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stores[FilesStore].Copied != 3 || report.Stores[MediasStore].Copied != 3 {
+		t.Errorf("unexpected dry run report %+v %+v", report.Stores[FilesStore], report.Stores[MediasStore])
+	}
+	if _, err := target.FileStorage.Download(media.NewPath("/a.txt")); err == nil {
+		t.Errorf("dry run should not copy files")
+	}
+
+	// An interrupted migration resumes after the last file copied.
+	checkpoint := `{"stores": {"files": {"last": "/a.txt", "copied": 1}}}`
+	if err := os.WriteFile(opts.CheckpointFile, []byte(checkpoint), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.DryRun = false
+	opts.Stores = []string{FilesStore, MediasStore}
+	report, err = migrator.Migrate(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Stores[FilesStore].Done || report.Stores[MediasStore].Copied != 3 {
+		t.Errorf("unexpected report %+v %+v", report.Stores[FilesStore], report.Stores[MediasStore])
+	}
+
+	if _, err := target.FileStorage.Download(media.NewPath("/a.txt")); err == nil {
+		t.Errorf("files before the checkpoint should not be copied again")
+	}
+	for _, p := range paths[1:] {
+		res, err := target.FileStorage.Download(media.NewPath(p))
+		if err != nil {
+			t.Fatalf("%s not copied, %v", p, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != "content of "+p {
+			t.Errorf("%s: got %q", p, body)
+		}
+	}
+	for _, p := range paths {
+		if _, err := target.MediaStorage.Get(media.NewPath(p)); err != nil {
+			t.Errorf("%s metadata not copied, %v", p, err)
+		}
+	}
+
+	// A catch-up pass copies again the medias updated since the migration.
+	updated := media.Media{Path: media.NewPath("/a.txt"), ContentType: "text/csv", CreatedAt: time.Now().Add(-time.Hour), UpdatedAt: time.Now().Add(time.Minute)}
+	if err := source.MediaStorage.Save(&updated); err != nil {
+		t.Fatal(err)
+	}
+	opts.Stores = []string{MediasStore}
+	opts.CatchUp = true
+	report, err = migrator.Migrate(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stores[MediasStore].Recopied != 1 {
+		t.Errorf("unexpected catch-up report %+v", report.Stores[MediasStore])
+	}
+	if m, err := target.MediaStorage.Get(updated.Path); err != nil || m.ContentType != "text/csv" {
+		t.Errorf("updated media not copied again, got %+v, %v", m, err)
+	}
+
+	// It copies the files missing from the target or modified since.
+	modified := media.NewPath("/b/c/d.txt")
+	if err := source.FileStorage.Upload(media.UploadInput{Path: modified, Body: bytes.NewReader([]byte("modified")), ContentLength: 8}); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	mountDir := source.FileStorage.(*filesystem.FileStorage).MountDir
+	if err := os.Chtimes(filepath.Join(mountDir, modified.ToString()), later, later); err != nil {
+		t.Fatal(err)
+	}
+	opts.Stores = []string{FilesStore}
+	report, err = migrator.Migrate(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stores[FilesStore].Recopied != 2 {
+		t.Errorf("unexpected files catch-up report %+v", report.Stores[FilesStore])
+	}
+	for p, want := range map[string]string{"/a.txt": "content of /a.txt", "/b/c/d.txt": "modified"} {
+		res, err := target.FileStorage.Download(media.NewPath(p))
+		if err != nil {
+			t.Fatalf("%s not copied, %v", p, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != want {
+			t.Errorf("%s: got %q, wanted %q", p, body, want)
+		}
+	}
+}
+
+func TestFileStorageWalkOrder(t *testing.T) {
+	s := newMigrationStorages(t).FileStorage
+	for _, p := range []string{"/a/b.txt", "/a.txt", "/a-b.txt", "/c.txt"} {
+		if err := s.Upload(media.UploadInput{Path: media.NewPath(p), Body: bytes.NewReader(nil)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := []string{}
+	err := s.Walk(media.NewPath("/"), func(f media.FileInfo) error {
+		got = append(got, f.Path.ToString())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/a-b.txt", "/a.txt", "/a/b.txt", "/c.txt"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("got %v, wanted %v", got, want)
+		}
+	}
+}
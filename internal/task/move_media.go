@@ -1,25 +1,48 @@
 package task
 
 import (
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/rs/zerolog/log"
 )
 
+const moveMediaOperation = "move_media"
+
+type moveMediaDetails struct {
+	Media media.Media `json:"media"`
+	Dir   string      `json:"dir"`
+}
+
+// MediaWithOrigin is the data of the events of a media moved or copied.
+type MediaWithOrigin struct {
+	From  media.Path   `json:"from"`
+	Media *media.Media `json:"media"`
+}
+
 type MoveMediaTask struct {
 	fileStorage  media.FileStorer
 	cacheStorage media.FileStorer
 	mediaStorage media.Storer
+	eventBus     *event.Bus
+	journal      *Journal
 }
 
 func NewMoveMediaTask(
 	fileStorage media.FileStorer,
 	cacheStorage media.FileStorer, mediaStorage media.Storer,
+	eventBus *event.Bus,
+	journal *Journal,
 ) MoveMediaTask {
-	return MoveMediaTask{
+	t := MoveMediaTask{
 		fileStorage,
 		cacheStorage,
 		mediaStorage,
+		eventBus,
+		journal,
 	}
+	journal.RegisterPlanner(moveMediaOperation, t.plan)
+	return t
 }
 
 func (t *MoveMediaTask) Move(src media.Path, dst media.Path) (*media.Media, error) {
@@ -27,33 +50,92 @@ func (t *MoveMediaTask) Move(src media.Path, dst media.Path) (*media.Media, erro
 	if err != nil {
 		return nil, err
 	}
-	err = t.fileStorage.Move(src, dst)
-	if err != nil {
-		return nil, err
-	}
 	oldPath := m.Path
-	m.Path = m.Path.WithDir(dst.ToString())
-
-	movedAssets := []media.DerivedMedia{}
-	for _, asset := range m.DerivedMedias {
-		dst := asset.Path.WithDir(dst.ToString())
-		err = t.cacheStorage.Move(asset.Path, dst)
-		if err != nil {
-			log.Warn().Err(err)
-			continue
-		}
-		asset.Path = dst
-		movedAssets = append(movedAssets, asset)
-	}
-	m.DerivedMedias = movedAssets
-
-	err = t.mediaStorage.Delete(oldPath)
-	if err != nil {
+	if m.Path.WithDir(dst.ToString()) == oldPath {
+		return m, nil
+	}
+
+	details := moveMediaDetails{Media: *m, Dir: dst.ToString()}
+	if err := t.journal.Run(moveMediaOperation, details, t.steps(details)); err != nil {
 		return nil, err
 	}
-	err = t.mediaStorage.Save(m)
-	if err != nil {
+	moved := movedMedia(details)
+	t.eventBus.Publish(event.MediaMoved, MediaWithOrigin{oldPath, &moved})
+	return &moved, nil
+}
+
+func (t *MoveMediaTask) plan(op *journal.Operation) ([]Step, error) {
+	var details moveMediaDetails
+	if err := op.DecodeDetails(&details); err != nil {
 		return nil, err
 	}
-	return m, nil
+	return t.steps(details), nil
+}
+
+// steps saves the moved record before deleting the previous one, so that
+// the media is never missing from the metadata storage.
+func (t *MoveMediaTask) steps(details moveMediaDetails) []Step {
+	var (
+		m     = details.Media
+		moved = movedMedia(details)
+		dir   = media.NewPath(details.Dir)
+	)
+	return []Step{
+		{
+			Name: "move original",
+			Do:   func() error { return moveFile(t.fileStorage, m.Path, dir) },
+			Undo: func() error { return moveFile(t.fileStorage, moved.Path, media.NewPath(m.Path.Dir())) },
+		},
+		{
+			Name: "move derived medias",
+			Do: func() error {
+				// Derived medias can be generated again, a missing one doesn't
+				// prevent the move.
+				for _, dm := range m.DerivedMedias {
+					if err := moveFile(t.cacheStorage, dm.Path, dir); err != nil {
+						log.Warn().Err(err).Msgf("unable to move derived media %s", dm.Path.ToString())
+					}
+				}
+				return nil
+			},
+			Undo: func() error {
+				for _, dm := range moved.DerivedMedias {
+					if err := moveFile(t.cacheStorage, dm.Path, media.NewPath(m.Path.Dir())); err != nil {
+						log.Warn().Err(err).Msgf("unable to move back derived media %s", dm.Path.ToString())
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name: "save moved record",
+			Do:   func() error { return t.mediaStorage.Save(&moved) },
+			Undo: func() error { return t.mediaStorage.Delete(moved.Path) },
+		},
+		{
+			Name: "delete previous record",
+			Do:   func() error { return t.mediaStorage.Delete(m.Path) },
+		},
+	}
+}
+
+func movedMedia(details moveMediaDetails) media.Media {
+	m := details.Media
+	m.Path = m.Path.WithDir(details.Dir)
+	m.DerivedMedias = make([]media.DerivedMedia, len(details.Media.DerivedMedias))
+	for i, dm := range details.Media.DerivedMedias {
+		dm.Path = dm.Path.WithDir(details.Dir)
+		m.DerivedMedias[i] = dm
+	}
+	return m
+}
+
+// moveFile moves the file p to the folder dir. It does nothing when the file
+// was already moved, and fails when it is found at neither place.
+func moveFile(s media.FileStorer, p media.Path, dir media.Path) error {
+	if _, err := s.Get(p); err == nil {
+		return s.Move(p, dir)
+	}
+	_, err := s.Get(p.WithDir(dir.ToString()))
+	return err
 }
@@ -1,6 +1,10 @@
 package task
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/transform"
 )
@@ -8,12 +12,10 @@ import (
 type TagMediaTask struct {
 	fileStorage  media.FileStorer
 	mediaStorage media.Storer
-	tagger       transform.GoogleTagger
+	tagger       transform.Tagger
 }
 
-func NewTagMediaTask(fileStorage media.FileStorer, mediaStorage media.Storer) TagMediaTask {
-	tagger := transform.NewGoogleTagger()
-
+func NewTagMediaTask(fileStorage media.FileStorer, mediaStorage media.Storer, tagger transform.Tagger) TagMediaTask {
 	return TagMediaTask{
 		fileStorage,
 		mediaStorage,
@@ -21,43 +23,88 @@ func NewTagMediaTask(fileStorage media.FileStorer, mediaStorage media.Storer) Ta
 	}
 }
 
+// Tag replaces the tags previously set by the tagger provider, the tags of the
+// other providers are kept.
 func (t *TagMediaTask) Tag(path media.Path) (*media.Media, error) {
-	/* 	media, err := t.mediaStorage.Get(path)
-	   	if err != nil {
-	   		return nil, err
-	   	}
-
-	   	downloadResult, err := t.fileStorage.Download(path)
-	   	if err != nil {
-	   		return nil, err
-	   	} */
-
-	/* 	source := pipeline.NewSource(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-	   		ctx.Path = path
-	   		ctx.Buffer = pipeline.NewBuffer(downloadResult.Body)
-	   		return ctx, nil
-	   	})
-
-	   	sinker := pipeline.NewSinker(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-	   		media.Tags = ctx.Tags
-	   		err := t.mediaStorage.Save(media)
-	   		return ctx, err
-	   	}) */
-
-	/* 	p := pipeline.NewPipeline(&source,
-	   		&sinker,
-	   		[]pipeline.PipelineStep{&t.tagger},
-	   	)
-	   	_, err = p.Execute()
-	   	if err != nil {
-	   		return nil, err
-	   	}
-
-	   	m, err := t.mediaStorage.Get(path)
-	   	if err != nil {
-	   		return nil, err
-	   	} */
-
-	//return m, nil
-	return nil, nil
+	m, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadResult, err := t.fileStorage.Download(path)
+	if err != nil {
+		return nil, err
+	}
+	defer downloadResult.Body.Close()
+
+	body, err := io.ReadAll(downloadResult.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := t.tagger.Tag(context.Background(), body, m.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Tags = transform.MergeTags(m.Tags, t.tagger.Provider(), tags)
+	m.UpdatedAt = time.Now()
+	err = t.mediaStorage.Save(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AddUserTags adds tags with the user provider to the media.
+func (t *TagMediaTask) AddUserTags(path media.Path, values []string) (*media.Media, error) {
+	m, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []media.Tag{}
+	for _, tag := range m.Tags {
+		if tag.Provider == media.UserTagProvider {
+			tags = append(tags, tag)
+		}
+	}
+	for _, v := range values {
+		tags = append(tags, media.Tag{
+			Value:           v,
+			ConfidenceScore: 1,
+		})
+	}
+	return t.saveUserTags(m, tags)
+}
+
+// RemoveUserTags removes the tags with the user provider from the media, the
+// tags of the other providers are kept.
+func (t *TagMediaTask) RemoveUserTags(path media.Path, values []string) (*media.Media, error) {
+	m, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := map[string]bool{}
+	for _, v := range values {
+		removed[media.NormalizeTagValue(v)] = true
+	}
+	tags := []media.Tag{}
+	for _, tag := range m.Tags {
+		if tag.Provider == media.UserTagProvider && !removed[tag.Value] {
+			tags = append(tags, tag)
+		}
+	}
+	return t.saveUserTags(m, tags)
+}
+
+func (t *TagMediaTask) saveUserTags(m *media.Media, tags []media.Tag) (*media.Media, error) {
+	m.Tags = transform.MergeTags(m.Tags, media.UserTagProvider, tags)
+	m.UpdatedAt = time.Now()
+	err := t.mediaStorage.Save(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
@@ -1,6 +1,15 @@
 package task
 
-import "github.com/jeremybastin1207/mindia-core/internal/scheduler"
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+)
+
+const taskWatchInterval = 500 * time.Millisecond
 
 type TaskOperator struct {
 	storer scheduler.Storer
@@ -11,3 +20,57 @@ func NewTaskOperator(taskStorage scheduler.Storer) TaskOperator {
 		storer: taskStorage,
 	}
 }
+
+func (o *TaskOperator) Get(id uuid.UUID) (*scheduler.Task, error) {
+	return o.storer.GetTask(id)
+}
+
+// Watch emits the task every time its status or progress changes. The channel
+// is closed once the task reached a terminal status or ctx is done.
+func (o *TaskOperator) Watch(ctx context.Context, id uuid.UUID) (<-chan scheduler.Task, error) {
+	t, err := o.storer.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan scheduler.Task)
+
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(taskWatchInterval)
+		defer ticker.Stop()
+
+		var last []byte
+		for {
+			current, err := json.Marshal(t)
+			if err != nil {
+				return
+			}
+			if string(current) != string(last) {
+				select {
+				case updates <- *t:
+				case <-ctx.Done():
+					return
+				}
+				last = current
+			}
+			if t.Status.IsTerminal() {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			t, err = o.storer.GetTask(id)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
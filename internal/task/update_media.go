@@ -0,0 +1,52 @@
+package task
+
+import (
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+type UpdateMediaTask struct {
+	mediaStorage      media.Storer
+	metadataValidator folder.MetadataValidator
+}
+
+func NewUpdateMediaTask(mediaStorage media.Storer, folderStorage folder.Storer) UpdateMediaTask {
+	return UpdateMediaTask{
+		mediaStorage,
+		folder.NewMetadataValidator(folderStorage),
+	}
+}
+
+// UpdateCustomMetadata merges the patch into the custom metadata of the media,
+// the keys set to null are removed.
+func (t *UpdateMediaTask) UpdateCustomMetadata(path media.Path, patch media.CustomMetadata) (*media.Media, error) {
+	m, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := media.CustomMetadata{}
+	for k, v := range m.CustomMetadata {
+		metadata[k] = v
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(metadata, k)
+		} else {
+			metadata[k] = v
+		}
+	}
+	if err := t.metadataValidator.Validate(path, metadata); err != nil {
+		return nil, err
+	}
+
+	m.CustomMetadata = metadata
+	m.UpdatedAt = time.Now()
+	err = t.mediaStorage.Save(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -1,16 +1,33 @@
 package task
 
 import (
+	"errors"
 	"io"
+	"io/fs"
 	"time"
 
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/parser"
 	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/jeremybastin1207/mindia-core/internal/plugin"
 	"github.com/jeremybastin1207/mindia-core/internal/transform"
 )
 
+const uploadMediaOperation = "upload_media"
+
+var errUploadBodyUnavailable = errors.New("upload body is not available anymore")
+
+type uploadMediaDetails struct {
+	Path string `json:"path"`
+	// MediaPath is the path of the original once uploaded, the optimizations
+	// can change its extension.
+	MediaPath string `json:"media_path,omitempty"`
+}
+
 type UploadMediaTask struct {
 	fileStorage               media.FileStorer
 	cacheStorage              media.FileStorer
@@ -18,7 +35,11 @@ type UploadMediaTask struct {
 	mediaOptimization         transform.MediaOptimization
 	namedTransformationParser parser.NamedTransformationParser
 	transformationParser      parser.Parser
-	transformationsBuilder    transform.Builder
+	transformationsBuilder    *transform.Builder
+	pluginManager             *plugin.PluginManager
+	eventBus                  *event.Bus
+	metadataValidator         folder.MetadataValidator
+	journal                   *Journal
 }
 
 func NewUploadMediaTask(
@@ -26,16 +47,27 @@ func NewUploadMediaTask(
 	cacheStorage media.FileStorer,
 	mediaStorage media.Storer,
 	namedTransformationStorage transform.Storer,
+	transformationsBuilder *transform.Builder,
+	pluginManager *plugin.PluginManager,
+	eventBus *event.Bus,
+	folderStorage folder.Storer,
+	journal *Journal,
 ) UploadMediaTask {
-	return UploadMediaTask{
+	t := UploadMediaTask{
 		cacheStorage:              cacheStorage,
 		fileStorage:               fileStorage,
 		mediaStorage:              mediaStorage,
 		mediaOptimization:         *transform.NewMediaOptimization(),
 		namedTransformationParser: parser.NewNamedTransformationParser(namedTransformationStorage),
 		transformationParser:      parser.NewParser(),
-		transformationsBuilder:    transform.NewBuilder(fileStorage),
+		transformationsBuilder:    transformationsBuilder,
+		pluginManager:             pluginManager,
+		eventBus:                  eventBus,
+		metadataValidator:         folder.NewMetadataValidator(folderStorage),
+		journal:                   journal,
 	}
+	journal.RegisterPlanner(uploadMediaOperation, t.plan)
+	return t
 }
 
 func (t *UploadMediaTask) Upload(
@@ -44,14 +76,84 @@ func (t *UploadMediaTask) Upload(
 	contentType string,
 	contentLength int64,
 	transformations []string,
+	customMetadata media.CustomMetadata,
+	expiresAt *time.Time,
 ) (*media.Media, error) {
 	if !media.IsContentTypeSupported(contentType) {
 		return nil, mindiaerr.New(mindiaerr.ErrCodeMimeTypeNotSupported)
 	}
+	if err := t.metadataValidator.Validate(media.NewPath(path), customMetadata); err != nil {
+		return nil, err
+	}
+
+	result, err := t.optimize(media.NewPath(path), body, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		m       media.Media
+		details = &uploadMediaDetails{Path: path}
+	)
+	err = t.journal.Run(uploadMediaOperation, details, []Step{
+		{
+			Name: "upload original",
+			Do: func() error {
+				if err := t.uploadOriginal(result); err != nil {
+					return err
+				}
+				m = media.Media{
+					Path:             result.Path,
+					ContentType:      result.ContentType,
+					ContentLength:    result.Buffer.Len(),
+					EmbeddedMetadata: result.EmbeddedMetadata,
+					CustomMetadata:   customMetadata,
+					DerivedMedias:    []media.DerivedMedia{},
+					CreatedAt:        time.Now(),
+					UpdatedAt:        time.Now(),
+					ExpiresAt:        expiresAt,
+				}
+				details.MediaPath = m.Path.ToString()
+				return nil
+			},
+			Undo: func() error { return deleteUploaded(t.fileStorage, details) },
+		},
+		{
+			Name: "upload derived medias",
+			Do:   func() error { return t.uploadDerivedMedias(&m, result, transformations) },
+			Undo: func() error { return deleteUploaded(t.cacheStorage, details) },
+		},
+		{
+			Name: "save record",
+			Do: func() error {
+				if err := t.pluginManager.OnUpload(&m); err != nil {
+					return err
+				}
+				return t.mediaStorage.Save(&m)
+			},
+			Undo: func() error { return deleteRecord(t.mediaStorage, details) },
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	t.pluginManager.EnqueueUploadHooks(&m)
+	t.eventBus.Publish(event.MediaUploaded, m)
+	for i := range m.DerivedMedias {
+		t.pluginManager.OnDerivedCreated(&m, &m.DerivedMedias[i])
+		t.eventBus.Publish(event.DerivedCreated, m.DerivedMedias[i])
+	}
+
+	return &m, nil
+}
+
+// optimize applies the optimizations of the content type to the body, the
+// result is left to be uploaded.
+func (t *UploadMediaTask) optimize(path media.Path, body io.Reader, contentType media.ContentType) (pipeline.PipelineCtx, error) {
 	source := pipeline.NewSource(
 		func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-			ctx.Path = media.NewPath(path)
+			ctx.Path = path
 			ctx.Buffer = pipeline.NewBuffer(body)
 			ctx.Buffer.ReadAll()
 			ctx.ContentType = contentType
@@ -59,96 +161,137 @@ func (t *UploadMediaTask) Upload(
 		})
 
 	sinker := pipeline.NewSinker(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-		err := t.fileStorage.Upload(media.UploadInput{
-			Path:          ctx.Path,
-			Body:          ctx.Buffer.Reader(),
-			ContentType:   ctx.ContentType,
-			ContentLength: ctx.Buffer.Len(),
-		})
-		return ctx, err
+		return ctx, nil
 	})
 
 	steps, err := t.mediaOptimization.GetSteps(contentType)
 	if err != nil {
-		return nil, err
+		return pipeline.PipelineCtx{}, err
 	}
 	p := pipeline.NewPipeline(&source, &sinker, steps)
+	return p.Execute()
+}
 
-	result, err := p.Execute()
-	if err != nil {
-		return nil, err
+func (t *UploadMediaTask) uploadOriginal(result pipeline.PipelineCtx) error {
+	return t.fileStorage.Upload(media.UploadInput{
+		Path:          result.Path,
+		Body:          result.Buffer.Reader(),
+		ContentType:   result.ContentType,
+		ContentLength: result.Buffer.Len(),
+	})
+}
+
+func (t *UploadMediaTask) uploadDerivedMedias(m *media.Media, result pipeline.PipelineCtx, transformations []string) error {
+	if len(transformations) == 0 {
+		return nil
 	}
+	for _, transformation := range transformations {
+		transformations, err := t.namedTransformationParser.Parse(transformation)
+		if err != nil {
+			return err
+		}
+		trans, err := t.transformationParser.Parse(*transformations)
+		if err != nil {
+			return err
+		}
+		steps, err := t.transformationsBuilder.Build(trans)
+		if err != nil {
+			return err
+		}
 
-	m := media.Media{
-		Path:             result.Path,
-		ContentType:      result.ContentType,
-		ContentLength:    result.Buffer.Len(),
-		EmbeddedMetadata: result.EmbeddedMetadata,
-		DerivedMedias:    []media.DerivedMedia{},
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
-	}
-
-	if len(transformations) > 0 {
-		for _, transformation := range transformations {
-			transformations, err := t.namedTransformationParser.Parse(transformation)
-			if err != nil {
-				return nil, err
-			}
-			trans, err := t.transformationParser.Parse(*transformations)
-			if err != nil {
-				return nil, err
-			}
-			steps, err := t.transformationsBuilder.Build(trans)
-			if err != nil {
-				return nil, err
-			}
-
-			source = pipeline.NewSource(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-				ctx.Path = result.Path
-				ctx.Buffer = result.Buffer
-				ctx.ContentType = result.ContentType
-				ctx.EmbeddedMetadata = result.EmbeddedMetadata
-				ctx.Buffer.ReadAll()
-				return ctx, nil
-			})
+		source := pipeline.NewSource(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
+			ctx.Path = result.Path
+			ctx.Buffer = result.Buffer
+			ctx.ContentType = result.ContentType
+			ctx.EmbeddedMetadata = result.EmbeddedMetadata
+			ctx.Buffer.ReadAll()
+			return ctx, nil
+		})
 
-			cacheSinker := pipeline.NewSinker(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-				err := t.cacheStorage.Upload(media.UploadInput{
-					Path:          ctx.Path.AppendSuffix(*transformations),
-					Body:          ctx.Buffer.Reader(),
-					ContentType:   ctx.ContentType,
-					ContentLength: ctx.Buffer.Len(),
-				})
-				return ctx, err
+		cacheSinker := pipeline.NewSinker(func(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
+			err := t.cacheStorage.Upload(media.UploadInput{
+				Path:          ctx.Path.AppendSuffix(*transformations),
+				Body:          ctx.Buffer.Reader(),
+				ContentType:   ctx.ContentType,
+				ContentLength: ctx.Buffer.Len(),
 			})
+			return ctx, err
+		})
 
-			p = pipeline.NewPipeline(&source, &cacheSinker, steps)
-			_, err = p.Execute()
-			if err != nil {
-				return nil, err
-			}
+		p := pipeline.NewPipeline(&source, &cacheSinker, steps)
+		_, err = p.Execute()
+		if err != nil {
+			return err
 		}
+	}
 
-		derivedMedias, err := t.cacheStorage.GetMultiple(m.Path)
-		if err != nil {
-			return nil, err
+	derivedMedias, err := t.cacheStorage.GetMultiple(m.Path)
+	if err != nil {
+		return err
+	}
+	for _, a := range derivedMedias {
+		m.DerivedMedias = append(m.DerivedMedias, media.DerivedMedia{
+			Path:          a.Path,
+			ContentType:   a.ContentType,
+			ContentLength: a.ContentLength,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		})
+	}
+	return nil
+}
+
+// plan rebuilds the steps of an interrupted upload to compensate it, the
+// body is not available to complete it.
+func (t *UploadMediaTask) plan(op *journal.Operation) ([]Step, error) {
+	var details uploadMediaDetails
+	if err := op.DecodeDetails(&details); err != nil {
+		return nil, err
+	}
+	unavailable := func() error { return errUploadBodyUnavailable }
+	return []Step{
+		{Name: "upload original", Do: unavailable, Undo: func() error { return deleteUploaded(t.fileStorage, &details) }},
+		{Name: "upload derived medias", Do: unavailable, Undo: func() error { return deleteUploaded(t.cacheStorage, &details) }},
+		{Name: "save record", Do: unavailable, Undo: func() error { return deleteRecord(t.mediaStorage, &details) }},
+	}, nil
+}
+
+// deleteUploaded deletes the files named after the uploaded media, its
+// extension may have changed before the path was recorded.
+func deleteUploaded(s media.FileStorer, details *uploadMediaDetails) error {
+	p := media.NewPath(details.Path)
+	if p.Uuid() == "" {
+		if err := s.Delete(p); err != nil {
+			return err
 		}
-		for _, a := range derivedMedias {
-			m.DerivedMedias = append(m.DerivedMedias, media.DerivedMedia{
-				Path:          a.Path,
-				ContentType:   a.ContentType,
-				ContentLength: a.ContentLength,
-				CreatedAt:     time.Now(),
-				UpdatedAt:     time.Now(),
-			})
+		if details.MediaPath != "" {
+			return s.Delete(media.NewPath(details.MediaPath))
 		}
+		return nil
 	}
+	return deleteNamedAfter(s, p)
+}
 
-	err = t.mediaStorage.Save(&m)
+// deleteNamedAfter deletes the files of the folder of p prefixed by its uuid.
+func deleteNamedAfter(s media.FileStorer, p media.Path) error {
+	files, err := s.GetMultiple(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+	for _, f := range files {
+		if err := s.Delete(f.Path); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return &m, nil
+func deleteRecord(s media.Storer, details *uploadMediaDetails) error {
+	if details.MediaPath == "" {
+		return nil
+	}
+	return s.Delete(media.NewPath(details.MediaPath))
 }
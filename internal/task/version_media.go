@@ -0,0 +1,287 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/rs/zerolog/log"
+)
+
+const versionMediaOperation = "version_media"
+
+type versionMediaDetails struct {
+	Previous media.Media `json:"previous"`
+	// Version is where the previous original is kept, it is pruned right
+	// away when versioning is disabled.
+	Version media.Version `json:"version"`
+	Media   media.Media   `json:"media"`
+	// Pruned are the versions the retention limits dropped.
+	Pruned []media.Version `json:"pruned,omitempty"`
+}
+
+// VersionMediaTask replaces the original of a media, keeping the previous
+// one as a version when the folder of the media has versioning enabled.
+type VersionMediaTask struct {
+	fileStorage   media.FileStorer
+	cacheStorage  media.FileStorer
+	mediaStorage  media.Storer
+	folderStorage folder.Storer
+	uploadMedia   *UploadMediaTask
+	eventBus      *event.Bus
+	journal       *Journal
+}
+
+func NewVersionMediaTask(
+	fileStorage media.FileStorer,
+	cacheStorage media.FileStorer,
+	mediaStorage media.Storer,
+	folderStorage folder.Storer,
+	uploadMedia *UploadMediaTask,
+	eventBus *event.Bus,
+	journal *Journal,
+) VersionMediaTask {
+	t := VersionMediaTask{
+		fileStorage,
+		cacheStorage,
+		mediaStorage,
+		folderStorage,
+		uploadMedia,
+		eventBus,
+		journal,
+	}
+	journal.RegisterPlanner(versionMediaOperation, t.plan)
+	return t
+}
+
+func (t *VersionMediaTask) GetVersions(path media.Path) ([]media.Version, error) {
+	m, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if m.Versions == nil {
+		return []media.Version{}, nil
+	}
+	return m.Versions, nil
+}
+
+// Upload replaces the original of the media with the body. The derived
+// medias are generated again, with the transformations of the previous ones
+// when none are given, and the custom metadata are kept when nil.
+func (t *VersionMediaTask) Upload(
+	path media.Path,
+	body io.Reader,
+	contentType string,
+	transformations []string,
+	customMetadata media.CustomMetadata,
+) (*media.Media, error) {
+	if !media.IsContentTypeSupported(contentType) {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeMimeTypeNotSupported)
+	}
+	previous, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if customMetadata == nil {
+		customMetadata = previous.CustomMetadata
+	}
+	if err := t.uploadMedia.metadataValidator.Validate(path, customMetadata); err != nil {
+		return nil, err
+	}
+
+	result, err := t.uploadMedia.optimize(path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if result.Path != previous.Path {
+		return nil, &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("a new version of %s must keep its extension", path.ToString())}
+	}
+
+	m := *previous
+	m.ContentType = result.ContentType
+	m.ContentLength = result.Buffer.Len()
+	m.EmbeddedMetadata = result.EmbeddedMetadata
+	m.CustomMetadata = customMetadata
+	return t.replace(previous, m, func() error {
+		return t.uploadMedia.uploadOriginal(result)
+	}, transformations)
+}
+
+// Restore makes the version the original of the media again, the version
+// replaced is kept like on an upload.
+func (t *VersionMediaTask) Restore(path media.Path, id string) (*media.Media, error) {
+	previous, err := t.mediaStorage.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	var version *media.Version
+	for i := range previous.Versions {
+		if previous.Versions[i].Id == id {
+			version = &previous.Versions[i]
+		}
+	}
+	if version == nil {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeVersionNotFound)
+	}
+
+	m := *previous
+	m.ContentType = version.ContentType
+	m.ContentLength = version.ContentLength
+	m.EmbeddedMetadata = version.EmbeddedMetadata
+	versionPath := version.Path
+	return t.replace(previous, m, func() error {
+		return t.fileStorage.Copy(versionPath, path)
+	}, nil)
+}
+
+func (t *VersionMediaTask) replace(previous *media.Media, m media.Media, upload func() error, transformations []string) (*media.Media, error) {
+	if len(transformations) == 0 {
+		for _, dm := range previous.DerivedMedias {
+			transformations = append(transformations, dm.Path.Transformations())
+		}
+	}
+	settings, err := folder.Closest(t.folderStorage, previous.Path.Dir(), func(s *folder.Settings) bool {
+		return s.Versioning != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var versioning *folder.Versioning
+	if settings != nil && settings.Versioning.Enabled {
+		versioning = settings.Versioning
+	}
+
+	id := uuid.New().String()
+	details := &versionMediaDetails{
+		Previous: *previous,
+		Version: media.Version{
+			Id:               id,
+			Path:             previous.Path.VersionPath(id),
+			ContentType:      previous.ContentType,
+			ContentLength:    previous.ContentLength,
+			EmbeddedMetadata: previous.EmbeddedMetadata,
+			CreatedAt:        time.Now(),
+		},
+	}
+	m.DerivedMedias = []media.DerivedMedia{}
+	m.UpdatedAt = time.Now()
+	details.Media = m
+
+	steps := t.steps(details)
+	steps[1].Do = upload
+	steps[2].Do = func() error {
+		if err := deleteNamedAfter(t.cacheStorage, previous.Path); err != nil {
+			return err
+		}
+		return t.regenerateDerivedMedias(&details.Media, transformations)
+	}
+	steps[3].Do = func() error {
+		versions := details.Previous.Versions
+		if versioning != nil {
+			versions = append(append([]media.Version{}, versions...), details.Version)
+			details.Media.Versions, details.Pruned = versioning.Prune(versions, time.Now())
+		} else {
+			details.Media.Versions = versions
+			details.Pruned = []media.Version{details.Version}
+		}
+		return t.mediaStorage.Save(&details.Media)
+	}
+	if err := t.journal.Run(versionMediaOperation, details, steps); err != nil {
+		return nil, err
+	}
+
+	replaced := details.Media
+	t.eventBus.Publish(event.MediaReplaced, replaced)
+	for i := range replaced.DerivedMedias {
+		t.uploadMedia.pluginManager.OnDerivedCreated(&replaced, &replaced.DerivedMedias[i])
+		t.eventBus.Publish(event.DerivedCreated, replaced.DerivedMedias[i])
+	}
+	return &replaced, nil
+}
+
+// plan rebuilds the steps of an interrupted replacement, the new original is
+// not available anymore so only the ones after the commit can complete.
+func (t *VersionMediaTask) plan(op *journal.Operation) ([]Step, error) {
+	var details versionMediaDetails
+	if err := op.DecodeDetails(&details); err != nil {
+		return nil, err
+	}
+	return t.steps(&details), nil
+}
+
+// steps keeps a copy of the previous original first, the original is
+// restored from it until the new record is saved. The steps before the
+// commit can't be planned again, replace sets what they do.
+func (t *VersionMediaTask) steps(details *versionMediaDetails) []Step {
+	unavailable := func() error { return errUploadBodyUnavailable }
+	previous := details.Previous
+	return []Step{
+		{
+			Name: "keep previous original",
+			Do:   func() error { return t.fileStorage.Copy(previous.Path, details.Version.Path) },
+			Undo: func() error { return t.fileStorage.Delete(details.Version.Path) },
+		},
+		{
+			Name: "upload original",
+			Do:   unavailable,
+			Undo: func() error { return t.fileStorage.Copy(details.Version.Path, previous.Path) },
+		},
+		{
+			Name: "upload derived medias",
+			Do:   unavailable,
+			// The previous derived medias were deleted, they are left to be
+			// generated on demand.
+			Undo: func() error {
+				if err := deleteNamedAfter(t.cacheStorage, previous.Path); err != nil {
+					return err
+				}
+				m := previous
+				m.DerivedMedias = []media.DerivedMedia{}
+				return t.mediaStorage.Save(&m)
+			},
+		},
+		{
+			Name: "save record",
+			Do:   unavailable,
+			Undo: func() error { return t.mediaStorage.Save(&previous) },
+		},
+		{
+			Name: "delete pruned versions",
+			Do: func() error {
+				for _, v := range details.Pruned {
+					if err := t.fileStorage.Delete(v.Path); err != nil {
+						log.Warn().Err(err).Msgf("unable to delete version %s", v.Path.ToString())
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// regenerateDerivedMedias applies the transformations to the new original.
+func (t *VersionMediaTask) regenerateDerivedMedias(m *media.Media, transformations []string) error {
+	if len(transformations) == 0 {
+		return nil
+	}
+	original, err := t.fileStorage.Download(m.Path)
+	if err != nil {
+		return err
+	}
+	defer original.Body.Close()
+
+	result := pipeline.PipelineCtx{
+		Path:             m.Path,
+		Buffer:           pipeline.NewBuffer(original.Body),
+		ContentType:      m.ContentType,
+		EmbeddedMetadata: m.EmbeddedMetadata,
+	}
+	return t.uploadMedia.uploadDerivedMedias(m, result, transformations)
+}
@@ -0,0 +1,102 @@
+package task
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func TestVersionMediaTask(t *testing.T) {
+	s := newMigrationStorages(t)
+	cacheStorage := filesystem.NewFileStorage(filesystem.FileStorageConfig{MountDir: filepath.Join(t.TempDir(), "cache")})
+	folderStorage := memoryFolderStorer{
+		"/a": folder.Settings{Path: "/a", Versioning: &folder.Versioning{Enabled: true, MaxVersions: 2}},
+	}
+	j, _ := newTestJournal(t)
+	uploadMedia := NewUploadMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, filesystem.NewNamedTransformationStorage(), nil, nil, event.NewBus(), folderStorage, j)
+	versionMedia := NewVersionMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, folderStorage, &uploadMedia, event.NewBus(), j)
+	deleteMedia := NewDeleteMediaTask(s.FileStorage, cacheStorage, s.MediaStorage, nopRecorder{}, nil, event.NewBus(), j)
+
+	content := func(p media.Path) string {
+		res, err := s.FileStorage.Download(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return string(b)
+	}
+	versioned := media.NewPath("/a/" + fsckUuid1 + ".mp4")
+	unversioned := media.NewPath("/b/" + fsckUuid2 + ".mp4")
+	for _, p := range []media.Path{versioned, unversioned} {
+		if err := s.FileStorage.Upload(media.UploadInput{Path: p, Body: bytes.NewReader([]byte("v1")), ContentLength: 2}); err != nil {
+			t.Fatal(err)
+		}
+		m := media.Media{Path: p, ContentType: media.VideoMp4, ContentLength: 2, DerivedMedias: []media.DerivedMedia{}, CreatedAt: time.Now()}
+		if err := s.MediaStorage.Save(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var first media.Version
+	for _, body := range []string{"v2", "v3", "v4"} {
+		m, err := versionMedia.Upload(versioned, bytes.NewReader([]byte(body)), media.VideoMp4, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Id == "" {
+			first = m.Versions[0]
+		}
+	}
+	if content(versioned) != "v4" {
+		t.Errorf("original should be replaced, got %s", content(versioned))
+	}
+	versions, err := versionMedia.GetVersions(versioned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || content(versions[0].Path) != "v2" || content(versions[1].Path) != "v3" {
+		t.Fatalf("the two last versions should be kept, got %+v", versions)
+	}
+	if _, err := s.FileStorage.Get(first.Path); err == nil {
+		t.Errorf("pruned version %s should be deleted", first.Path.ToString())
+	}
+
+	restored, err := versionMedia.Restore(versioned, versions[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content(versioned) != "v2" || len(restored.Versions) != 2 || content(restored.Versions[1].Path) != "v4" {
+		t.Errorf("version should be restored, got %s and versions %+v", content(versioned), restored.Versions)
+	}
+	if _, err := versionMedia.Restore(versioned, "unknown"); err == nil {
+		t.Errorf("restoring an unknown version should fail")
+	}
+
+	m, err := versionMedia.Upload(unversioned, bytes.NewReader([]byte("v2")), media.VideoMp4, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content(unversioned) != "v2" || len(m.Versions) != 0 {
+		t.Errorf("media without versioning should be overwritten, got %+v", m.Versions)
+	}
+
+	if err := deleteMedia.Delete(versioned); err != nil {
+		t.Fatal(err)
+	}
+	checker := NewConsistencyChecker(s.FileStorage, cacheStorage, s.MediaStorage, nil)
+	report, err := checker.Check(FsckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsConsistent() {
+		t.Errorf("versions should be deleted with their media, got %+v", report)
+	}
+}
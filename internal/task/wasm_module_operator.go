@@ -0,0 +1,46 @@
+package task
+
+import (
+	"errors"
+	"io"
+
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+)
+
+type WasmModuleOperator struct {
+	wasmRuntime            *transform.WasmRuntime
+	transformationsBuilder *transform.Builder
+}
+
+func NewWasmModuleOperator(wasmRuntime *transform.WasmRuntime, transformationsBuilder *transform.Builder) WasmModuleOperator {
+	return WasmModuleOperator{
+		wasmRuntime,
+		transformationsBuilder,
+	}
+}
+
+func (t *WasmModuleOperator) GetAll() []string {
+	return t.transformationsBuilder.WasmModules()
+}
+
+// Upload registers the module before writing it to the modules directory, so
+// that a module which can't be registered is not loaded again on restart.
+func (t *WasmModuleOperator) Upload(name string, body io.Reader) error {
+	if !t.wasmRuntime.CanSaveModules() {
+		return errors.New("wasm modules directory is not configured")
+	}
+	wasm, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m, err := t.wasmRuntime.Compile(name, wasm)
+	if err != nil {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	if err := t.transformationsBuilder.RegisterWasmModule(m); err != nil {
+		m.Close()
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: err}
+	}
+	return t.wasmRuntime.SaveModule(m, wasm)
+}
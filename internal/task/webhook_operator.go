@@ -0,0 +1,96 @@
+package task
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/webhook"
+)
+
+type WebhookOperator struct {
+	webhookStorage webhook.Storer
+}
+
+func NewWebhookOperator(webhookStorage webhook.Storer) WebhookOperator {
+	return WebhookOperator{
+		webhookStorage,
+	}
+}
+
+func (t *WebhookOperator) GetAll() ([]webhook.Webhook, error) {
+	webhooks, err := t.webhookStorage.GetAll()
+	if err != nil {
+		return []webhook.Webhook{}, err
+	}
+	arr := []webhook.Webhook{}
+	for _, w := range webhooks {
+		arr = append(arr, w)
+	}
+	return arr, nil
+}
+
+func (t *WebhookOperator) Get(id string) (*webhook.Webhook, error) {
+	return t.webhookStorage.Get(id)
+}
+
+func (t *WebhookOperator) Create(url string, events []event.Type) (*webhook.Webhook, error) {
+	if err := validateWebhook(url, events); err != nil {
+		return nil, err
+	}
+	w := webhook.Webhook{
+		Id:        uuid.New().String(),
+		Url:       url,
+		Secret:    webhook.GenerateSecret(),
+		Events:    events,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	err := t.webhookStorage.Save(w)
+	return &w, err
+}
+
+func (t *WebhookOperator) Update(id string, url string, events []event.Type) (*webhook.Webhook, error) {
+	if err := validateWebhook(url, events); err != nil {
+		return nil, err
+	}
+	w, err := t.webhookStorage.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	w.Url = url
+	w.Events = events
+	w.UpdatedAt = time.Now()
+	err = t.webhookStorage.Save(*w)
+	return w, err
+}
+
+func (t *WebhookOperator) Delete(id string) error {
+	if _, err := t.webhookStorage.Get(id); err != nil {
+		return err
+	}
+	return t.webhookStorage.Delete(id)
+}
+
+func (t *WebhookOperator) GetDeliveries(id string) ([]webhook.Delivery, error) {
+	if _, err := t.webhookStorage.Get(id); err != nil {
+		return nil, err
+	}
+	return t.webhookStorage.GetDeliveries(id)
+}
+
+func validateWebhook(rawUrl string, events []event.Type) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("invalid webhook url %s", rawUrl)}
+	}
+	for _, e := range events {
+		if !event.IsTypeSupported(e) {
+			return &mindiaerr.Error{ErrCode: mindiaerr.ErrBadRequest, Msg: fmt.Errorf("unsupported event type %s", e)}
+		}
+	}
+	return nil
+}
@@ -1,41 +1,120 @@
 package transform
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
 )
 
+const transformationPrefix = "c_"
+
+// StepFactory builds a pipeline step from the arguments of a transformation.
+type StepFactory func(args map[string]string) (pipeline.PipelineStep, error)
+
 type Builder struct {
-	scalerFactory      ScalerFactory
-	watermarkerFactory WatermarkerFactory
+	mu          sync.RWMutex
+	factories   map[string]StepFactory
+	wasmModules map[string]*WasmModule
+}
+
+func NewBuilder(dataStorage media.FileStorer) *Builder {
+	scalerFactory := ScalerFactory{}
+	watermarkerFactory := NewWatermarkerFactory(dataStorage)
+
+	return &Builder{
+		factories: map[string]StepFactory{
+			"c_scale": func(args map[string]string) (pipeline.PipelineStep, error) {
+				return scalerFactory.Build(args)
+			},
+			"c_watermark": func(args map[string]string) (pipeline.PipelineStep, error) {
+				return watermarkerFactory.Build(args)
+			},
+		},
+		wasmModules: map[string]*WasmModule{},
+	}
+}
+
+// RegisterFactory adds a transformation to the builder. Names must start with
+// "c_" and built-in transformations cannot be overridden.
+func (b *Builder) RegisterFactory(name string, factory StepFactory) error {
+	if !strings.HasPrefix(name, transformationPrefix) || len(name) == len(transformationPrefix) {
+		return fmt.Errorf("transformation name %s must start with %s", name, transformationPrefix)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.factories[name]; ok {
+		return fmt.Errorf("transformation %s is already registered", name)
+	}
+	b.factories[name] = factory
+	return nil
+}
+
+// RegisterWasmModule registers the module as the c_<module name>
+// transformation, replacing a previously registered version of the module
+// which is closed once the transformations running it are done.
+func (b *Builder) RegisterWasmModule(m *WasmModule) error {
+	name := transformationPrefix + m.Name()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.factories[name]; ok {
+		if _, ok := b.wasmModules[name]; !ok {
+			return fmt.Errorf("transformation %s is already registered", name)
+		}
+	}
+	if previous, ok := b.wasmModules[name]; ok && previous.sum != m.sum {
+		defer previous.retire()
+	}
+	b.wasmModules[name] = m
+	b.factories[name] = func(args map[string]string) (pipeline.PipelineStep, error) {
+		return &WasmTransformer{builder: b, name: name, args: args}, nil
+	}
+	return nil
+}
+
+// acquireWasmModule returns the module registered as the transformation, it
+// must be released once used.
+func (b *Builder) acquireWasmModule(name string) (*WasmModule, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	m, ok := b.wasmModules[name]
+	if !ok {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeTransformationNotFound)
+	}
+	m.acquire()
+	return m, nil
 }
 
-func NewBuilder(dataStorage media.FileStorer) Builder {
-	return Builder{
-		scalerFactory:      ScalerFactory{},
-		watermarkerFactory: NewWatermarkerFactory(dataStorage),
+// WasmModules returns the names of the transformations backed by a WASM
+// module.
+func (b *Builder) WasmModules() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := []string{}
+	for name := range b.wasmModules {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
 func (b *Builder) Build(ts []Transformation) ([]pipeline.PipelineStep, error) {
 	var transformers []pipeline.PipelineStep
 
 	for _, t := range ts {
-		var (
-			t2  pipeline.PipelineStep
-			err error
-		)
-
-		switch t.Name {
-		case "c_scale":
-			t2, err = b.scalerFactory.Build(t.Args)
-		case "c_watermark":
-			t2, err = b.watermarkerFactory.Build(t.Args)
-		default:
-			err = mindiaerr.New(mindiaerr.ErrCodeTransformationNotFound)
+		b.mu.RLock()
+		factory, ok := b.factories[t.Name]
+		b.mu.RUnlock()
+		if !ok {
+			return nil, mindiaerr.New(mindiaerr.ErrCodeTransformationNotFound)
 		}
 
+		t2, err := factory(t.Args)
 		if err != nil {
 			return nil, err
 		}
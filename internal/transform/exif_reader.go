@@ -2,6 +2,8 @@ package transform
 
 import (
 	"fmt"
+	"image"
+	"strconv"
 	"strings"
 
 	"github.com/jeremybastin1207/mindia-core/internal/media"
@@ -22,6 +24,13 @@ func NewExifReader() ExifReader {
 func (r *ExifReader) Execute(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
 	var metadata = media.Metadata{}
 
+	config, _, err := image.DecodeConfig(ctx.Buffer.Reader())
+	if err == nil {
+		metadata[media.WidthMetadataKey] = strconv.Itoa(config.Width)
+		metadata[media.HeightMetadataKey] = strconv.Itoa(config.Height)
+		ctx.EmbeddedMetadata = metadata
+	}
+
 	x, err := exif.Decode(ctx.Buffer.Reader())
 	if err != nil {
 		return ctx, nil
@@ -34,7 +43,7 @@ func (r *ExifReader) Execute(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, er
 
 	make, err := x.Get(exif.Make)
 	if err == nil {
-		metadata["make"] = parseTag(make)
+		metadata[media.CameraMakeMetadataKey] = parseTag(make)
 	}
 
 	model, err := x.Get(exif.Model)
@@ -58,8 +67,8 @@ func (r *ExifReader) Execute(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, er
 
 	lat, long, err := x.LatLong()
 	if err == nil {
-		metadata["lat"] = fmt.Sprintf("%v", lat)
-		metadata["long"] = fmt.Sprintf("%v", long)
+		metadata[media.LatitudeMetadataKey] = fmt.Sprintf("%v", lat)
+		metadata[media.LongitudeMetadataKey] = fmt.Sprintf("%v", long)
 	}
 
 	colorSpace, err := x.Get(exif.ColorSpace)
@@ -0,0 +1,40 @@
+package transform
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+// Tagger detects the tags of a media.
+type Tagger interface {
+	Provider() string
+	Tag(ctx context.Context, body []byte, contentType media.ContentType) ([]media.Tag, error)
+}
+
+// MergeTags replaces the tags of the given provider by the new ones and keeps
+// the tags of the other providers.
+func MergeTags(current []media.Tag, provider string, tags []media.Tag) []media.Tag {
+	merged := []media.Tag{}
+	for _, t := range current {
+		if t.Provider != provider {
+			merged = append(merged, t)
+		}
+	}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		value := media.NormalizeTagValue(t.Value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		t.Value = value
+		t.Provider = provider
+		merged = append(merged, t)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].ConfidenceScore > merged[j].ConfidenceScore
+	})
+	return merged
+}
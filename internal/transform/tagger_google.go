@@ -1,51 +1,83 @@
 package transform
 
 import (
+	"bytes"
 	"context"
+	"sync"
 
 	vision "cloud.google.com/go/vision/apiv1"
 	"github.com/jeremybastin1207/mindia-core/internal/media"
-	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
 )
 
-func NewGoogleTagger() GoogleTagger {
-	return GoogleTagger{}
-}
+const GoogleTaggerProvider = "google"
 
 type GoogleTagger struct {
+	mu        sync.Mutex
+	client    *vision.ImageAnnotatorClient
+	maxLabels int
+}
+
+func NewGoogleTagger() *GoogleTagger {
+	return &GoogleTagger{
+		maxLabels: 10,
+	}
+}
+
+func (t *GoogleTagger) Provider() string {
+	return GoogleTaggerProvider
 }
 
-func (w *GoogleTagger) Run(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
-	var (
-		ctx2 = context.Background()
-	)
+// getClient creates the Vision client on first use, it is then shared by all
+// the calls.
+func (t *GoogleTagger) getClient(ctx context.Context) (*vision.ImageAnnotatorClient, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	client, err := vision.NewImageAnnotatorClient(ctx2)
+	if t.client == nil {
+		client, err := vision.NewImageAnnotatorClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.client = client
+	}
+	return t.client, nil
+}
+
+func (t *GoogleTagger) Tag(ctx context.Context, body []byte, contentType media.ContentType) ([]media.Tag, error) {
+	client, err := t.getClient(ctx)
 	if err != nil {
-		return ctx, err
+		return nil, err
 	}
-	defer client.Close()
 
-	image, err := vision.NewImageFromReader(ctx.Buffer.Reader())
+	image, err := vision.NewImageFromReader(bytes.NewReader(body))
 	if err != nil {
-		return ctx, err
+		return nil, err
 	}
 
-	labels, err := client.DetectLabels(ctx2, image, nil, 10)
+	labels, err := client.DetectLabels(ctx, image, nil, t.maxLabels)
 	if err != nil {
-		return ctx, err
+		return nil, err
 	}
 
-	var tags []media.Tag
+	tags := []media.Tag{}
 	for _, label := range labels {
 		tags = append(tags, media.Tag{
 			Value:           label.Description,
 			ConfidenceScore: label.Score,
-			Provider:        "google",
+			Provider:        GoogleTaggerProvider,
 		})
 	}
+	return tags, nil
+}
 
-	ctx.Tags = tags
+func (t *GoogleTagger) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	return ctx, nil
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
 }
@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+const HttpTaggerProvider = "http"
+
+// HttpTagger posts the media body to an endpoint which answers with the tags:
+//
+//	{"tags": [{"value": "dog", "confidence_score": 0.9}]}
+type HttpTagger struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewHttpTagger(url string, token string, timeout time.Duration) *HttpTagger {
+	return &HttpTagger{
+		url:   url,
+		token: token,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (t *HttpTagger) Provider() string {
+	return HttpTaggerProvider
+}
+
+func (t *HttpTagger) Tag(ctx context.Context, body []byte, contentType media.ContentType) ([]media.Tag, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("tagging endpoint responded %d: %s", resp.StatusCode, bytes.TrimSpace(b))
+	}
+
+	var result struct {
+		Tags []media.Tag `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid tagging endpoint response: %w", err)
+	}
+	for i := range result.Tags {
+		result.Tags[i].Provider = HttpTaggerProvider
+	}
+	return result.Tags, nil
+}
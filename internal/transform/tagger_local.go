@@ -0,0 +1,288 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	_ "golang.org/x/image/webp"
+)
+
+const LocalTaggerProvider = "local"
+
+const (
+	// sampleSize bounds the sides of the pictures downsampled before analysis,
+	// their aspect ratio is kept so that the regions keep their shape.
+	sampleSize = 64
+	// minColorShare is the share of the pixels a colour must cover to be a tag.
+	minColorShare = 0.15
+	// minFaceShare and maxFaceShare bound the share of the pixels a skin
+	// region must cover to be taken as a face.
+	minFaceShare = 0.01
+	maxFaceShare = 0.5
+	// maxFaceConfidence caps the confidence of the faces tag, it is only a
+	// guess from the skin tones.
+	maxFaceConfidence = 0.8
+)
+
+// LocalTagger derives tags from the pixels of a picture without any network
+// call: dominant colours, grayscale, transparency, orientation, size and
+// faces, guessed from the regions of skin tones.
+type LocalTagger struct{}
+
+func NewLocalTagger() *LocalTagger {
+	return &LocalTagger{}
+}
+
+func (t *LocalTagger) Provider() string {
+	return LocalTaggerProvider
+}
+
+func (t *LocalTagger) Tag(ctx context.Context, body []byte, contentType media.ContentType) ([]media.Tag, error) {
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, mindiaerr.New(mindiaerr.ErrCodeMimeTypeNotSupported)
+	}
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	tags := []media.Tag{
+		newLocalTag(orientation(bounds.Dx(), bounds.Dy()), 1),
+		newLocalTag(sizeClass(bounds.Dx(), bounds.Dy()), 1),
+	}
+
+	sample := imaging.Fit(img, sampleSize, sampleSize, imaging.Box)
+	var (
+		colors      = map[string]int{}
+		skin        = make([]bool, len(sample.Pix)/4)
+		total       = 0
+		transparent = 0
+		grayscale   = true
+	)
+	for i := 0; i+3 < len(sample.Pix); i += 4 {
+		r, g, b, a := sample.Pix[i], sample.Pix[i+1], sample.Pix[i+2], sample.Pix[i+3]
+		total++
+		if a < 255 {
+			transparent++
+		}
+		if a < 32 {
+			continue
+		}
+		skin[i/4] = isSkin(r, g, b)
+		name, saturated := colorName(r, g, b)
+		if saturated {
+			grayscale = false
+		}
+		colors[name]++
+	}
+	if total == 0 {
+		return tags, nil
+	}
+
+	for name, count := range colors {
+		share := float32(count) / float32(total)
+		if share >= minColorShare {
+			tags = append(tags, newLocalTag(name, share))
+		}
+	}
+	if grayscale {
+		tags = append(tags, newLocalTag("grayscale", 1))
+	}
+	if share := float32(transparent) / float32(total); share > 0.01 {
+		tags = append(tags, newLocalTag("transparent", share))
+	}
+	if confidence := faceConfidence(skin, sample.Rect.Dx()); confidence > 0 {
+		tags = append(tags, newLocalTag("faces", confidence))
+	}
+	return tags, nil
+}
+
+func newLocalTag(value string, confidence float32) media.Tag {
+	return media.Tag{
+		Value:           value,
+		ConfidenceScore: confidence,
+		Provider:        LocalTaggerProvider,
+	}
+}
+
+func orientation(width, height int) string {
+	ratio := float64(width) / float64(height)
+	switch {
+	case ratio > 1.05:
+		return "landscape"
+	case ratio < 0.95:
+		return "portrait"
+	default:
+		return "square"
+	}
+}
+
+func sizeClass(width, height int) string {
+	side := width
+	if height > side {
+		side = height
+	}
+	switch {
+	case side >= 3840:
+		return "4k"
+	case side >= 1920:
+		return "large"
+	case side >= 640:
+		return "medium"
+	default:
+		return "small"
+	}
+}
+
+// isSkin tells whether the pixel has a skin tone, with the RGB rule of Kovac
+// et al.
+func isSkin(r, g, b uint8) bool {
+	max, min := r, r
+	for _, c := range []uint8{g, b} {
+		if c > max {
+			max = c
+		}
+		if c < min {
+			min = c
+		}
+	}
+	diff := int(r) - int(g)
+	return r > 95 && g > 40 && b > 20 && max-min > 15 && (diff > 15 || diff < -15) && r > g && r > b
+}
+
+// faceConfidence looks for a face among the connected regions of skin of the
+// mask: a region taller than wide which fills most of its bounding box. The
+// confidence is how much it fills it.
+func faceConfidence(skin []bool, width int) float32 {
+	height := len(skin) / width
+	seen := make([]bool, len(skin))
+	best := float32(0)
+	for start := range skin {
+		if !skin[start] || seen[start] {
+			continue
+		}
+		count := 0
+		minX, minY, maxX, maxY := width, height, 0, 0
+		stack := []int{start}
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%width, i/width
+			count++
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+			for _, n := range []int{i - width, i + width, i - 1, i + 1} {
+				if n < 0 || n >= len(skin) || (n == i-1 && x == 0) || (n == i+1 && x == width-1) {
+					continue
+				}
+				if skin[n] && !seen[n] {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+
+		share := float32(count) / float32(len(skin))
+		if share < minFaceShare || share > maxFaceShare {
+			continue
+		}
+		w, h := maxX-minX+1, maxY-minY+1
+		ratio := float32(h) / float32(w)
+		fill := float32(count) / float32(w*h)
+		if ratio < 0.8 || ratio > 2 || fill < 0.5 {
+			continue
+		}
+		if confidence := fill * maxFaceConfidence; confidence > best {
+			best = confidence
+		}
+	}
+	return best
+}
+
+// colorName returns the name of the colour closest to the pixel and whether
+// the pixel is saturated enough to not be a shade of gray.
+func colorName(r, g, b uint8) (string, bool) {
+	h, s, v := toHsv(r, g, b)
+	switch {
+	case v < 0.2:
+		return "black", false
+	case s < 0.15 && v > 0.85:
+		return "white", false
+	case s < 0.15:
+		return "gray", false
+	}
+	switch {
+	case h < 15 || h >= 345:
+		return "red", true
+	case h < 45:
+		if v < 0.6 {
+			return "brown", true
+		}
+		return "orange", true
+	case h < 70:
+		return "yellow", true
+	case h < 165:
+		return "green", true
+	case h < 200:
+		return "cyan", true
+	case h < 260:
+		return "blue", true
+	case h < 290:
+		return "purple", true
+	default:
+		return "pink", true
+	}
+}
+
+func toHsv(r, g, b uint8) (h, s, v float64) {
+	rf, gf, bf := float64(r)/255, float64(g)/255, float64(b)/255
+	max := rf
+	if gf > max {
+		max = gf
+	}
+	if bf > max {
+		max = bf
+	}
+	min := rf
+	if gf < min {
+		min = gf
+	}
+	if bf < min {
+		min = bf
+	}
+	v = max
+	d := max - min
+	if max == 0 || d == 0 {
+		return 0, 0, v
+	}
+	s = d / max
+	switch max {
+	case rf:
+		h = 60 * (gf - bf) / d
+	case gf:
+		h = 60 * ((bf-rf)/d + 2)
+	default:
+		h = 60 * ((rf-gf)/d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
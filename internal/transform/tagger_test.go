@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+)
+
+func encodePng(t *testing.T, width, height int, c color.Color) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tagValues(tags []media.Tag) map[string]bool {
+	values := map[string]bool{}
+	for _, t := range tags {
+		values[t.Value] = true
+	}
+	return values
+}
+
+func TestLocalTagger(t *testing.T) {
+	tagger := NewLocalTagger()
+
+	tags, err := tagger.Tag(context.Background(), encodePng(t, 200, 100, color.NRGBA{R: 220, G: 20, B: 20, A: 255}), media.ImagePng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := tagValues(tags)
+	for _, v := range []string{"landscape", "small", "red"} {
+		if !values[v] {
+			t.Errorf("expected tag %s, got %v", v, tags)
+		}
+	}
+	if values["grayscale"] || values["transparent"] {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	tags, err = tagger.Tag(context.Background(), encodePng(t, 100, 300, color.NRGBA{R: 128, G: 128, B: 128, A: 100}), media.ImagePng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = tagValues(tags)
+	for _, v := range []string{"portrait", "gray", "grayscale", "transparent"} {
+		if !values[v] {
+			t.Errorf("expected tag %s, got %v", v, tags)
+		}
+	}
+
+	if values["faces"] {
+		t.Errorf("unexpected faces in %v", tags)
+	}
+
+	// A skin toned oval on a blue background, the wide picture must not be
+	// squashed before looking for it.
+	for _, width := range []int{200, 400} {
+		img := image.NewNRGBA(image.Rect(0, 0, width, 200))
+		for x := 0; x < width; x++ {
+			for y := 0; y < 200; y++ {
+				dx, dy := float64(x-width/2)/40, float64(y-100)/55
+				if dx*dx+dy*dy <= 1 {
+					img.Set(x, y, color.NRGBA{R: 224, G: 172, B: 105, A: 255})
+				} else {
+					img.Set(x, y, color.NRGBA{R: 30, G: 60, B: 200, A: 255})
+				}
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		tags, err = tagger.Tag(context.Background(), buf.Bytes(), media.ImagePng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tagValues(tags)["faces"] {
+			t.Errorf("expected tag faces in %dx200, got %v", width, tags)
+		}
+	}
+
+	if _, err := tagger.Tag(context.Background(), []byte{}, media.VideoMp4); err == nil {
+		t.Errorf("should not tag videos")
+	}
+}
+
+func TestHttpTagger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Content-Type") != media.ImagePng {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"tags": [{"value": "Dog", "confidence_score": 0.9}]}`))
+	}))
+	defer server.Close()
+
+	tags, err := NewHttpTagger(server.URL, "secret", 0).Tag(context.Background(), []byte("png"), media.ImagePng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Value != "Dog" || tags[0].Provider != HttpTaggerProvider {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	if _, err := NewHttpTagger(server.URL, "wrong", 0).Tag(context.Background(), []byte("png"), media.ImagePng); err == nil {
+		t.Errorf("should fail when the endpoint rejects the request")
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	current := []media.Tag{
+		{Value: "old", ConfidenceScore: 0.5, Provider: LocalTaggerProvider},
+		{Value: "cat", ConfidenceScore: 1, Provider: "user"},
+	}
+	merged := MergeTags(current, LocalTaggerProvider, []media.Tag{
+		{Value: "Red", ConfidenceScore: 0.8},
+		{Value: "red", ConfidenceScore: 0.7},
+	})
+	if len(merged) != 2 || merged[0].Value != "cat" || merged[1].Value != "red" || merged[1].Provider != LocalTaggerProvider {
+		t.Errorf("unexpected tags %v", merged)
+	}
+}
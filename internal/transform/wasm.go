@@ -0,0 +1,300 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+	"github.com/rs/zerolog/log"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+const (
+	defaultWasmMemoryLimitPages = 256 // 16 MiB
+	defaultWasmTimeout          = 5 * time.Second
+	wasmExtension               = ".wasm"
+)
+
+var wasmModuleNameRegex = regexp.MustCompile("^[a-z0-9_]+$")
+
+// WASM transformation modules must export:
+//   - memory
+//   - alloc(size i32) i32, returning a pointer to size free bytes
+//   - transform(in_ptr i32, in_len i32, args_ptr i32, args_len i32) i64,
+//     returning the output pointer in the high 32 bits and its length in the
+//     low 32 bits
+//
+// The input is the media bytes and args the transformation arguments encoded
+// as a JSON object. A fresh instance is used for every transformation.
+type WasmRuntime struct {
+	runtime wazero.Runtime
+	timeout time.Duration
+	dir     string
+}
+
+type WasmRuntimeOptions func(*wasmRuntimeOptions)
+
+type wasmRuntimeOptions struct {
+	memoryLimitPages uint32
+	timeout          time.Duration
+	dir              string
+}
+
+func WithWasmMemoryLimitPages(pages uint32) WasmRuntimeOptions {
+	return func(o *wasmRuntimeOptions) {
+		if pages > 0 {
+			o.memoryLimitPages = pages
+		}
+	}
+}
+
+func WithWasmTimeout(timeout time.Duration) WasmRuntimeOptions {
+	return func(o *wasmRuntimeOptions) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// WithWasmModulesDir sets the directory modules are loaded from and uploaded
+// modules are saved to.
+func WithWasmModulesDir(dir string) WasmRuntimeOptions {
+	return func(o *wasmRuntimeOptions) {
+		o.dir = dir
+	}
+}
+
+func NewWasmRuntime(opts ...WasmRuntimeOptions) *WasmRuntime {
+	o := &wasmRuntimeOptions{
+		memoryLimitPages: defaultWasmMemoryLimitPages,
+		timeout:          defaultWasmTimeout,
+	}
+	for _, optFunc := range opts {
+		optFunc(o)
+	}
+
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(o.memoryLimitPages).
+		WithCloseOnContextDone(true)
+
+	return &WasmRuntime{
+		runtime: wazero.NewRuntimeWithConfig(context.Background(), config),
+		timeout: o.timeout,
+		dir:     o.dir,
+	}
+}
+
+func (r *WasmRuntime) Close() error {
+	return r.runtime.Close(context.Background())
+}
+
+type WasmModule struct {
+	name    string
+	runtime *WasmRuntime
+	// The runtime shares the compilation of identical modules, sum tells
+	// whether closing one closes the other.
+	sum      [sha256.Size]byte
+	compiled wazero.CompiledModule
+
+	// users counts the transformations running the module, a retired module
+	// is closed once the last one is done.
+	mu      sync.Mutex
+	users   int
+	retired bool
+}
+
+func (r *WasmRuntime) Compile(name string, wasm []byte) (*WasmModule, error) {
+	if !wasmModuleNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid wasm module name %s", name)
+	}
+	compiled, err := r.runtime.CompileModule(context.Background(), wasm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile wasm module %s: %w", name, err)
+	}
+
+	exports := compiled.ExportedFunctions()
+	for _, fn := range []string{"alloc", "transform"} {
+		if _, ok := exports[fn]; !ok {
+			compiled.Close(context.Background())
+			return nil, fmt.Errorf("wasm module %s must export %s", name, fn)
+		}
+	}
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		compiled.Close(context.Background())
+		return nil, fmt.Errorf("wasm module %s must export its memory", name)
+	}
+
+	return &WasmModule{
+		name:     name,
+		runtime:  r,
+		sum:      sha256.Sum256(wasm),
+		compiled: compiled,
+	}, nil
+}
+
+func (m *WasmModule) Name() string {
+	return m.name
+}
+
+func (m *WasmModule) Close() error {
+	return m.compiled.Close(context.Background())
+}
+
+func (m *WasmModule) acquire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users++
+}
+
+func (m *WasmModule) release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users--
+	if m.retired && m.users == 0 {
+		m.Close()
+	}
+}
+
+// retire closes the module once no transformation runs it anymore.
+func (m *WasmModule) retire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retired = true
+	if m.users == 0 {
+		m.Close()
+	}
+}
+
+func (m *WasmModule) Transform(input []byte, args map[string]string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.runtime.timeout)
+	defer cancel()
+
+	mod, err := m.runtime.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, fmt.Errorf("unable to instantiate wasm module %s: %w", m.name, err)
+	}
+	defer mod.Close(context.Background())
+
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	inPtr, err := writeWasmBytes(ctx, mod, input)
+	if err != nil {
+		return nil, err
+	}
+	argsPtr, err := writeWasmBytes(ctx, mod, argsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := mod.ExportedFunction("transform").Call(ctx, uint64(inPtr), uint64(len(input)), uint64(argsPtr), uint64(len(argsJSON)))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("wasm module %s exceeded %v", m.name, m.runtime.timeout)
+		}
+		return nil, fmt.Errorf("wasm module %s failed: %w", m.name, err)
+	}
+
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	out, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("wasm module %s returned an out of range output", m.name)
+	}
+	return bytes.Clone(out), nil
+}
+
+func writeWasmBytes(ctx context.Context, mod api.Module, b []byte) (uint32, error) {
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, b) {
+		return 0, errors.New("wasm module allocated an out of range buffer")
+	}
+	return ptr, nil
+}
+
+// LoadModules compiles every module of the modules directory, named after
+// their file name. Modules that don't compile are skipped.
+func (r *WasmRuntime) LoadModules() ([]*WasmModule, error) {
+	if r.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	modules := []*WasmModule{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != wasmExtension {
+			continue
+		}
+		wasm, err := os.ReadFile(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, err := r.Compile(strings.TrimSuffix(e.Name(), wasmExtension), wasm)
+		if err != nil {
+			log.Warn().Err(err).Msgf("skipping wasm module %s", e.Name())
+			continue
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// SaveModule writes a compiled module to the modules directory so that it is
+// loaded again on restart.
+func (r *WasmRuntime) SaveModule(m *WasmModule, wasm []byte) error {
+	if r.dir == "" {
+		return errors.New("wasm modules directory is not configured")
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, m.name+wasmExtension), wasm, 0644)
+}
+
+// CanSaveModules tells whether uploaded modules can be persisted.
+func (r *WasmRuntime) CanSaveModules() bool {
+	return r.dir != ""
+}
+
+// WasmTransformer runs the module registered under its name when executed,
+// the steps built before the module was replaced run the new one.
+type WasmTransformer struct {
+	builder *Builder
+	name    string
+	args    map[string]string
+}
+
+func (t *WasmTransformer) Execute(ctx pipeline.PipelineCtx) (pipeline.PipelineCtx, error) {
+	m, err := t.builder.acquireWasmModule(t.name)
+	if err != nil {
+		return ctx, err
+	}
+	defer m.release()
+	out, err := m.Transform(ctx.Buffer.Bytes(), t.args)
+	if err != nil {
+		return ctx, err
+	}
+	ctx.Buffer = pipeline.NewBuffer(bytes.NewReader(out))
+	return ctx, nil
+}
@@ -0,0 +1,182 @@
+package transform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/pipeline"
+)
+
+func wasmSection(id byte, content ...byte) []byte {
+	return append([]byte{id, byte(len(content))}, content...)
+}
+
+// buildWasmModule assembles a module exporting its memory, a bump allocator
+// and the given transform function body.
+func buildWasmModule(memoryPages byte, transformBody []byte) []byte {
+	allocBody := []byte{0x00, 0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6a, 0x24, 0x00, 0x0b}
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, wasmSection(0x01,
+		0x02,
+		0x60, 0x01, 0x7f, 0x01, 0x7f,
+		0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7e,
+	)...)
+	module = append(module, wasmSection(0x03, 0x02, 0x00, 0x01)...)
+	module = append(module, wasmSection(0x05, 0x01, 0x00, memoryPages)...)
+	module = append(module, wasmSection(0x06, 0x01, 0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b)...)
+
+	exports := []byte{0x03}
+	for _, e := range []struct {
+		name string
+		kind byte
+		idx  byte
+	}{{"memory", 0x02, 0}, {"alloc", 0x00, 0}, {"transform", 0x00, 1}} {
+		exports = append(exports, byte(len(e.name)))
+		exports = append(exports, e.name...)
+		exports = append(exports, e.kind, e.idx)
+	}
+	module = append(module, wasmSection(0x07, exports...)...)
+
+	code := []byte{0x02, byte(len(allocBody))}
+	code = append(code, allocBody...)
+	code = append(code, byte(len(transformBody)))
+	code = append(code, transformBody...)
+	return append(module, wasmSection(0x0a, code...)...)
+}
+
+var (
+	// Returns its input: (in_ptr << 32) | in_len.
+	identityBody = []byte{0x00, 0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b}
+	// Loops forever.
+	loopBody = []byte{0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x00, 0x0b}
+)
+
+func TestWasmTransformation(t *testing.T) {
+	r := NewWasmRuntime()
+	defer r.Close()
+
+	m, err := r.Compile("identity", buildWasmModule(1, identityBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder(nil)
+	if err := b.RegisterWasmModule(m); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := b.Build([]Transformation{{Name: "c_identity"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := steps[0].Execute(pipeline.PipelineCtx{
+		Buffer: pipeline.NewBuffer(bytes.NewReader([]byte("mindia"))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(ctx.Buffer.Bytes()); got != "mindia" {
+		t.Errorf("got %s, wanted mindia", got)
+	}
+}
+
+func TestWasmLimits(t *testing.T) {
+	r := NewWasmRuntime(WithWasmMemoryLimitPages(2), WithWasmTimeout(100*time.Millisecond))
+	defer r.Close()
+
+	if _, err := r.Compile("greedy", buildWasmModule(3, identityBody)); err == nil {
+		t.Errorf("should have rejected a module exceeding the memory limit")
+	}
+
+	m, err := r.Compile("loop", buildWasmModule(1, loopBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Transform([]byte("mindia"), nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeded") {
+		t.Errorf("got %v, wanted a timeout", err)
+	}
+}
+
+func TestWasmModuleRegistration(t *testing.T) {
+	r := NewWasmRuntime()
+	defer r.Close()
+	b := NewBuilder(nil)
+
+	scale, err := r.Compile("scale", buildWasmModule(1, identityBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterWasmModule(scale); err == nil {
+		t.Errorf("should not override a built-in transformation")
+	}
+
+	first, _ := r.Compile("identity", buildWasmModule(1, identityBody))
+	same, _ := r.Compile("identity", buildWasmModule(1, identityBody))
+	second, _ := r.Compile("identity", buildWasmModule(2, identityBody))
+	for _, m := range []*WasmModule{first, same, second} {
+		if err := b.RegisterWasmModule(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := first.Transform([]byte("mindia"), nil); err == nil {
+		t.Errorf("replaced module should be closed")
+	}
+	if _, err := second.Transform([]byte("mindia"), nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWasmModuleReplacedWhileRunning(t *testing.T) {
+	r := NewWasmRuntime(WithWasmTimeout(200 * time.Millisecond))
+	defer r.Close()
+	b := NewBuilder(nil)
+
+	loop, _ := r.Compile("hot", buildWasmModule(1, loopBody))
+	identity, _ := r.Compile("hot", buildWasmModule(1, identityBody))
+	if err := b.RegisterWasmModule(loop); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := b.Build([]Transformation{{Name: "c_hot"}, {Name: "c_hot"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := func() pipeline.PipelineCtx {
+		return pipeline.PipelineCtx{Buffer: pipeline.NewBuffer(bytes.NewReader([]byte("mindia")))}
+	}
+
+	running := make(chan error)
+	go func() {
+		_, err := steps[0].Execute(input())
+		running <- err
+	}()
+	for {
+		loop.mu.Lock()
+		users := loop.users
+		loop.mu.Unlock()
+		if users == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := b.RegisterWasmModule(identity); err != nil {
+		t.Fatal(err)
+	}
+
+	// The running transformation keeps its module until it is done.
+	if err := <-running; err == nil || !strings.Contains(err.Error(), "exceeded") {
+		t.Errorf("got %v, wanted the running module to time out", err)
+	}
+	if _, err := loop.Transform([]byte("mindia"), nil); err == nil {
+		t.Errorf("replaced module should be closed once unused")
+	}
+	// A step built before the replacement runs the new module.
+	ctx, err := steps[1].Execute(input())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(ctx.Buffer.Bytes()); got != "mindia" {
+		t.Errorf("got %s, wanted mindia", got)
+	}
+}
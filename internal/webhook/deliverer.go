@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxDeliveryAttempts = 5
+	deliveryTimeout     = 10 * time.Second
+	// deliveryRetryDelay is the delay before the first retry, it doubles on
+	// each attempt.
+	deliveryRetryDelay = 30 * time.Second
+)
+
+// Deliverer executes the delivery tasks enqueued by the Dispatcher. A failed
+// delivery is handed back to the scheduler, with an exponential backoff, until
+// maxDeliveryAttempts is reached.
+type Deliverer struct {
+	webhookStorage Storer
+	client         *http.Client
+}
+
+func NewDeliverer(webhookStorage Storer) Deliverer {
+	return Deliverer{
+		webhookStorage: webhookStorage,
+		client:         &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+func (d *Deliverer) Execute(t *scheduler.Task) (*scheduler.Task, error) {
+	var details deliveryDetails
+	if err := t.DecodeDetails(&details); err != nil {
+		return nil, err
+	}
+	w, err := d.webhookStorage.Get(details.WebhookId)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := Delivery{
+		Id:          uuid.New().String(),
+		WebhookId:   w.Id,
+		EventId:     details.Event.Id,
+		EventType:   details.Event.Type,
+		Attempt:     details.Attempt,
+		DeliveredAt: time.Now(),
+	}
+	err = d.send(w, details.Event, &delivery)
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		delivery.Success = true
+	}
+	if err := d.webhookStorage.SaveDelivery(delivery); err != nil {
+		log.Warn().Err(err).Msgf("unable to save delivery of webhook %s", w.Id)
+	}
+
+	if err == nil {
+		return nil, nil
+	}
+	if details.Attempt >= maxDeliveryAttempts {
+		return nil, fmt.Errorf("webhook %s delivery failed after %d attempts: %w", w.Id, details.Attempt, err)
+	}
+	t.ContinueAfter = deliveryRetryDelay << (details.Attempt - 1)
+	details.Attempt++
+	t.Details = details
+	return t, nil
+}
+
+func (d *Deliverer) send(w *Webhook, event event.Event, delivery *Delivery) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.Url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Mindia-Event", event.Type)
+	req.Header.Set("X-Mindia-Delivery", delivery.Id)
+	if w.Secret != "" {
+		req.Header.Set("X-Mindia-Signature", Sign(w.Secret, payload))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	delivery.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return nil
+}
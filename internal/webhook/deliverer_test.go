@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+)
+
+type memoryStorer struct {
+	webhooks   WebhookMap
+	deliveries []Delivery
+}
+
+func (s *memoryStorer) GetAll() (WebhookMap, error) { return s.webhooks, nil }
+func (s *memoryStorer) Get(id string) (*Webhook, error) {
+	w := s.webhooks[id]
+	return &w, nil
+}
+func (s *memoryStorer) Save(w Webhook) error   { s.webhooks[w.Id] = w; return nil }
+func (s *memoryStorer) Delete(id string) error { delete(s.webhooks, id); return nil }
+func (s *memoryStorer) SaveDelivery(d Delivery) error {
+	s.deliveries = append(s.deliveries, d)
+	return nil
+}
+func (s *memoryStorer) GetDeliveries(id string) ([]Delivery, error) { return s.deliveries, nil }
+
+func TestDelivererSignsPayload(t *testing.T) {
+	var signature string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Mindia-Signature")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	storer := &memoryStorer{webhooks: WebhookMap{"1": {Id: "1", Url: srv.URL, Secret: "secret"}}}
+	d := NewDeliverer(storer)
+	task := scheduler.NewTask(DeliveryTaskName, deliveryDetails{WebhookId: "1", Event: event.New(event.MediaUploaded, nil), Attempt: 1})
+
+	next, err := d.Execute(&task)
+	if err != nil || next != nil {
+		t.Fatalf("got (%v, %v), wanted a successful delivery", next, err)
+	}
+	if want := Sign("secret", body); signature != want {
+		t.Errorf("got signature %s, wanted %s", signature, want)
+	}
+	if len(storer.deliveries) != 1 || !storer.deliveries[0].Success {
+		t.Errorf("should have logged a successful delivery")
+	}
+}
+
+func TestDelivererRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	storer := &memoryStorer{webhooks: WebhookMap{"1": {Id: "1", Url: srv.URL}}}
+	d := NewDeliverer(storer)
+	task := scheduler.NewTask(DeliveryTaskName, deliveryDetails{WebhookId: "1", Event: event.New(event.MediaDeleted, nil), Attempt: 1})
+
+	for attempt := 1; attempt < maxDeliveryAttempts; attempt++ {
+		next, err := d.Execute(&task)
+		if err != nil || next == nil {
+			t.Fatalf("attempt %d: should have been retried, got %v", attempt, err)
+		}
+		if want := deliveryRetryDelay << (attempt - 1); next.ContinueAfter != want {
+			t.Errorf("attempt %d: got a retry after %v, wanted %v", attempt, next.ContinueAfter, want)
+		}
+		task = *next
+	}
+	if _, err := d.Execute(&task); err == nil {
+		t.Errorf("should have given up after %d attempts", maxDeliveryAttempts)
+	}
+	if got := len(storer.deliveries); got != maxDeliveryAttempts {
+		t.Errorf("got %d logged deliveries, wanted %d", got, maxDeliveryAttempts)
+	}
+}
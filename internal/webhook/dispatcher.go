@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+const DeliveryTaskName = "webhook_delivery"
+
+type deliveryDetails struct {
+	WebhookId string      `json:"webhook_id"`
+	Event     event.Event `json:"event"`
+	Attempt   int         `json:"attempt"`
+}
+
+// Dispatcher subscribes to the event bus and turns events into one delivery
+// task per subscribed webhook so that the HTTP calls are made, and retried, by
+// the task scheduler.
+type Dispatcher struct {
+	webhookStorage Storer
+	taskStorage    scheduler.Storer
+}
+
+func NewDispatcher(webhookStorage Storer, taskStorage scheduler.Storer) *Dispatcher {
+	return &Dispatcher{
+		webhookStorage,
+		taskStorage,
+	}
+}
+
+func (d *Dispatcher) HandleEvent(e event.Event) {
+	if t, ok := e.Data.(*scheduler.Task); ok && t.Name == DeliveryTaskName {
+		return
+	}
+
+	webhooks, err := d.webhookStorage.GetAll()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to get webhooks")
+		return
+	}
+
+	for _, w := range webhooks {
+		if !w.Accepts(e.Type) {
+			continue
+		}
+		t := scheduler.NewTask(DeliveryTaskName, deliveryDetails{
+			WebhookId: w.Id,
+			Event:     e,
+			Attempt:   1,
+		})
+		t.Status = scheduler.Enqueued
+		t.EnqueuedAt = time.Now()
+		if err := d.taskStorage.EnqueueTask(&t); err != nil {
+			log.Warn().Err(err).Msgf("unable to enqueue delivery of %s to webhook %s", e.Type, w.Id)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package webhook
+
+// MaxDeliveries is the number of deliveries kept in the log of each webhook.
+const MaxDeliveries = 100
+
+type Storer interface {
+	GetAll() (WebhookMap, error)
+	Get(id string) (*Webhook, error)
+	Save(webhook Webhook) error
+	Delete(id string) error
+	SaveDelivery(delivery Delivery) error
+	GetDeliveries(webhookId string) ([]Delivery, error)
+}
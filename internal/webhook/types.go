@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/jeremybastin1207/mindia-core/internal/event"
+	"golang.org/x/exp/slices"
+)
+
+type WebhookMap = map[string]Webhook
+
+type Webhook struct {
+	Id     string `json:"id" yaml:"id"`
+	Url    string `json:"url" yaml:"url"`
+	Secret string `json:"secret,omitempty" yaml:"secret"`
+	// Events filters the event types sent to the webhook, all events are
+	// sent when empty.
+	Events    []event.Type `json:"events,omitempty" yaml:"events"`
+	CreatedAt time.Time    `json:"created_at,omitempty" yaml:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at,omitempty" yaml:"updated_at"`
+}
+
+func (w *Webhook) Accepts(t event.Type) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, t)
+}
+
+type Delivery struct {
+	Id          string     `json:"id" yaml:"id"`
+	WebhookId   string     `json:"webhook_id" yaml:"webhook_id"`
+	EventId     string     `json:"event_id" yaml:"event_id"`
+	EventType   event.Type `json:"event_type" yaml:"event_type"`
+	Attempt     int        `json:"attempt" yaml:"attempt"`
+	StatusCode  int        `json:"status_code,omitempty" yaml:"status_code"`
+	Error       string     `json:"error,omitempty" yaml:"error"`
+	Success     bool       `json:"success" yaml:"success"`
+	DeliveredAt time.Time  `json:"delivered_at" yaml:"delivered_at"`
+}
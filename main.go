@@ -1,28 +1,33 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/go-playground/validator/v10"
-	redigo "github.com/gomodule/redigo/redis"
-	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/cmd"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/nats"
 	"github.com/jeremybastin1207/mindia-core/internal/adapter/prometheus"
 	"github.com/jeremybastin1207/mindia-core/internal/adapter/redis"
-	"github.com/jeremybastin1207/mindia-core/internal/adapter/s3"
 	"github.com/jeremybastin1207/mindia-core/internal/api"
-	"github.com/jeremybastin1207/mindia-core/internal/apikey"
 	"github.com/jeremybastin1207/mindia-core/internal/config"
 	mindiaerr "github.com/jeremybastin1207/mindia-core/internal/error"
+	"github.com/jeremybastin1207/mindia-core/internal/event"
 	"github.com/jeremybastin1207/mindia-core/internal/logging"
-	"github.com/jeremybastin1207/mindia-core/internal/media"
 	"github.com/jeremybastin1207/mindia-core/internal/plugin"
 	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
 	"github.com/jeremybastin1207/mindia-core/internal/task"
 	"github.com/jeremybastin1207/mindia-core/internal/transform"
+	"github.com/jeremybastin1207/mindia-core/internal/webhook"
 	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
 )
 
+const pluginHealthCheckInterval = 30 * time.Second
+
 func main() {
 	if _, err := os.Stat(".env"); err == nil {
 		err := godotenv.Load(".env")
@@ -32,16 +37,8 @@ func main() {
 	}
 
 	var (
-		logger                     = logging.New()
-		s3Client                   *s3.S3
-		redisPool                  *redigo.Pool
-		fileStorage                media.FileStorer
-		cacheStorage               media.FileStorer
-		mediaStorage               media.Storer
-		namedTransformationStorage transform.Storer
-		apikeyStorage              apikey.Storer
-		taskStorage                scheduler.Storer
-		analyticsRecorder          = prometheus.NewPrometheusRecorder()
+		logger            = logging.New()
+		analyticsRecorder = prometheus.NewPrometheusRecorder()
 	)
 
 	configStorage := config.NewFilesystemStorage()
@@ -57,98 +54,266 @@ func main() {
 		mindiaerr.ExitErrorf(validationErrors.Error())
 	}
 
-	if c.Adapters.S3 != nil {
-		s3Client = s3.NewS3(s3.S3Config{
-			AccessKeyId:     c.Adapters.S3.AccessKeyId,
-			SecretAccessKey: c.Adapters.S3.SecretAccessKey,
-			Endpoint:        c.Adapters.S3.Endpoint,
-			Region:          c.Adapters.S3.Region,
-		})
+	// Arguments run a command of the cli instead of the server.
+	if len(os.Args) > 1 {
+		runCli(c)
+		return
 	}
-	if c.Adapters.Redis != nil {
-		redisAddr := fmt.Sprintf("%s:%v", c.Adapters.Redis.Host, c.Adapters.Redis.Port)
-		redisPool = redis.NewPool(redisAddr)
+
+	st, err := newStorages(c)
+	if err != nil {
+		mindiaerr.ExitErrorf(err.Error())
 	}
+	defer st.close()
+	if err := st.requireAll(); err != nil {
+		mindiaerr.ExitErrorf(err.Error())
+	}
+	var (
+		redisPool                  = st.redisPool
+		fileStorage                = st.fileStorage
+		cacheStorage               = st.cacheStorage
+		mediaStorage               = st.mediaStorage
+		namedTransformationStorage = st.namedTransformationStorage
+		apikeyStorage              = st.apikeyStorage
+		taskStorage                = st.taskStorage
+		webhookStorage             = st.webhookStorage
+		folderStorage              = st.folderStorage
+	)
 
-	if c.Storage.MediaStorage.FileStorage.FilesystemStorageConfig != nil {
-		fileStorage = filesystem.NewFileStorage(filesystem.FileStorageConfig{
-			MountDir: c.Storage.MediaStorage.FileStorage.FilesystemStorageConfig.MountDir,
-		})
-	} else if c.Storage.MediaStorage.FileStorage.S3StorageConfig != nil {
-		fileStorage = s3.NewFileStorage(s3.FileStorageConfig{
-			S3:     s3Client,
-			Bucket: c.Storage.MediaStorage.FileStorage.S3StorageConfig.Bucket,
-		})
-	} else {
-		mindiaerr.ExitErrorf("file storage config must be provided")
+	storageMigrator, migrationTarget, err := newStorageMigrator(st)
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to build the migration target storages, %v", err)
 	}
+	if migrationTarget != nil {
+		defer migrationTarget.close()
+	}
+	consistencyChecker := task.NewConsistencyChecker(fileStorage, cacheStorage, mediaStorage, st.journalStorage)
 
-	if c.Storage.MediaStorage.CacheStorage.FilesystemStorageConfig != nil {
-		cacheStorage = filesystem.NewFileStorage(filesystem.FileStorageConfig{
-			MountDir: c.Storage.MediaStorage.CacheStorage.FilesystemStorageConfig.MountDir,
-		})
-	} else if c.Storage.MediaStorage.CacheStorage.S3StorageConfig != nil {
-		cacheStorage = s3.NewFileStorage(s3.FileStorageConfig{
-			S3:     s3Client,
-			Bucket: c.Storage.MediaStorage.CacheStorage.S3StorageConfig.Bucket,
-		})
-	} else {
-		mindiaerr.ExitErrorf("cache storage config must be provided")
+	eventSinks := []event.Sink{}
+	if c.Events.Redis != nil {
+		if redisPool == nil {
+			mindiaerr.ExitErrorf("redis adapter config must be provided to publish events to redis")
+		}
+		eventSinks = append(eventSinks, redis.NewEventSink(redisPool, c.Events.Redis.Channel))
+	}
+	if c.Events.Nats != nil {
+		eventSinks = append(eventSinks, nats.NewEventSink(c.Events.Nats.Url, c.Events.Nats.Subject))
+	}
+	// The sinks are closed once the scheduler drained, after the last events.
+	for _, sink := range eventSinks {
+		if closer, ok := sink.(interface{ Close() }); ok {
+			defer closer.Close()
+		}
 	}
+	eventBus := event.NewBus(eventSinks...)
 
-	if c.Storage.MediaStorage.MetadataStorage.Redis != nil {
-		mediaStorage = redis.NewMediaStorage(redisPool)
-	} else {
-		mindiaerr.ExitErrorf("media storage config must be provided")
+	schedulerOpts := []scheduler.SchedulerOptions{
+		scheduler.WithWorkers(c.Scheduler.Workers),
 	}
+	for taskName, limit := range c.Scheduler.ConcurrencyLimits {
+		schedulerOpts = append(schedulerOpts, scheduler.WithConcurrencyLimit(taskName, limit))
+	}
+	taskScheduler := scheduler.NewTaskScheduler(taskStorage, logger, schedulerOpts...)
+
+	webhookDispatcher := webhook.NewDispatcher(webhookStorage, taskStorage)
+	webhookDeliverer := webhook.NewDeliverer(webhookStorage)
+	taskScheduler.RegisterListener(webhook.DeliveryTaskName, &webhookDeliverer)
+	eventBus.Subscribe(event.AllEvents, webhookDispatcher.HandleEvent)
+	taskScheduler.RegisterFinishedHook(func(t *scheduler.Task) {
+		eventBus.Publish(event.TaskFinished, t)
+	})
 
-	if c.Storage.NamedTransforationStorage.Filesystem != nil {
-		namedTransformationStorage = filesystem.NewNamedTransformationStorage()
-	} else if c.Storage.NamedTransforationStorage.Redis != nil {
-		namedTransformationStorage = redis.NewNamedTransformationStorage(redisPool)
-	} else {
-		mindiaerr.ExitErrorf("named transformation storage config must be provided")
+	transformationsBuilder := transform.NewBuilder(fileStorage)
+
+	var tagger transform.Tagger
+	switch c.Tagging.Provider {
+	case transform.LocalTaggerProvider:
+		tagger = transform.NewLocalTagger()
+	case transform.GoogleTaggerProvider:
+		googleTagger := transform.NewGoogleTagger()
+		defer googleTagger.Close()
+		tagger = googleTagger
+	case transform.HttpTaggerProvider:
+		if c.Tagging.Http == nil {
+			mindiaerr.ExitErrorf("http tagger config must be provided")
+		}
+		tagger = transform.NewHttpTagger(c.Tagging.Http.Url, c.Tagging.Http.Token, c.Tagging.Http.Timeout)
+	default:
+		mindiaerr.ExitErrorf("tagging provider %s not supported", c.Tagging.Provider)
 	}
 
-	if c.Storage.ApiKeyStorage.Filesystem != nil {
-		apikeyStorage = filesystem.NewApiKeyStorage()
-	} else if c.Storage.ApiKeyStorage.Redis != nil {
-		apikeyStorage = redis.NewApiKeyStorage(redisPool)
-	} else {
-		mindiaerr.ExitErrorf("apikey storage config must be provided")
+	wasmRuntime := transform.NewWasmRuntime(
+		transform.WithWasmModulesDir(c.Wasm.ModulesDir),
+		transform.WithWasmMemoryLimitPages(c.Wasm.MemoryLimitPages),
+		transform.WithWasmTimeout(c.Wasm.Timeout),
+	)
+	defer wasmRuntime.Close()
+	wasmModules, err := wasmRuntime.LoadModules()
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to load wasm modules, %v", err)
+	}
+	for _, m := range wasmModules {
+		if err := transformationsBuilder.RegisterWasmModule(m); err != nil {
+			log.Warn().Err(err).Msgf("skipping wasm module %s", m.Name())
+			m.Close()
+		}
 	}
 
-	if c.Storage.TaskStorage.Filesystem != nil {
-		// TODO
-	} else if c.Storage.TaskStorage.Redis != nil {
-		taskStorage = redis.NewTaskStorage(redisPool)
+	pluginManager := plugin.NewPluginManager(fileStorage, cacheStorage, mediaStorage, taskStorage, eventBus, transformationsBuilder)
+	// Plugins are enabled by name so that their hooks run in a stable order.
+	pluginNames := []string{}
+	for name := range c.Plugins {
+		pluginNames = append(pluginNames, name)
+	}
+	sort.Strings(pluginNames)
+	for _, name := range pluginNames {
+		pluginConfig := c.Plugins[name]
+		if !pluginConfig.Enabled {
+			continue
+		}
+		if err := pluginManager.Enable(name, pluginConfig.Options); err != nil {
+			mindiaerr.ExitErrorf(err.Error())
+		}
+	}
+	for _, p := range pluginManager.GetPlugins() {
+		taskScheduler.RegisterListener(p.Name(), p)
 	}
+	taskScheduler.RegisterListener(plugin.HookTaskName, &pluginManager)
+	pluginsCtx, stopPlugins := context.WithCancel(context.Background())
+	go pluginManager.MonitorHealth(pluginsCtx, pluginHealthCheckInterval)
 
-	taskScheduler := scheduler.NewTaskScheduler(taskStorage, logger)
-	go taskScheduler.ProcessTasks()
+	storageUsageCollector := task.NewStorageUsageCollector(fileStorage, cacheStorage, analyticsRecorder)
+	taskScheduler.RegisterListener(task.StorageUsageTaskName, &storageUsageCollector)
+	if storageMigrator != nil {
+		taskScheduler.RegisterListener(task.MigrateStorageTaskName, storageMigrator)
+	}
+	taskScheduler.RegisterListener(task.FsckTaskName, &consistencyChecker)
+	cacheEvictor := task.NewCacheEvictor(cacheStorage, mediaStorage)
+	taskScheduler.RegisterListener(task.EvictCacheTaskName, &cacheEvictor)
 
-	pluginManager := plugin.NewPluginManager(fileStorage, cacheStorage, mediaStorage, taskStorage)
+	operationJournal := task.NewJournal(st.journalStorage)
+	journalCtx, stopJournal := context.WithCancel(context.Background())
+	go operationJournal.KeepAlive(journalCtx)
+	taskScheduler.RegisterListener(task.ResumeOperationsTaskName, operationJournal)
+	uploadMedia := task.NewUploadMediaTask(fileStorage, cacheStorage, mediaStorage, namedTransformationStorage, transformationsBuilder, &pluginManager, eventBus, folderStorage, operationJournal)
+	versionMedia := task.NewVersionMediaTask(fileStorage, cacheStorage, mediaStorage, folderStorage, &uploadMedia, eventBus, operationJournal)
+	copyMedia := task.NewCopyMediaTask(fileStorage, cacheStorage, mediaStorage, &uploadMedia, taskStorage, eventBus, operationJournal)
+	taskScheduler.RegisterListener(task.CopyFolderTaskName, &copyMedia)
+	moveMedia := task.NewMoveMediaTask(fileStorage, cacheStorage, mediaStorage, eventBus, operationJournal)
+	deleteMedia := task.NewDeleteMediaTask(fileStorage, cacheStorage, mediaStorage, analyticsRecorder, &pluginManager, eventBus, operationJournal)
+	mediaExpirer := task.NewMediaExpirer(mediaStorage, &deleteMedia)
+	taskScheduler.RegisterListener(task.ExpireUploadsTaskName, &mediaExpirer)
+	folderOperator := task.NewFolderOperator(mediaStorage, folderStorage, taskStorage, &moveMedia, &deleteMedia)
+	taskScheduler.RegisterListener(task.MoveFolderTaskName, &folderOperator)
+	taskScheduler.RegisterListener(task.DeleteFolderTaskName, &folderOperator)
+	downloadMedia := task.NewDownloadMediaTask(fileStorage, cacheStorage, mediaStorage, namedTransformationStorage, transformationsBuilder, analyticsRecorder, &pluginManager, eventBus)
+	collectionOperator := task.NewCollectionOperator(st.collectionStorage, mediaStorage, &downloadMedia)
+	eventBus.Subscribe(event.MediaDeleted, collectionOperator.HandleEvent)
+	eventBus.Subscribe(event.MediaMoved, collectionOperator.HandleEvent)
 
-	colorizePlugin := plugin.NewColorizePlugin(&pluginManager)
-	pluginManager.RegisterPlugin(&colorizePlugin)
-	taskScheduler.RegisterListener(colorizePlugin.Name(), &colorizePlugin)
+	for name, schedule := range c.Scheduler.RecurringJobs {
+		if schedule == "" {
+			continue
+		}
+		job := scheduler.RecurringJob{
+			Name:     name,
+			Schedule: schedule,
+			TaskName: name,
+		}
+		if name == task.OrphanCleanupJobName {
+			job.TaskName = task.FsckTaskName
+			job.Details = task.OrphanCleanupOptions
+		}
+		if err := taskScheduler.RegisterRecurringJob(job); err != nil {
+			mindiaerr.ExitErrorf(err.Error())
+		}
+	}
 
 	tasks := api.Tasks{
-		ClearCache:                  task.NewClearCacheTask(cacheStorage, analyticsRecorder),
+		ClearCache:                  task.NewClearCacheTask(cacheStorage, analyticsRecorder, eventBus),
 		NamedTransformationOperator: task.NewNamedTransformationOperator(namedTransformationStorage),
 		ApiKeyOperator:              task.NewApiKeyOperator(apikeyStorage),
+		WebhookOperator:             task.NewWebhookOperator(webhookStorage),
+		FolderSettingsOperator:      task.NewFolderSettingsOperator(folderStorage),
+		FolderOperator:              folderOperator,
+		WasmModuleOperator:          task.NewWasmModuleOperator(wasmRuntime, transformationsBuilder),
 		AnalyticsOperator:           task.NewAnalyticsOperator(fileStorage, cacheStorage),
 		TaskOperator:                task.NewTaskOperator(taskStorage),
 		GetMedia:                    task.NewGetMediaTask(mediaStorage, analyticsRecorder),
-		DownloadMedia:               task.NewDownloadMediaTask(fileStorage, cacheStorage, mediaStorage, namedTransformationStorage, analyticsRecorder),
-		UploadMedia:                 task.NewUploadMediaTask(fileStorage, cacheStorage, mediaStorage, namedTransformationStorage),
-		DeleteMedia:                 task.NewDeleteMediaTask(fileStorage, cacheStorage, mediaStorage, analyticsRecorder),
-		MoveMedia:                   task.NewMoveMediaTask(fileStorage, cacheStorage, mediaStorage),
-		CopyMedia:                   task.NewCopyMediaTask(fileStorage, cacheStorage, mediaStorage),
-		TagMedia:                    task.NewTagMediaTask(fileStorage, mediaStorage),
+		DownloadMedia:               downloadMedia,
+		UploadMedia:                 uploadMedia,
+		UpdateMedia:                 task.NewUpdateMediaTask(mediaStorage, folderStorage),
+		DeleteMedia:                 deleteMedia,
+		MoveMedia:                   moveMedia,
+		CopyMedia:                   copyMedia,
+		VersionMedia:                versionMedia,
+		CollectionOperator:          collectionOperator,
+		TagMedia:                    task.NewTagMediaTask(fileStorage, mediaStorage, tagger),
 		ColorizeMedia:               task.NewColorizeMediaTask(&pluginManager),
 	}
+	if err := operationJournal.Resume(); err != nil {
+		logger.Error("unable to resume the journal operations, " + err.Error())
+	}
+	taskScheduler.Start()
 
-	server := api.NewApiServer(c.MasterKey, c.Server.HttpApiConfig.Host, c.Server.HttpApiConfig.Port, apikeyStorage, logger, tasks)
+	server := api.NewApiServer(c.MasterKey, c.Server.HttpApiConfig.Host, c.Server.HttpApiConfig.Port, apikeyStorage, logger, tasks, pluginManager.Routes())
 	server.Serve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Scheduler.DrainTimeout)
+	defer cancel()
+	if err := taskScheduler.Shutdown(ctx); err != nil {
+		logger.Error(err.Error())
+	}
+	stopJournal()
+	stopPlugins()
+	pluginManager.Close()
+}
+
+// runCli runs a command of the cli. Only the storages the commands read are
+// built, the task storage only once a task is enqueued and the filesystem
+// journal read only, so that the cli doesn't wait on the databases held by a
+// running server.
+func runCli(c *config.Config) {
+	cliConfig := *c
+	cliConfig.Storage.TaskStorage = config.TaskStorageConfig{}
+	cliConfig.Storage.WebhookStorage = config.WebhookStorageConfig{}
+	cliConfig.Storage.FolderStorage = config.FolderStorageConfig{}
+	cliConfig.Storage.JournalStorage.Filesystem = nil
+	cliConfig.Storage.CollectionStorage = config.CollectionStorageConfig{}
+
+	st, err := newStorages(&cliConfig)
+	if err != nil {
+		mindiaerr.ExitErrorf(err.Error())
+	}
+	defer st.close()
+	if err := st.requireMedias(); err != nil {
+		mindiaerr.ExitErrorf(err.Error())
+	}
+
+	storageMigrator, migrationTarget, err := newStorageMigrator(st)
+	if err != nil {
+		mindiaerr.ExitErrorf("unable to build the migration target storages, %v", err)
+	}
+	if migrationTarget != nil {
+		defer migrationTarget.close()
+	}
+	if c.Storage.JournalStorage.Filesystem != nil {
+		st.openJournalReadOnly(*c.Storage.JournalStorage.Filesystem)
+	}
+	consistencyChecker := task.NewConsistencyChecker(st.fileStorage, st.cacheStorage, st.mediaStorage, st.journalStorage)
+	openTaskStorage := func() (scheduler.Storer, error) {
+		if st.taskStorage == nil {
+			st.openTaskStorage(c.Storage.TaskStorage)
+		}
+		if st.taskStorage == nil {
+			return nil, errors.New("task storage config must be provided")
+		}
+		return st.taskStorage, nil
+	}
+
+	cmd.Execute(
+		cmd.NewIndexCommand(st.mediaStorage),
+		cmd.NewMigrateCommand(storageMigrator, openTaskStorage),
+		cmd.NewFsckCommand(&consistencyChecker, openTaskStorage),
+	)
 }
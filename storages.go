@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/filesystem"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/postgres"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/redis"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/s3"
+	"github.com/jeremybastin1207/mindia-core/internal/adapter/sqlite"
+	"github.com/jeremybastin1207/mindia-core/internal/apikey"
+	"github.com/jeremybastin1207/mindia-core/internal/collection"
+	"github.com/jeremybastin1207/mindia-core/internal/config"
+	"github.com/jeremybastin1207/mindia-core/internal/folder"
+	"github.com/jeremybastin1207/mindia-core/internal/journal"
+	"github.com/jeremybastin1207/mindia-core/internal/media"
+	"github.com/jeremybastin1207/mindia-core/internal/scheduler"
+	"github.com/jeremybastin1207/mindia-core/internal/task"
+	"github.com/jeremybastin1207/mindia-core/internal/transform"
+	"github.com/jeremybastin1207/mindia-core/internal/webhook"
+)
+
+// storages holds the storers described by a config, the ones it doesn't
+// describe are nil.
+type storages struct {
+	redisPool                  *redigo.Pool
+	postgresPool               *pgxpool.Pool
+	fileStorage                media.FileStorer
+	cacheStorage               media.FileStorer
+	mediaStorage               media.Storer
+	namedTransformationStorage transform.Storer
+	apikeyStorage              apikey.Storer
+	taskStorage                scheduler.Storer
+	webhookStorage             webhook.Storer
+	folderStorage              folder.Storer
+	journalStorage             journal.Storer
+	collectionStorage          collection.Storer
+	closers                    []func()
+}
+
+func newStorages(c *config.Config) (*storages, error) {
+	var (
+		st       = &storages{}
+		s3Client *s3.S3
+	)
+
+	if c.Adapters.S3 != nil {
+		s3Client = s3.NewS3(s3.S3Config{
+			AccessKeyId:     c.Adapters.S3.AccessKeyId,
+			SecretAccessKey: c.Adapters.S3.SecretAccessKey,
+			Endpoint:        c.Adapters.S3.Endpoint,
+			Region:          c.Adapters.S3.Region,
+		})
+	}
+	if c.Adapters.Redis != nil {
+		redisAddr := fmt.Sprintf("%s:%v", c.Adapters.Redis.Host, c.Adapters.Redis.Port)
+		st.redisPool = redis.NewPool(redisAddr)
+	}
+	if c.Adapters.Postgres != nil {
+		postgresPool, err := postgres.NewPool(c.Adapters.Postgres.Url, c.Adapters.Postgres.MaxConns)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to postgres, %w", err)
+		}
+		st.closers = append(st.closers, postgresPool.Close)
+		st.postgresPool = postgresPool
+	}
+
+	if c.Storage.MediaStorage.FileStorage.FilesystemStorageConfig != nil {
+		st.fileStorage = filesystem.NewFileStorage(filesystem.FileStorageConfig{
+			MountDir: c.Storage.MediaStorage.FileStorage.FilesystemStorageConfig.MountDir,
+		})
+	} else if c.Storage.MediaStorage.FileStorage.S3StorageConfig != nil {
+		st.fileStorage = s3.NewFileStorage(s3.FileStorageConfig{
+			S3:     s3Client,
+			Bucket: c.Storage.MediaStorage.FileStorage.S3StorageConfig.Bucket,
+		})
+	}
+
+	if c.Storage.MediaStorage.CacheStorage.FilesystemStorageConfig != nil {
+		st.cacheStorage = filesystem.NewFileStorage(filesystem.FileStorageConfig{
+			MountDir: c.Storage.MediaStorage.CacheStorage.FilesystemStorageConfig.MountDir,
+		})
+	} else if c.Storage.MediaStorage.CacheStorage.S3StorageConfig != nil {
+		st.cacheStorage = s3.NewFileStorage(s3.FileStorageConfig{
+			S3:     s3Client,
+			Bucket: c.Storage.MediaStorage.CacheStorage.S3StorageConfig.Bucket,
+		})
+	}
+
+	if c.Storage.MediaStorage.MetadataStorage.Sqlite != nil {
+		sqliteMediaStorage, err := sqlite.NewMediaStorage(c.Storage.MediaStorage.MetadataStorage.Sqlite.Path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open sqlite media storage, %w", err)
+		}
+		st.closers = append(st.closers, func() { sqliteMediaStorage.Close() })
+		st.mediaStorage = sqliteMediaStorage
+	} else if c.Storage.MediaStorage.MetadataStorage.Postgres != nil {
+		st.mediaStorage = postgres.NewMediaStorage(st.postgresPool)
+	} else if c.Storage.MediaStorage.MetadataStorage.Redis != nil {
+		st.mediaStorage = redis.NewMediaStorage(st.redisPool)
+	}
+
+	if c.Storage.NamedTransforationStorage.Filesystem != nil {
+		st.namedTransformationStorage = filesystem.NewNamedTransformationStorage()
+	} else if c.Storage.NamedTransforationStorage.Redis != nil {
+		st.namedTransformationStorage = redis.NewNamedTransformationStorage(st.redisPool)
+	} else if c.Storage.NamedTransforationStorage.Postgres != nil {
+		st.namedTransformationStorage = postgres.NewNamedTransformationStorage(st.postgresPool)
+	}
+
+	if c.Storage.ApiKeyStorage.Filesystem != nil {
+		st.apikeyStorage = filesystem.NewApiKeyStorage()
+	} else if c.Storage.ApiKeyStorage.Redis != nil {
+		st.apikeyStorage = redis.NewApiKeyStorage(st.redisPool)
+	} else if c.Storage.ApiKeyStorage.Postgres != nil {
+		st.apikeyStorage = postgres.NewApiKeyStorage(st.postgresPool)
+	}
+
+	st.openTaskStorage(c.Storage.TaskStorage)
+
+	if c.Storage.WebhookStorage.Filesystem != nil {
+		st.webhookStorage = filesystem.NewWebhookStorage()
+	} else if c.Storage.WebhookStorage.Redis != nil {
+		st.webhookStorage = redis.NewWebhookStorage(st.redisPool)
+	}
+
+	if c.Storage.FolderStorage.Filesystem != nil {
+		st.folderStorage = filesystem.NewFolderStorage()
+	} else if c.Storage.FolderStorage.Redis != nil {
+		st.folderStorage = redis.NewFolderStorage(st.redisPool)
+	}
+
+	if c.Storage.JournalStorage.Filesystem != nil {
+		journalStorage := filesystem.NewJournalStorage(*c.Storage.JournalStorage.Filesystem)
+		st.closers = append(st.closers, func() { journalStorage.Close() })
+		st.journalStorage = journalStorage
+	} else if c.Storage.JournalStorage.Redis != nil {
+		st.journalStorage = redis.NewJournalStorage(st.redisPool)
+	} else if c.Storage.JournalStorage.Postgres != nil {
+		st.journalStorage = postgres.NewJournalStorage(st.postgresPool)
+	}
+
+	if c.Storage.CollectionStorage.Filesystem != nil {
+		st.collectionStorage = filesystem.NewCollectionStorage()
+	} else if c.Storage.CollectionStorage.Redis != nil {
+		st.collectionStorage = redis.NewCollectionStorage(st.redisPool)
+	} else if c.Storage.CollectionStorage.Postgres != nil {
+		st.collectionStorage = postgres.NewCollectionStorage(st.postgresPool)
+	}
+	return st, nil
+}
+
+// openTaskStorage builds the task storage described by c, it is left nil
+// when c describes none.
+func (st *storages) openTaskStorage(c config.TaskStorageConfig) {
+	if c.Filesystem != nil {
+		taskStorage := filesystem.NewTaskStorage(*c.Filesystem)
+		st.closers = append(st.closers, func() { taskStorage.Close() })
+		st.taskStorage = taskStorage
+	} else if c.Redis != nil {
+		st.taskStorage = redis.NewTaskStorage(st.redisPool)
+	} else if c.Postgres != nil {
+		st.taskStorage = postgres.NewTaskStorage(st.postgresPool)
+	}
+}
+
+// heldJournal is the journal held by another process, its operations can't
+// be listed.
+type heldJournal struct {
+	err error
+}
+
+func (j heldJournal) Save(op *journal.Operation) error { return j.err }
+func (j heldJournal) Delete(id uuid.UUID) error        { return j.err }
+func (j heldJournal) GetAll() ([]journal.Operation, error) {
+	return nil, j.err
+}
+
+// openJournalReadOnly opens the filesystem journal without waiting for the
+// server holding it, it is left nil when it was never created.
+func (st *storages) openJournalReadOnly(filename string) {
+	s, err := filesystem.OpenJournalStorageReadOnly(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		st.journalStorage = heldJournal{fmt.Errorf("journal is held by a running server, run with --background instead, %w", err)}
+		return
+	}
+	st.closers = append(st.closers, func() { s.Close() })
+	st.journalStorage = s
+}
+
+// requireAll returns an error when one of the storages the server needs is
+// not described by the config.
+func (st *storages) requireAll() error {
+	if err := st.requireMedias(); err != nil {
+		return err
+	}
+	switch {
+	case st.namedTransformationStorage == nil:
+		return errors.New("named transformation storage config must be provided")
+	case st.apikeyStorage == nil:
+		return errors.New("apikey storage config must be provided")
+	case st.taskStorage == nil:
+		return errors.New("task storage config must be provided")
+	case st.webhookStorage == nil:
+		return errors.New("webhook storage config must be provided")
+	case st.folderStorage == nil:
+		return errors.New("folder storage config must be provided")
+	case st.journalStorage == nil:
+		return errors.New("journal storage config must be provided")
+	case st.collectionStorage == nil:
+		return errors.New("collection storage config must be provided")
+	}
+	return nil
+}
+
+// requireMedias returns an error when one of the storages of the medias is
+// not described by the config.
+func (st *storages) requireMedias() error {
+	switch {
+	case st.fileStorage == nil:
+		return errors.New("file storage config must be provided")
+	case st.cacheStorage == nil:
+		return errors.New("cache storage config must be provided")
+	case st.mediaStorage == nil:
+		return errors.New("media storage config must be provided")
+	}
+	return nil
+}
+
+func (st *storages) close() {
+	for i := len(st.closers) - 1; i >= 0; i-- {
+		st.closers[i]()
+	}
+}
+
+// newMigrationTarget returns the storages described by the environment
+// variables prefixed by TARGET_, nil when there are none. Only the storages
+// a migration copies are built.
+func newMigrationTarget() (*storages, error) {
+	configStorage := config.NewPrefixedFilesystemStorage("TARGET_")
+	c, err := configStorage.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	c.Storage.TaskStorage = config.TaskStorageConfig{}
+	c.Storage.WebhookStorage = config.WebhookStorageConfig{}
+	c.Storage.FolderStorage = config.FolderStorageConfig{}
+	c.Storage.JournalStorage = config.JournalStorageConfig{}
+	c.Storage.CollectionStorage = config.CollectionStorageConfig{}
+
+	st, err := newStorages(c)
+	if err != nil {
+		return nil, err
+	}
+	if st.fileStorage == nil && st.cacheStorage == nil && st.mediaStorage == nil && st.apikeyStorage == nil && st.namedTransformationStorage == nil {
+		st.close()
+		return nil, nil
+	}
+	return st, nil
+}
+
+// newStorageMigrator returns the migrator copying st to the migration
+// target, both are nil when there is no target. The target must be closed
+// by the caller.
+func newStorageMigrator(st *storages) (*task.StorageMigrator, *storages, error) {
+	target, err := newMigrationTarget()
+	if err != nil || target == nil {
+		return nil, nil, err
+	}
+	m := task.NewStorageMigrator(st.migrationStorages(), target.migrationStorages())
+	return &m, target, nil
+}
+
+func (st *storages) migrationStorages() task.MigrationStorages {
+	return task.MigrationStorages{
+		FileStorage:                st.fileStorage,
+		CacheStorage:               st.cacheStorage,
+		MediaStorage:               st.mediaStorage,
+		ApiKeyStorage:              st.apikeyStorage,
+		NamedTransformationStorage: st.namedTransformationStorage,
+	}
+}